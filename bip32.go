@@ -0,0 +1,323 @@
+package outscript
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BottleFmt/gobottle"
+	"github.com/KarpelesLab/base58"
+	"github.com/KarpelesLab/secp256k1"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// HDHardened is added to a child index for hardened derivation.
+const HDHardened = 0x80000000
+
+// HDKey is a BIP-32 extended key, holding either a private or a public key.
+type HDKey struct {
+	Version           uint32 // serialization version, see [HDKey.Prefix]
+	Depth             uint8
+	ParentFingerprint uint32 // as big endian, zero for master keys
+	ChildNumber       uint32
+	ChainCode         []byte
+	Key               []byte // 0x00 followed by the private key, or compressed public key
+}
+
+// hdVersion is a pair of SLIP-132 version bytes, with the address scheme and network they imply
+type hdVersion struct {
+	priv, pub       string
+	privV, pubV     uint32
+	scheme, network string
+}
+
+var hdVersions = []*hdVersion{
+	{"xprv", "xpub", 0x0488ade4, 0x0488b21e, "p2pkh", "bitcoin"},
+	{"yprv", "ypub", 0x049d7878, 0x049d7cb2, "p2sh:p2wpkh", "bitcoin"},
+	{"zprv", "zpub", 0x04b2430c, 0x04b24746, "p2wpkh", "bitcoin"},
+	{"Yprv", "Ypub", 0x0295b005, 0x0295b43f, "p2sh:p2wsh:multisig", "bitcoin"},
+	{"Zprv", "Zpub", 0x02aa7a99, 0x02aa7ed3, "p2wsh:multisig", "bitcoin"},
+	{"tprv", "tpub", 0x04358394, 0x043587cf, "p2pkh", "bitcoin-testnet"},
+	{"uprv", "upub", 0x044a4e28, 0x044a5262, "p2sh:p2wpkh", "bitcoin-testnet"},
+	{"vprv", "vpub", 0x045f18bc, 0x045f1cf6, "p2wpkh", "bitcoin-testnet"},
+	{"Uprv", "Upub", 0x024285b5, 0x024289ef, "p2sh:p2wsh:multisig", "bitcoin-testnet"},
+	{"Vprv", "Vpub", 0x02575048, 0x02575483, "p2wsh:multisig", "bitcoin-testnet"},
+}
+
+func findHDVersion(v uint32) (*hdVersion, bool) {
+	for _, hv := range hdVersions {
+		if hv.privV == v {
+			return hv, true
+		}
+		if hv.pubV == v {
+			return hv, false
+		}
+	}
+	return nil, false
+}
+
+// NewHDKey returns the BIP-32 master key generated from seed, with the xprv version.
+func NewHDKey(seed []byte) (*HDKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("seed must be between 16 and 64 bytes long")
+	}
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	I := mac.Sum(nil)
+
+	var k secp256k1.ModNScalar
+	if overflow := k.SetByteSlice(I[:32]); overflow || k.IsZero() {
+		return nil, errors.New("invalid master key, use another seed")
+	}
+	return &HDKey{Version: 0x0488ade4, ChainCode: I[32:], Key: append([]byte{0}, I[:32]...)}, nil
+}
+
+// ParseHDKey parses a base58 encoded extended key such as xprv, xpub or one of their SLIP-132
+// variants (ypub, zpub, tpub, etc).
+func ParseHDKey(s string) (*HDKey, error) {
+	buf, err := base58.Bitcoin.Decode(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) != 82 {
+		return nil, errors.New("invalid extended key length")
+	}
+	h := gobottle.Hash(buf[:78], sha256.New, sha256.New)
+	if subtle.ConstantTimeCompare(h[:4], buf[78:]) != 1 {
+		return nil, errors.New("bad checksum")
+	}
+	k := &HDKey{
+		Version:           binary.BigEndian.Uint32(buf[:4]),
+		Depth:             buf[4],
+		ParentFingerprint: binary.BigEndian.Uint32(buf[5:9]),
+		ChildNumber:       binary.BigEndian.Uint32(buf[9:13]),
+		ChainCode:         slices.Clone(buf[13:45]),
+		Key:               slices.Clone(buf[45:78]),
+	}
+	if k.Depth == 0 && (k.ParentFingerprint != 0 || k.ChildNumber != 0) {
+		return nil, errors.New("invalid master key parent or child number")
+	}
+	if hv, priv := findHDVersion(k.Version); hv != nil && priv != k.IsPrivate() {
+		return nil, errors.New("extended key version does not match key type")
+	}
+	if k.IsPrivate() {
+		var d secp256k1.ModNScalar
+		if overflow := d.SetByteSlice(k.Key[1:]); overflow || d.IsZero() {
+			return nil, errors.New("invalid private key")
+		}
+	} else if _, err := secp256k1.ParsePubKey(k.Key); err != nil || k.Key[0] == 4 {
+		return nil, errors.New("invalid public key")
+	}
+	return k, nil
+}
+
+// String returns the base58 serialization of the extended key.
+func (k *HDKey) String() string {
+	buf := binary.BigEndian.AppendUint32(nil, k.Version)
+	buf = append(buf, k.Depth)
+	buf = binary.BigEndian.AppendUint32(buf, k.ParentFingerprint)
+	buf = binary.BigEndian.AppendUint32(buf, k.ChildNumber)
+	buf = slices.Concat(buf, k.ChainCode, k.Key)
+	h := gobottle.Hash(buf, sha256.New, sha256.New)
+	return base58.Bitcoin.Encode(append(buf, h[:4]...))
+}
+
+// Prefix returns the prefix of the serialized key (xprv, xpub, zpub, etc) or an empty string if
+// the version is unknown.
+func (k *HDKey) Prefix() string {
+	hv, priv := findHDVersion(k.Version)
+	switch {
+	case hv == nil:
+		return ""
+	case priv:
+		return hv.priv
+	default:
+		return hv.pub
+	}
+}
+
+// Scheme returns the address scheme and network implied by the SLIP-132 version of the key, for
+// example "p2wpkh" and "bitcoin" for zpub keys.
+func (k *HDKey) Scheme() (string, string) {
+	hv, _ := findHDVersion(k.Version)
+	if hv == nil {
+		return "", ""
+	}
+	return hv.scheme, hv.network
+}
+
+// WithPrefix returns a copy of the key using the version matching prefix, for example "zpub". A
+// private key is converted to its public key if a public prefix is requested.
+func (k *HDKey) WithPrefix(prefix string) (*HDKey, error) {
+	for _, hv := range hdVersions {
+		switch prefix {
+		case hv.priv:
+			if !k.IsPrivate() {
+				return nil, errors.New("cannot convert a public key to a private key")
+			}
+			res := k.dup()
+			res.Version = hv.privV
+			return res, nil
+		case hv.pub:
+			res := k.dup()
+			if k.IsPrivate() {
+				res.Key = k.PubKey().SerializeCompressed()
+			}
+			res.Version = hv.pubV
+			return res, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported extended key prefix %s", prefix)
+}
+
+func (k *HDKey) dup() *HDKey {
+	res := *k
+	res.ChainCode = slices.Clone(k.ChainCode)
+	res.Key = slices.Clone(k.Key)
+	return &res
+}
+
+// IsPrivate returns true if the extended key holds a private key.
+func (k *HDKey) IsPrivate() bool {
+	return len(k.Key) == 33 && k.Key[0] == 0
+}
+
+// PrivKey returns the private key, or nil for public extended keys. The result can be used as
+// [BtcTxSign.Key].
+func (k *HDKey) PrivKey() *secp256k1.PrivateKey {
+	if !k.IsPrivate() {
+		return nil
+	}
+	return secp256k1.PrivKeyFromBytes(k.Key[1:])
+}
+
+// PubKey returns the public key, which can be passed to [New].
+func (k *HDKey) PubKey() *secp256k1.PublicKey {
+	if k.IsPrivate() {
+		return k.PrivKey().PubKey()
+	}
+	pub, err := secp256k1.ParsePubKey(k.Key)
+	if err != nil {
+		return nil
+	}
+	return pub
+}
+
+// Neuter returns the public extended key matching k.
+func (k *HDKey) Neuter() (*HDKey, error) {
+	if !k.IsPrivate() {
+		return k, nil
+	}
+	hv, _ := findHDVersion(k.Version)
+	if hv == nil {
+		return nil, fmt.Errorf("unknown extended key version %08x", k.Version)
+	}
+	return k.WithPrefix(hv.pub)
+}
+
+// Fingerprint returns the first 4 bytes of the hash160 of the public key, as big endian.
+func (k *HDKey) Fingerprint() uint32 {
+	h := gobottle.Hash(k.PubKey().SerializeCompressed(), sha256.New, ripemd160.New)
+	return binary.BigEndian.Uint32(h[:4])
+}
+
+// Child derives the child key at index i, hardened if i is HDHardened or more. Hardened
+// derivation requires a private key. In the unlikely case the resulting key is invalid an error
+// is returned and the next index should be used.
+func (k *HDKey) Child(i uint32) (*HDKey, error) {
+	if k.Depth == 255 {
+		return nil, errors.New("maximum derivation depth reached")
+	}
+	pub := k.PubKey()
+	if pub == nil {
+		return nil, errors.New("invalid public key")
+	}
+	var data []byte
+	if i >= HDHardened {
+		if !k.IsPrivate() {
+			return nil, errors.New("hardened derivation requires a private key")
+		}
+		data = slices.Clone(k.Key)
+	} else {
+		data = pub.SerializeCompressed()
+	}
+	data = binary.BigEndian.AppendUint32(data, i)
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	I := mac.Sum(nil)
+
+	var t secp256k1.ModNScalar
+	if overflow := t.SetByteSlice(I[:32]); overflow {
+		return nil, errors.New("invalid child key, use the next index")
+	}
+	res := &HDKey{Version: k.Version, Depth: k.Depth + 1, ParentFingerprint: k.Fingerprint(), ChildNumber: i, ChainCode: I[32:]}
+
+	if k.IsPrivate() {
+		t.Add(&k.PrivKey().Key)
+		if t.IsZero() {
+			return nil, errors.New("invalid child key, use the next index")
+		}
+		b := t.Bytes()
+		res.Key = append([]byte{0}, b[:]...)
+		return res, nil
+	}
+
+	var tG, P, Q secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&t, &tG)
+	pub.AsJacobian(&P)
+	secp256k1.AddNonConst(&tG, &P, &Q)
+	if (Q.X.IsZero() && Q.Y.IsZero()) || Q.Z.IsZero() {
+		return nil, errors.New("invalid child key, use the next index")
+	}
+	Q.ToAffine()
+	res.Key = secp256k1.NewPublicKey(&Q.X, &Q.Y).SerializeCompressed()
+	return res, nil
+}
+
+// Derive derives the key at the given path, such as "m/84'/0'/0'/0/5". Paths starting with "m"
+// can only be derived from a master key, other paths are relative to k.
+func (k *HDKey) Derive(path string) (*HDKey, error) {
+	if strings.HasPrefix(path, "m") && k.Depth != 0 {
+		return nil, errors.New("absolute derivation path requires a master key")
+	}
+	p, err := ParseHDPath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range p {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// ParseHDPath parses a BIP-32 derivation path such as "m/84'/0'/0'/0/5". Hardened indexes can be
+// marked with ', h or H and have HDHardened added.
+func ParseHDPath(path string) ([]uint32, error) {
+	if path == "m" {
+		return nil, nil
+	}
+	path = strings.TrimPrefix(path, "m/")
+	var res []uint32
+	for _, s := range strings.Split(path, "/") {
+		var hardened uint32
+		if l := len(s) - 1; l > 0 && (s[l] == '\'' || s[l] == 'h' || s[l] == 'H') {
+			hardened = HDHardened
+			s = s[:l]
+		}
+		i, err := strconv.ParseUint(s, 10, 31)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path element %q", s)
+		}
+		res = append(res, uint32(i)|hardened)
+	}
+	return res, nil
+}
@@ -0,0 +1,187 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"slices"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+)
+
+func TestHDKeyVectors(t *testing.T) {
+	// BIP-32 test vectors 1 to 3, each step listing the derived xpub and xprv
+	vectors := []struct {
+		seed string
+		path []string
+		keys []string
+	}{
+		{
+			"000102030405060708090a0b0c0d0e0f",
+			[]string{"m", "0'", "1", "2H", "2", "1000000000"},
+			[]string{
+				"xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8",
+				"xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi",
+				"xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw",
+				"xprv9uHRZZhk6KAJC1avXpDAp4MDc3sQKNxDiPvvkX8Br5ngLNv1TxvUxt4cV1rGL5hj6KCesnDYUhd7oWgT11eZG7XnxHrnYeSvkzY7d2bhkJ7",
+				"xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ",
+				"xprv9wTYmMFdV23N2TdNG573QoEsfRrWKQgWeibmLntzniatZvR9BmLnvSxqu53Kw1UmYPxLgboyZQaXwTCg8MSY3H2EU4pWcQDnRnrVA1xe8fs",
+				"xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5",
+				"xprv9z4pot5VBttmtdRTWfWQmoH1taj2axGVzFqSb8C9xaxKymcFzXBDptWmT7FwuEzG3ryjH4ktypQSAewRiNMjANTtpgP4mLTj34bhnZX7UiM",
+				"xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV",
+				"xprvA2JDeKCSNNZky6uBCviVfJSKyQ1mDYahRjijr5idH2WwLsEd4Hsb2Tyh8RfQMuPh7f7RtyzTtdrbdqqsunu5Mm3wDvUAKRHSC34sJ7in334",
+				"xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy",
+				"xprvA41z7zogVVwxVSgdKUHDy1SKmdb533PjDz7J6N6mV6uS3ze1ai8FHa8kmHScGpWmj4WggLyQjgPie1rFSruoUihUZREPSL39UNdE3BBDu76",
+			},
+		},
+		{
+			"fffcf9f6f3f0edeae7e4e1dedbd8d5d2cfccc9c6c3c0bdbab7b4b1aeaba8a5a29f9c999693908d8a8784817e7b7875726f6c696663605d5a5754514e4b484542",
+			[]string{"m", "0", "2147483647'", "1", "2147483646'", "2"},
+			[]string{
+				"xpub661MyMwAqRbcFW31YEwpkMuc5THy2PSt5bDMsktWQcFF8syAmRUapSCGu8ED9W6oDMSgv6Zz8idoc4a6mr8BDzTJY47LJhkJ8UB7WEGuduB",
+				"xprv9s21ZrQH143K31xYSDQpPDxsXRTUcvj2iNHm5NUtrGiGG5e2DtALGdso3pGz6ssrdK4PFmM8NSpSBHNqPqm55Qn3LqFtT2emdEXVYsCzC2U",
+				"xpub69H7F5d8KSRgmmdJg2KhpAK8SR3DjMwAdkxj3ZuxV27CprR9LgpeyGmXUbC6wb7ERfvrnKZjXoUmmDznezpbZb7ap6r1D3tgFxHmwMkQTPH",
+				"xprv9vHkqa6EV4sPZHYqZznhT2NPtPCjKuDKGY38FBWLvgaDx45zo9WQRUT3dKYnjwih2yJD9mkrocEZXo1ex8G81dwSM1fwqWpWkeS3v86pgKt",
+				"xpub6ASAVgeehLbnwdqV6UKMHVzgqAG8Gr6riv3Fxxpj8ksbH9ebxaEyBLZ85ySDhKiLDBrQSARLq1uNRts8RuJiHjaDMBU4Zn9h8LZNnBC5y4a",
+				"xprv9wSp6B7kry3Vj9m1zSnLvN3xH8RdsPP1Mh7fAaR7aRLcQMKTR2vidYEeEg2mUCTAwCd6vnxVrcjfy2kRgVsFawNzmjuHc2YmYRmagcEPdU9",
+				"xpub6DF8uhdarytz3FWdA8TvFSvvAh8dP3283MY7p2V4SeE2wyWmG5mg5EwVvmdMVCQcoNJxGoWaU9DCWh89LojfZ537wTfunKau47EL2dhHKon",
+				"xprv9zFnWC6h2cLgpmSA46vutJzBcfJ8yaJGg8cX1e5StJh45BBciYTRXSd25UEPVuesF9yog62tGAQtHjXajPPdbRCHuWS6T8XA2ECKADdw4Ef",
+				"xpub6ERApfZwUNrhLCkDtcHTcxd75RbzS1ed54G1LkBUHQVHQKqhMkhgbmJbZRkrgZw4koxb5JaHWkY4ALHY2grBGRjaDMzQLcgJvLJuZZvRcEL",
+				"xprvA1RpRA33e1JQ7ifknakTFpgNXPmW2YvmhqLQYMmrj4xJXXWYpDPS3xz7iAxn8L39njGVyuoseXzU6rcxFLJ8HFsTjSyQbLYnMpCqE2VbFWc",
+				"xpub6FnCn6nSzZAw5Tw7cgR9bi15UV96gLZhjDstkXXxvCLsUXBGXPdSnLFbdpq8p9HmGsApME5hQTZ3emM2rnY5agb9rXpVGyy3bdW6EEgAtqt",
+				"xprvA2nrNbFZABcdryreWet9Ea4LvTJcGsqrMzxHx98MMrotbir7yrKCEXw7nadnHM8Dq38EGfSh6dqA9QWTyefMLEcBYJUuekgW4BYPJcr9E7j",
+			},
+		},
+		{
+			// retention of leading zeros
+			"4b381541583be4423346c643850da4b320e46a87ae3d2a4e6da11eba819cd4acba45d239319ac14f863b8d5ab5a0d0c64d2e8a1e7d1457df2e5a3c51c73235be",
+			[]string{"m", "0'"},
+			[]string{
+				"xpub661MyMwAqRbcEZVB4dScxMAdx6d4nFc9nvyvH3v4gJL378CSRZiYmhRoP7mBy6gSPSCYk6SzXPTf3ND1cZAceL7SfJ1Z3GC8vBgp2epUt13",
+				"xprv9s21ZrQH143K25QhxbucbDDuQ4naNntJRi4KUfWT7xo4EKsHt2QJDu7KXp1A3u7Bi1j8ph3EGsZ9Xvz9dGuVrtHHs7pXeTzjuxBrCmmhgC6",
+				"xpub68NZiKmJWnxxS6aaHmn81bvJeTESw724CRDs6HbuccFQN9Ku14VQrADWgqbhhTHBaohPX4CjNLf9fq9MYo6oDaPPLPxSb7gwQN3ih19Zm4Y",
+				"xprv9uPDJpEQgRQfDcW7BkF7eTya6RPxXeJCqCJGHuCJ4GiRVLzkTXBAJMu2qaMWPrS7AANYqdq6vcBcBUdJCVVFceUvJFjaPdGZ2y9WACViL4L",
+			},
+		},
+	}
+
+	for _, v := range vectors {
+		master := must(outscript.NewHDKey(must(hex.DecodeString(v.seed))))
+		k := master
+		var pub *outscript.HDKey
+		for n, p := range v.path {
+			if n > 0 {
+				k = must(k.Derive(p))
+				// non-hardened derivation from the parent public key gives the same result
+				if i := must(outscript.ParseHDPath(p)); i[0] < outscript.HDHardened {
+					if s := must(pub.Child(i[0])).String(); s != v.keys[2*n] {
+						t.Errorf("public derivation of %s: got %s", p, s)
+					}
+				}
+			}
+			pub = must(k.Neuter())
+			if s := pub.String(); s != v.keys[2*n] {
+				t.Errorf("%s: got %s expected %s", p, s, v.keys[2*n])
+			}
+			if s := k.String(); s != v.keys[2*n+1] {
+				t.Errorf("%s: got %s expected %s", p, s, v.keys[2*n+1])
+			}
+			// serialization round trip
+			for _, s := range v.keys[2*n : 2*n+2] {
+				if parsed := must(outscript.ParseHDKey(s)); parsed.String() != s {
+					t.Errorf("failed to round trip %s", s)
+				}
+			}
+		}
+		// full path derivation from the master key
+		full := "m/" + v.path[1]
+		for _, p := range v.path[2:] {
+			full += "/" + p
+		}
+		if s := must(master.Derive(full)).String(); s != v.keys[len(v.keys)-1] {
+			t.Errorf("failed to derive %s: got %s", full, s)
+		}
+	}
+}
+
+func TestHDKeySLIP132(t *testing.T) {
+	// BIP-84 test vector, root key of "abandon abandon ... about"
+	root := must(outscript.ParseHDKey("zprvAWgYBBk7JR8Gjrh4UJQ2uJdG1r3WNRRfURiABBE3RvMXYSrRJL62XuezvGdPvG6GFBZduosCc1YP5wixPox7zhZLfiUm8aunE96BBa4Kei5"))
+	if root.Prefix() != "zprv" || !root.IsPrivate() {
+		t.Errorf("unexpected root key prefix %s", root.Prefix())
+	}
+	if scheme, network := root.Scheme(); scheme != "p2wpkh" || network != "bitcoin" {
+		t.Errorf("unexpected scheme %s %s", scheme, network)
+	}
+	account := must(root.Derive("m/84'/0'/0'"))
+	zpub := must(account.WithPrefix("zpub"))
+	if s := zpub.String(); s != "zpub6rFR7y4Q2AijBEqTUquhVz398htDFrtymD9xYYfG1m4wAcvPhXNfE3EfH1r1ADqtfSdVCToUG868RvUUkgDKf31mGDtKsAYz2oz2AGutZYs" {
+		t.Errorf("unexpected account zpub %s", s)
+	}
+	if s := must(zpub.WithPrefix("xpub")).String(); s[:4] != "xpub" || must(outscript.ParseHDKey(s)).Fingerprint() != zpub.Fingerprint() {
+		t.Errorf("failed to convert zpub to xpub: %s", s)
+	}
+	if _, err := zpub.WithPrefix("zprv"); err == nil {
+		t.Errorf("expected error converting a public key to private")
+	}
+
+	// receive addresses, from the private and the public account key
+	for _, k := range []*outscript.HDKey{account, zpub} {
+		key := must(k.Derive("0/0"))
+		if addr := must(outscript.New(key.PubKey()).Address("p2wpkh", "bitcoin")); addr != "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu" {
+			t.Errorf("unexpected first address %s", addr)
+		}
+	}
+	if _, err := zpub.Derive("m/0"); err == nil {
+		t.Errorf("expected error deriving an absolute path from a non-master key")
+	}
+	if _, err := zpub.Derive("0'"); err == nil {
+		t.Errorf("expected error deriving a hardened key from a public key")
+	}
+
+	// fingerprints and parents
+	key := must(account.Derive("0/1"))
+	if key.Depth != 5 || key.ChildNumber != 1 || key.ParentFingerprint != must(account.Derive("0")).Fingerprint() {
+		t.Errorf("unexpected key metadata")
+	}
+	if account.PrivKey() == nil || zpub.PrivKey() != nil {
+		t.Errorf("unexpected private key availability")
+	}
+}
+
+func TestParseHDPath(t *testing.T) {
+	h := uint32(outscript.HDHardened)
+	vectors := []struct {
+		path string
+		res  []uint32
+	}{
+		{"m", nil},
+		{"m/84'/0'/0'/0/5", []uint32{84 + h, h, h, 0, 5}},
+		{"m/44h/60H/0h", []uint32{44 + h, 60 + h, h}},
+		{"0/1", []uint32{0, 1}},
+		{"2147483647'", []uint32{0xffffffff}},
+	}
+	for _, v := range vectors {
+		res, err := outscript.ParseHDPath(v.path)
+		if err != nil || !slices.Equal(res, v.res) {
+			t.Errorf("ParseHDPath(%s) = %v, %v", v.path, res, err)
+		}
+	}
+	for _, path := range []string{"m/", "m//1", "m/2147483648", "m/-1", "m/a", "m/1''", "x/1"} {
+		if _, err := outscript.ParseHDPath(path); err == nil {
+			t.Errorf("expected error parsing %s", path)
+		}
+	}
+
+	// invalid keys
+	for _, s := range []string{
+		"xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet9", // bad checksum
+		"xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8Nqtwyb",                                                           // too short
+	} {
+		if _, err := outscript.ParseHDKey(s); err == nil {
+			t.Errorf("expected error parsing %s", s)
+		}
+	}
+	if _, err := outscript.NewHDKey(make([]byte, 8)); err == nil {
+		t.Errorf("expected error with short seed")
+	}
+}
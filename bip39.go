@@ -0,0 +1,137 @@
+package outscript
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/BottleFmt/gobottle"
+)
+
+// Wordlist is a BIP-39 list of 2048 words used to encode mnemonics.
+type Wordlist struct {
+	words []string
+	index map[string]int
+}
+
+// EnglishWordlist is the BIP-39 english wordlist, used by default.
+var EnglishWordlist = mustWordlist(strings.Fields(bip39English))
+
+// NewWordlist returns a wordlist for the given 2048 words, for example one of the other languages
+// published with BIP-39. Words must be unique and in NFKD form.
+func NewWordlist(words []string) (*Wordlist, error) {
+	if len(words) != 2048 {
+		return nil, fmt.Errorf("wordlist must have 2048 words, got %d", len(words))
+	}
+	wl := &Wordlist{words: words, index: make(map[string]int)}
+	for n, w := range words {
+		if _, found := wl.index[w]; found {
+			return nil, fmt.Errorf("duplicate word %q in wordlist", w)
+		}
+		wl.index[w] = n
+	}
+	return wl, nil
+}
+
+func mustWordlist(words []string) *Wordlist {
+	wl, err := NewWordlist(words)
+	if err != nil {
+		panic(err)
+	}
+	return wl
+}
+
+// Mnemonic returns the mnemonic encoding entropy, which must be 16, 20, 24, 28 or 32 bytes long
+// for a 12, 15, 18, 21 or 24 words mnemonic.
+func (wl *Wordlist) Mnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", errors.New("entropy must be 16, 20, 24, 28 or 32 bytes long")
+	}
+	// entropy is followed by 1 bit of checksum per 32 bits, and split in groups of 11 bits
+	h := gobottle.Hash(entropy, sha256.New)
+	cs := len(entropy) / 4
+	v := new(big.Int).SetBytes(entropy)
+	v.Lsh(v, uint(cs))
+	v.Or(v, big.NewInt(int64(h[0]>>(8-cs))))
+
+	res := make([]string, (len(entropy)*8+cs)/11)
+	mask := big.NewInt(2047)
+	for n := len(res) - 1; n >= 0; n-- {
+		res[n] = wl.words[new(big.Int).And(v, mask).Int64()]
+		v.Rsh(v, 11)
+	}
+	return strings.Join(res, " "), nil
+}
+
+// Entropy returns the entropy encoded in mnemonic, checking its words and checksum.
+func (wl *Wordlist) Entropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	switch len(words) {
+	case 12, 15, 18, 21, 24:
+	default:
+		return nil, fmt.Errorf("invalid mnemonic length of %d words", len(words))
+	}
+	v := new(big.Int)
+	for _, w := range words {
+		i, ok := wl.index[w]
+		if !ok {
+			return nil, fmt.Errorf("invalid mnemonic word %q", w)
+		}
+		v.Lsh(v, 11)
+		v.Or(v, big.NewInt(int64(i)))
+	}
+	cs := len(words) / 3
+	sum := byte(v.Int64() & (1<<cs - 1))
+	v.Rsh(v, uint(cs))
+
+	entropy := v.FillBytes(make([]byte, cs*4))
+	h := gobottle.Hash(entropy, sha256.New)
+	if h[0]>>(8-cs) != sum {
+		return nil, errors.New("invalid mnemonic checksum")
+	}
+	return entropy, nil
+}
+
+// NewMnemonic generates a random english mnemonic of 12, 15, 18, 21 or 24 words.
+func NewMnemonic(words int) (string, error) {
+	switch words {
+	case 12, 15, 18, 21, 24:
+	default:
+		return "", fmt.Errorf("invalid mnemonic length of %d words", words)
+	}
+	entropy := make([]byte, words/3*4)
+	if _, err := rand.Read(entropy); err != nil {
+		return "", err
+	}
+	return EnglishWordlist.Mnemonic(entropy)
+}
+
+// MnemonicSeed returns the 64 bytes seed for mnemonic and the optional passphrase. The mnemonic is
+// not checked against any wordlist. Both strings are expected to be in NFKD form, which is always
+// the case for english mnemonics and ASCII passphrases.
+func MnemonicSeed(mnemonic, passphrase string) ([]byte, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key(sha512.New, mnemonic, []byte("mnemonic"+passphrase), 2048, 64)
+}
+
+// NewHDKeyFromMnemonic checks mnemonic against wordlist (english if not specified) and returns the
+// BIP-32 master key for its seed with the optional passphrase.
+func NewHDKeyFromMnemonic(mnemonic, passphrase string, wordlist ...*Wordlist) (*HDKey, error) {
+	wl := EnglishWordlist
+	if len(wordlist) > 0 {
+		wl = wordlist[0]
+	}
+	if _, err := wl.Entropy(mnemonic); err != nil {
+		return nil, err
+	}
+	seed, err := MnemonicSeed(mnemonic, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return NewHDKey(seed)
+}
@@ -0,0 +1,159 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+)
+
+func TestMnemonicVectors(t *testing.T) {
+	// vectors from https://github.com/trezor/python-mnemonic/blob/master/vectors.json, with passphrase TREZOR
+	vectors := [][3]string{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			"808080808080808080808080808080808080808080808080",
+			"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter always",
+			"107d7c02a5aa6f38c58083ff74f04c607c2d2c0ecc55501dadd72d025b751bc27fe913ffb796f841c49b1d33b610cf0e91d3aa239027f5e99fe4ce9e5088cd65",
+		},
+		{
+			"b63a9c59a6e641f288ebc103017f1da9f8290b3da6bdef7b",
+			"renew stay biology evidence goat welcome casual join adapt armor shuffle fault little machine walk stumble urge swap",
+			"9248d83e06f4cd98debf5b6f010542760df925ce46cf38a1bdb4e4de7d21f5c39366941c69e1bdbf2966e0f6e6dbece898a0e2f0a4c2b3e640953dfe8b7bbdc5",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+		{
+			"15da872c95a13dd738fbf50e427583ad61f18fd99f628c417a61cf8343c90419",
+			"beyond stage sleep clip because twist token leaf atom beauty genius food business side grid unable middle armed observe pair crouch tonight away coconut",
+			"b15509eaa2d09d3efd3e006ef42151b30367dc6e3aa5e44caba3fe4d3e352e65101fbdb86a96776b91946ff06f8eac594dc6ee1d3e82a42dfe1b40fef6bcc3fd",
+		},
+	}
+
+	for _, v := range vectors {
+		entropy := must(hex.DecodeString(v[0]))
+		if m := must(outscript.EnglishWordlist.Mnemonic(entropy)); m != v[1] {
+			t.Errorf("bad mnemonic for %s: %s", v[0], m)
+		}
+		if e := must(outscript.EnglishWordlist.Entropy(v[1])); hex.EncodeToString(e) != v[0] {
+			t.Errorf("bad entropy for %s: %x", v[1], e)
+		}
+		if seed := must(outscript.MnemonicSeed(v[1], "TREZOR")); hex.EncodeToString(seed) != v[2] {
+			t.Errorf("bad seed for %s: %x", v[1], seed)
+		}
+	}
+
+	k := must(outscript.NewHDKeyFromMnemonic(vectors[0][1], "TREZOR"))
+	if k.String() != "xprv9s21ZrQH143K3h3fDYiay8mocZ3afhfULfb5GX8kCBdno77K4HiA15Tg23wpbeF1pLfs1c5SPmYHrEpTuuRhxMwvKDwqdKiGJS9XFKzUsAF" {
+		t.Errorf("bad master key %s", k)
+	}
+}
+
+func TestMnemonicInvalid(t *testing.T) {
+	invalid := []string{
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow yellow",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice caged above",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo, wrong",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo why",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon",
+	}
+	for _, m := range invalid {
+		if _, err := outscript.EnglishWordlist.Entropy(m); err == nil {
+			t.Errorf("expected error for %q", m)
+		}
+		if _, err := outscript.NewHDKeyFromMnemonic(m, ""); err == nil {
+			t.Errorf("expected master key error for %q", m)
+		}
+	}
+	if _, err := outscript.EnglishWordlist.Mnemonic(make([]byte, 18)); err == nil {
+		t.Errorf("expected error for 18 bytes of entropy")
+	}
+	if _, err := outscript.NewMnemonic(13); err == nil {
+		t.Errorf("expected error for 13 words")
+	}
+	if _, err := outscript.NewWordlist([]string{"abandon"}); err == nil {
+		t.Errorf("expected error for short wordlist")
+	}
+}
+
+func TestMnemonicGenerate(t *testing.T) {
+	for _, n := range []int{12, 15, 18, 21, 24} {
+		m := must(outscript.NewMnemonic(n))
+		if l := len(strings.Fields(m)); l != n {
+			t.Errorf("expected %d words, got %d", n, l)
+		}
+		entropy := must(outscript.EnglishWordlist.Entropy(m))
+		if len(entropy) != n/3*4 || must(outscript.EnglishWordlist.Mnemonic(entropy)) != m {
+			t.Errorf("mnemonic %q does not round trip", m)
+		}
+	}
+
+	// other languages can be used with their own wordlist
+	wl := make([]string, 2048)
+	for n := range wl {
+		wl[n] = "w" + hex.EncodeToString([]byte{byte(n >> 8), byte(n)})
+	}
+	custom := must(outscript.NewWordlist(wl))
+	m := must(custom.Mnemonic(make([]byte, 16)))
+	if !strings.HasPrefix(m, "w0000 w0000 ") || must(custom.Entropy(m)) == nil {
+		t.Errorf("unexpected custom wordlist mnemonic %s", m)
+	}
+	if _, err := outscript.NewHDKeyFromMnemonic(m, "", custom); err != nil {
+		t.Errorf("failed to use custom wordlist: %s", err)
+	}
+	if _, err := outscript.NewHDKeyFromMnemonic(m, ""); err == nil {
+		t.Errorf("expected error with the english wordlist")
+	}
+}
+
+func TestMnemonicScripts(t *testing.T) {
+	master := must(outscript.NewHDKeyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", ""))
+	if zprv := must(master.WithPrefix("zprv")); zprv.String() != "zprvAWgYBBk7JR8Gjrh4UJQ2uJdG1r3WNRRfURiABBE3RvMXYSrRJL62XuezvGdPvG6GFBZduosCc1YP5wixPox7zhZLfiUm8aunE96BBa4Kei5" {
+		t.Errorf("bad BIP-84 root key %s", zprv)
+	}
+
+	addrs := []struct{ path, format, network, addr string }{
+		{"m/44'/0'/0'/0/0", "p2pkh", "bitcoin", "1LqBGSKuX5yYUonjxT5qGfpUsXKYYWeabA"},
+		{"m/49'/0'/0'/0/0", "p2sh:p2wpkh", "bitcoin", "37VucYSaXLCAsxYyAPfbSi9eh4iEcbShgf"},
+		{"m/84'/0'/0'/0/0", "p2wpkh", "bitcoin", "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu"},
+		{"m/44'/60'/0'/0/0", "eth", "evm", "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"},
+	}
+	for _, a := range addrs {
+		k := must(master.Derive(a.path))
+		if addr := must(outscript.New(k.PubKey()).Address(a.format, a.network)); addr != a.addr {
+			t.Errorf("bad %s address at %s: %s", a.format, a.path, addr)
+		}
+	}
+
+	// a single key yields scripts for all secp256k1 networks
+	s := outscript.New(must(master.Derive("m/44'/0'/0'/0/0")).PubKey())
+	for network, formats := range outscript.FormatsPerNetwork {
+		if network == "massa" || network == "solana" {
+			continue
+		}
+		for _, f := range formats {
+			if _, err := s.Generate(f); err != nil {
+				t.Errorf("failed to generate %s script for %s: %s", f, network, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package outscript
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Weight returns the BIP-141 weight of the transaction, counting 4 units per byte of non-witness
+// data and 1 unit per byte of witness data.
+func (tx *BtcTx) Weight() int {
+	return 3*len(tx.exportBytes(false)) + len(tx.exportBytes(tx.HasWitness()))
+}
+
+// VSize returns the virtual size of the transaction in vbytes, its weight divided by 4 rounded up.
+func (tx *BtcTx) VSize() int {
+	return (tx.Weight() + 3) / 4
+}
+
+// EstimateVSize returns the virtual size the transaction will have once signed, using the given
+// scheme for each input that has neither script nor witness yet. Signed inputs are counted as is.
+func (tx *BtcTx) EstimateVSize(schemes ...string) (int, error) {
+	if len(schemes) != len(tx.In) {
+		return 0, errors.New("EstimateVSize requires as many schemes as there are inputs")
+	}
+	wtx := tx.Dup()
+	for n, in := range wtx.In {
+		if len(in.Script) > 0 || len(in.Witnesses) > 0 {
+			continue
+		}
+		if err := in.Prefill(schemes[n]); err != nil {
+			return 0, fmt.Errorf("input %d: %w", n, err)
+		}
+	}
+	return wtx.VSize(), nil
+}
+
+// Fee returns the fee paid by the transaction, prevAmounts holding the amount of the output spent
+// by each input, in order.
+func (tx *BtcTx) Fee(prevAmounts []BtcAmount) (BtcAmount, error) {
+	if len(prevAmounts) != len(tx.In) {
+		return 0, errors.New("Fee requires as many amounts as there are inputs")
+	}
+	var in, out BtcAmount
+	for _, amount := range prevAmounts {
+		in += amount
+	}
+	for _, o := range tx.Out {
+		out += o.Amount
+	}
+	if out > in {
+		return 0, errors.New("transaction outputs exceed its inputs")
+	}
+	return in - out, nil
+}
+
+// FeeRate returns the fee rate of the transaction in sat/vB, based on its current size. Use
+// [BtcTx.EstimateVSize] to compute the rate of a transaction before signing it.
+func (tx *BtcTx) FeeRate(prevAmounts []BtcAmount) (float64, error) {
+	fee, err := tx.Fee(prevAmounts)
+	if err != nil {
+		return 0, err
+	}
+	return float64(fee) / float64(tx.VSize()), nil
+}
+
+// btcFee returns the fee for the given weight at feeRate sat/vB, the size being rounded up to the
+// next vbyte as done by nodes
+func btcFee(weight int, feeRate float64) int64 {
+	return int64(math.Ceil(float64((weight+3)/4) * feeRate))
+}
+
+// weight returns the weight of the input, including its witness
+func (in *BtcTxInput) weight() int {
+	return 4*in.computeSize() + in.computeWitnessSize()
+}
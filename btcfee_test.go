@@ -0,0 +1,97 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestBtcTxWeight(t *testing.T) {
+	// signed BIP-143 native p2wpkh example: 233 bytes without witness, 343 bytes total
+	tx := &outscript.BtcTx{}
+	err := tx.UnmarshalBinary(must(hex.DecodeString("01000000000102fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f00000000494830450221008b9d1dc26ba6a9cb62127b02742fa9d754cd3bebf337f7a55d114c8e5cdd30be022040529b194ba3f9281a99f2b1c0a19c0489bc22ede944ccf4ecbab4cc618ef3ed01eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac000247304402203609e17b84f6a7d30c80bfa610b5b4542f32a8a0d5447a12fb1366d7f01cc44a0220573a954c4518331561406f90300e8f3358f51928d43c212a8caed02de67eebee0121025476c2e83188368da1ff3e292e7acafcdb3566bb0ad253f62fc70f07aeee635711000000")))
+	if err != nil {
+		t.Fatalf("failed to parse tx: %s", err)
+	}
+	if w := tx.Weight(); w != 1042 {
+		t.Errorf("unexpected weight %d", w)
+	}
+	if v := tx.VSize(); v != 261 {
+		t.Errorf("unexpected vsize %d", v)
+	}
+	if v := tx.ComputeSize(); v != 261 {
+		t.Errorf("unexpected computed size %d", v)
+	}
+	prevAmounts := []outscript.BtcAmount{625000000, 600000000}
+	if fee := must(tx.Fee(prevAmounts)); fee != 889210000 {
+		t.Errorf("unexpected fee %d", fee)
+	}
+	if rate := must(tx.FeeRate(prevAmounts)); rate != 889210000.0/261 {
+		t.Errorf("unexpected fee rate %f", rate)
+	}
+	if _, err := tx.Fee(prevAmounts[:1]); err == nil {
+		t.Errorf("expected error with missing amount")
+	}
+	if _, err := tx.Fee([]outscript.BtcAmount{1000, 1000}); err == nil {
+		t.Errorf("expected error with outputs exceeding inputs")
+	}
+
+	// without witness, weight is 4 times the size
+	tx.In[1].Witnesses = nil
+	if w := tx.Weight(); w != 4*len(tx.Bytes()) || w != 4*233 {
+		t.Errorf("unexpected weight %d without witness", w)
+	}
+}
+
+func TestBtcTxEstimateVSize(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	txid := outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f")))
+	schemes := []string{"p2pk", "p2pkh", "p2pukh", "p2wpkh", "p2sh:p2wpkh", "p2wsh:p2pk", "p2wsh:p2pkh", "p2tr"}
+
+	for _, scheme := range schemes {
+		tx := &outscript.BtcTx{Version: 2}
+		var keys []*outscript.BtcTxSign
+		var names []string
+		for n := range 3 {
+			tx.In = append(tx.In, &outscript.BtcTxInput{TXID: txid, Vout: uint32(n), Sequence: 0xffffffff})
+			keys = append(keys, &outscript.BtcTxSign{Key: key, Scheme: scheme, Amount: 10000})
+			names = append(names, scheme)
+		}
+		if err := tx.AddNetOutput("bitcoin", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", 25000); err != nil {
+			t.Fatalf("failed to add output: %s", err)
+		}
+		estimate, err := tx.EstimateVSize(names...)
+		if err != nil {
+			t.Fatalf("%s: failed to estimate: %s", scheme, err)
+		}
+		if err := tx.Sign(keys...); err != nil {
+			t.Fatalf("%s: failed to sign: %s", scheme, err)
+		}
+		// ECDSA signatures are 71 or 72 bytes long, the estimate uses the maximum
+		actual := tx.VSize()
+		if estimate < actual || estimate > actual+3 {
+			t.Errorf("%s: estimated %d vbytes, got %d", scheme, estimate, actual)
+		}
+		if scheme == "p2tr" && estimate != actual {
+			t.Errorf("p2tr: estimated %d vbytes, got %d", estimate, actual)
+		}
+		// signed inputs are kept as is
+		if v := must(tx.EstimateVSize("", "", "")); v != actual {
+			t.Errorf("%s: estimate of signed tx is %d, expected %d", scheme, v, actual)
+		}
+		rate := must(tx.FeeRate([]outscript.BtcAmount{10000, 10000, 10000}))
+		if fee := rate * float64(actual); fee < 4999 || fee > 5001 {
+			t.Errorf("%s: unexpected fee rate %f", scheme, rate)
+		}
+	}
+
+	tx := &outscript.BtcTx{In: []*outscript.BtcTxInput{{}}}
+	if _, err := tx.EstimateVSize("p2sh:multisig"); err == nil {
+		t.Errorf("expected error with unsupported scheme")
+	}
+	if _, err := tx.EstimateVSize(); err == nil {
+		t.Errorf("expected error with missing scheme")
+	}
+}
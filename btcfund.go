@@ -0,0 +1,244 @@
+package outscript
+
+import (
+	"cmp"
+	"crypto"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// BtcUtxo is an unspent output that can be used to fund a transaction with [FundBtcTx].
+type BtcUtxo struct {
+	TXID   Hex32
+	Vout   uint32
+	Amount BtcAmount
+	Script []byte        // scriptPubKey of the output
+	Key    crypto.Signer // key used to spend the output
+	Scheme string        // signing scheme, must be supported by [BtcTxInput.Prefill]
+}
+
+const (
+	btcDustRelayFee = 3      // sat/vB, used to compute the dust threshold
+	bnbMaxTries     = 100000 // branch-and-bound search limit
+)
+
+// FundBtcTx builds a transaction paying outputs using some of the given utxos, at the given fee rate
+// in sat/vB. Any amount left over is sent to changeAddress, unless it would be dust in which case it
+// is added to the fee. The selection first looks for a set of inputs not requiring change using a
+// branch-and-bound search, and otherwise falls back to the smallest sufficient single utxo or the
+// largest ones first.
+//
+// The returned keys match the transaction inputs and can be passed as is to [BtcTx.Sign] once the
+// transaction has been adjusted as needed (sequence, locktime, etc).
+func FundBtcTx(utxos []*BtcUtxo, outputs []*BtcTxOutput, feeRate float64, network, changeAddress string) (*BtcTx, []*BtcTxSign, error) {
+	if len(outputs) == 0 {
+		return nil, nil, errors.New("at least one output is required")
+	}
+	if feeRate < 0 || math.IsNaN(feeRate) || math.IsInf(feeRate, 0) {
+		return nil, nil, errors.New("invalid fee rate")
+	}
+	changeOut, err := ParseBitcoinBasedAddress(network, changeAddress)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid change address: %w", err)
+	}
+	change := &BtcTxOutput{Script: changeOut.Bytes()}
+
+	tx := &BtcTx{Version: 2}
+	var target int64
+	for n, out := range outputs {
+		if out.Amount < btcDustThreshold(out) {
+			return nil, nil, fmt.Errorf("output %d amount is below the dust threshold", n)
+		}
+		out = out.Dup()
+		out.N = n
+		tx.Out = append(tx.Out, out)
+		target += int64(out.Amount)
+	}
+
+	// compute the weight and effective value of each input
+	type candidate struct {
+		utxo  *BtcUtxo
+		in    *BtcTxInput
+		value int64 // amount minus the fee paying for the input
+	}
+	var candidates []*candidate
+	for n, u := range utxos {
+		in := &BtcTxInput{TXID: u.TXID, Vout: u.Vout, Sequence: 0xffffffff}
+		if err := in.Prefill(u.Scheme); err != nil {
+			return nil, nil, fmt.Errorf("utxo %d: %w", n, err)
+		}
+		c := &candidate{utxo: u, in: in, value: int64(u.Amount) - btcFee(in.weight(), feeRate)}
+		if c.value > 0 {
+			candidates = append(candidates, c)
+		}
+	}
+	// largest first, which also speeds up the branch-and-bound search
+	slices.SortStableFunc(candidates, func(a, b *candidate) int {
+		return cmp.Compare(b.value, a.value)
+	})
+
+	// fixed part of the transaction: version, input/output counts, outputs, locktime, plus one
+	// extra vbyte of margin for rounding
+	base := 4 * (4 + BtcVarInt(len(tx.Out)).Len() + 1 + 4 + 1)
+	for _, out := range tx.Out {
+		base += 4 * out.computeSize()
+	}
+	// the segwit marker and flag are only paid for if a selected input has a witness
+	segwitFee := btcFee(base+2, feeRate) - btcFee(base, feeRate)
+	target += btcFee(base, feeRate)
+
+	// cost of adding a change output and of spending it later
+	changeFee := btcFee(4*change.computeSize(), feeRate)
+	costOfChange := changeFee + btcFee(btcSpendWeight(change.Script), feeRate)
+	minChange := changeFee + int64(btcDustThreshold(change))
+
+	values := make([]int64, len(candidates))
+	for n, c := range candidates {
+		values[n] = c.value
+	}
+	sel := selectBtcInputs(values, target, costOfChange, minChange)
+	if slices.ContainsFunc(sel, func(n int) bool { return len(candidates[n].in.Witnesses) > 0 }) {
+		var sum int64
+		for _, n := range sel {
+			sum += values[n]
+		}
+		if sum < target+segwitFee {
+			sel = selectBtcInputs(values, target+segwitFee, costOfChange, minChange)
+		}
+	}
+	if sel == nil {
+		return nil, nil, errors.New("insufficient funds")
+	}
+
+	var keys []*BtcTxSign
+	var inSum, outSum int64
+	for _, n := range sel {
+		c := candidates[n]
+		tx.In = append(tx.In, c.in)
+		keys = append(keys, &BtcTxSign{Key: c.utxo.Key, Scheme: c.utxo.Scheme, Amount: c.utxo.Amount, PrevScript: c.utxo.Script})
+		inSum += int64(c.utxo.Amount)
+	}
+	for _, out := range tx.Out {
+		outSum += int64(out.Amount)
+	}
+
+	// add change and compute the final fee on the actual transaction
+	change.N = len(tx.Out)
+	tx.Out = append(tx.Out, change)
+	fee := btcFee(tx.Weight(), feeRate)
+	if left := inSum - outSum - fee; left >= int64(btcDustThreshold(change)) {
+		change.Amount = BtcAmount(left)
+	} else {
+		tx.Out = tx.Out[:len(tx.Out)-1]
+		if inSum-outSum < btcFee(tx.Weight(), feeRate) {
+			return nil, nil, errors.New("insufficient funds")
+		}
+	}
+
+	tx.ClearInputs()
+	return tx, keys, nil
+}
+
+// selectBtcInputs returns the indexes of values to use to reach target, see [FundBtcTx]
+func selectBtcInputs(values []int64, target, costOfChange, minChange int64) []int {
+	if sel := selectBnB(values, target, costOfChange); sel != nil {
+		return sel
+	}
+	return selectLargestFirst(values, target, minChange)
+}
+
+// btcSpendWeight returns the estimated weight of an input spending script
+func btcSpendWeight(script []byte) int {
+	in := &BtcTxInput{}
+	if in.Prefill(btcScriptType(script)) != nil {
+		in.Prefill("p2wpkh") // unknown, assume the most common
+	}
+	return in.weight()
+}
+
+// btcDustThreshold returns the minimum amount of out for it to be relayed, as computed by bitcoin core
+func btcDustThreshold(out *BtcTxOutput) BtcAmount {
+	size := out.computeSize()
+	if _, _, ok := witnessProgram(out.Script); ok {
+		size += 32 + 4 + 1 + 107/4 + 4
+	} else {
+		size += 32 + 4 + 1 + 107 + 4
+	}
+	return BtcAmount(size * btcDustRelayFee)
+}
+
+// selectBnB returns the indexes of values (sorted in decreasing order) whose sum is between target
+// and target+costOfChange, minimizing the excess, or nil if no such selection was found
+func selectBnB(values []int64, target, costOfChange int64) []int {
+	var remaining int64
+	for _, v := range values {
+		remaining += v
+	}
+	if remaining < target {
+		return nil
+	}
+
+	var best, cur []int
+	bestExcess := costOfChange + 1
+	tries := 0
+	var search func(i int, sum, remaining int64)
+	search = func(i int, sum, remaining int64) {
+		tries++
+		switch {
+		case tries > bnbMaxTries || sum > target+costOfChange:
+			return
+		case sum >= target:
+			if sum-target < bestExcess {
+				best, bestExcess = slices.Clone(cur), sum-target
+			}
+			return
+		case i == len(values) || sum+remaining < target:
+			return
+		}
+		cur = append(cur, i)
+		search(i+1, sum+values[i], remaining-values[i])
+		cur = cur[:len(cur)-1]
+		// once a value is excluded, selections using an equal value instead were already explored
+		remaining -= values[i]
+		j := i + 1
+		for j < len(values) && values[j] == values[i] {
+			remaining -= values[j]
+			j++
+		}
+		search(j, sum, remaining)
+	}
+	search(0, 0, remaining)
+	return best
+}
+
+// selectLargestFirst returns the indexes of values (sorted in decreasing order) covering target
+// plus minChange, using the smallest single value that does or the largest values first. If this
+// is not possible, it selects enough to cover target alone.
+func selectLargestFirst(values []int64, target, minChange int64) []int {
+	for i := len(values) - 1; i >= 0; i-- {
+		if values[i] >= target+minChange {
+			return []int{i}
+		}
+	}
+	for _, goal := range []int64{target + minChange, target} {
+		var sel []int
+		var sum int64
+		for i, v := range values {
+			sel = append(sel, i)
+			sum += v
+			if sum >= goal {
+				// drop the smallest values that are not needed
+				for j := len(sel) - 1; j >= 0; j-- {
+					if sum-values[sel[j]] >= goal {
+						sum -= values[sel[j]]
+						sel = slices.Delete(sel, j, j+1)
+					}
+				}
+				return sel
+			}
+		}
+	}
+	return nil
+}
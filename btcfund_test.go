@@ -0,0 +1,119 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestFundBtcTx(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	txid := outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f")))
+	dest := must(outscript.ParseBitcoinBasedAddress("bitcoin", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq")).Bytes()
+	change := "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"
+
+	utxos := func(scheme string, amounts ...outscript.BtcAmount) []*outscript.BtcUtxo {
+		var res []*outscript.BtcUtxo
+		for n, amount := range amounts {
+			res = append(res, &outscript.BtcUtxo{TXID: txid, Vout: uint32(n), Amount: amount, Script: must(outscript.New(key.PubKey()).Generate(scheme)), Key: key, Scheme: scheme})
+		}
+		return res
+	}
+	// fund returns the funded tx after signing it, along with the fee paid
+	fund := func(u []*outscript.BtcUtxo, amount outscript.BtcAmount, feeRate float64) (*outscript.BtcTx, int64) {
+		t.Helper()
+		tx, keys, err := outscript.FundBtcTx(u, []*outscript.BtcTxOutput{{Amount: amount, Script: dest}}, feeRate, "bitcoin", change)
+		if err != nil {
+			t.Fatalf("failed to fund %d: %s", amount, err)
+		}
+		if len(keys) != len(tx.In) {
+			t.Fatalf("got %d keys for %d inputs", len(keys), len(tx.In))
+		}
+		var prevOuts []*outscript.BtcTxOutput
+		fee := int64(0)
+		for n, in := range tx.In {
+			utxo := u[in.Vout]
+			if keys[n].Amount != utxo.Amount {
+				t.Errorf("key %d does not match input", n)
+			}
+			prevOuts = append(prevOuts, &outscript.BtcTxOutput{Amount: utxo.Amount, Script: utxo.Script})
+			fee += int64(utxo.Amount)
+		}
+		for _, out := range tx.Out {
+			fee -= int64(out.Amount)
+		}
+		if err := tx.Sign(keys...); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		if err := tx.Verify(prevOuts); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+		if min := float64(tx.ComputeSize()) * feeRate; float64(fee) < min {
+			t.Errorf("fee %d is below %f for %d vbytes", fee, min, tx.ComputeSize())
+		}
+		return tx, fee
+	}
+
+	// 50000+30000 matches the amount and fees closely enough to avoid change
+	tx, fee := fund(utxos("p2wpkh", 100000, 50000, 30000, 20000), 79800, 1)
+	if len(tx.In) != 2 || len(tx.Out) != 1 || tx.In[0].Vout != 1 || tx.In[1].Vout != 2 {
+		t.Errorf("unexpected selection: %d inputs, %d outputs", len(tx.In), len(tx.Out))
+	}
+	if fee != 200 {
+		t.Errorf("unexpected fee %d", fee)
+	}
+
+	// no exact match, change goes to the change address
+	tx, fee = fund(utxos("p2wpkh", 100000, 50000, 30000, 20000), 120000, 2)
+	if len(tx.Out) != 2 || hex.EncodeToString(tx.Out[1].Script) != "0014751e76e8199196d454941c45d1b3a323f1433bd6" {
+		t.Fatalf("expected change output")
+	}
+	if fee > int64(tx.ComputeSize())*2+4 {
+		t.Errorf("fee %d is too high for %d vbytes", fee, tx.ComputeSize())
+	}
+
+	// the smallest sufficient utxo is used
+	tx, _ = fund(utxos("p2pkh", 10000, 500000, 200000, 30000), 150000, 5)
+	if len(tx.In) != 1 || tx.In[0].Vout != 2 {
+		t.Errorf("expected the 200000 utxo to be used")
+	}
+
+	// taproot and nested segwit inputs
+	fund(utxos("p2tr", 100000, 20000), 110000, 3)
+	fund(utxos("p2sh:p2wpkh", 100000, 20000), 110000, 3)
+
+	// change below the dust threshold is left to miners
+	tx, fee = fund(utxos("p2wpkh", 100000), 99600, 1)
+	if len(tx.Out) != 1 || fee != 400 {
+		t.Errorf("expected dust change to be dropped, got %d outputs and fee %d", len(tx.Out), fee)
+	}
+
+	// utxos worth less than the fee to spend them are ignored
+	tx, _ = fund(utxos("p2pkh", 100000, 500), 50000, 10)
+	if len(tx.In) != 1 {
+		t.Errorf("expected uneconomic utxo to be skipped")
+	}
+
+	// the segwit marker is only paid for when a segwit input is selected: 98090 plus the fees
+	// exactly match the legacy utxo
+	mixed := append(utxos("p2pkh", 100000), utxos("p2wpkh", 0, 1000000)[1])
+	tx, fee = fund(mixed, 98090, 10)
+	if len(tx.In) != 1 || tx.In[0].Vout != 0 || len(tx.Out) != 1 || fee != 1910 {
+		t.Errorf("unexpected selection from mixed utxos: %d inputs, %d outputs, fee %d", len(tx.In), len(tx.Out), fee)
+	}
+
+	if _, _, err := outscript.FundBtcTx(utxos("p2wpkh", 10000, 20000), []*outscript.BtcTxOutput{{Amount: 30000, Script: dest}}, 1, "bitcoin", change); err == nil {
+		t.Errorf("expected insufficient funds error")
+	}
+	if _, _, err := outscript.FundBtcTx(utxos("p2wpkh", 10000), []*outscript.BtcTxOutput{{Amount: 100, Script: dest}}, 1, "bitcoin", change); err == nil {
+		t.Errorf("expected dust output error")
+	}
+	if _, _, err := outscript.FundBtcTx(utxos("p2wpkh", 10000), []*outscript.BtcTxOutput{{Amount: 5000, Script: dest}}, 1, "litecoin", change); err == nil {
+		t.Errorf("expected invalid change address error")
+	}
+	if _, _, err := outscript.FundBtcTx([]*outscript.BtcUtxo{{Amount: 10000, Key: key, Scheme: "p2sh:multisig"}}, []*outscript.BtcTxOutput{{Amount: 5000, Script: dest}}, 1, "bitcoin", change); err == nil {
+		t.Errorf("expected unsupported scheme error")
+	}
+}
@@ -0,0 +1,200 @@
+package outscript
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/BottleFmt/gobottle"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+const btcMessageMagic = "Bitcoin Signed Message:\n"
+
+// BtcMessageHash returns the hash signed by legacy "Bitcoin Signed Message" signatures.
+func BtcMessageHash(message string) []byte {
+	buf := slices.Concat(BtcVarInt(len(btcMessageMagic)).Bytes(), []byte(btcMessageMagic), BtcVarInt(len(message)).Bytes(), []byte(message))
+	return gobottle.Hash(buf, sha256.New, sha256.New)
+}
+
+// btcMessageHeaders lists the BIP-137 header byte of each scheme, before adding the recovery id
+var btcMessageHeaders = map[string]byte{
+	"p2pukh":      27,
+	"p2pkh":       31,
+	"p2sh:p2wpkh": 35,
+	"p2wpkh":      39,
+}
+
+// BtcSignMessage signs message with key as a BIP-137 compact signature, returned in base64. The
+// scheme is one of "p2pkh", "p2pukh", "p2sh:p2wpkh" or "p2wpkh" and defines the header byte.
+func BtcSignMessage(key crypto.Signer, scheme, message string) (string, error) {
+	header, ok := btcMessageHeaders[scheme]
+	if !ok {
+		return "", fmt.Errorf("unsupported scheme %s for message signing", scheme)
+	}
+	pub, ok := key.Public().(*secp256k1.PublicKey)
+	if !ok {
+		return "", errors.New("message signing requires a secp256k1 key")
+	}
+	h := BtcMessageHash(message)
+	sig, err := key.Sign(rand.Reader, h, crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	sigO, err := secp256k1.ParseDERSignature(sig)
+	if err != nil {
+		return "", err
+	}
+	if !sigO.BruteforceRecoveryCode(h, pub) {
+		return "", errors.New("unable to find signature recovery code")
+	}
+	r, s, v := sigO.Export()
+	buf := make([]byte, 65)
+	buf[0] = header + v
+	r.FillBytes(buf[1:33])
+	s.FillBytes(buf[33:])
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// Bip322ToSpend returns the virtual BIP-322 transaction paying to the challenge script, which
+// commits to message.
+func Bip322ToSpend(challenge []byte, message string) *BtcTx {
+	h := taggedHash("BIP0322-signed-message", []byte(message))
+	in := &BtcTxInput{Vout: 0xffffffff, Script: slices.Concat([]byte{0}, PushBytes(h))}
+	return &BtcTx{
+		In:  []*BtcTxInput{in},
+		Out: []*BtcTxOutput{{Script: challenge}},
+	}
+}
+
+// Bip322ToSign returns the unsigned virtual BIP-322 transaction spending toSpend, which holds the
+// proof once signed.
+func Bip322ToSign(toSpend *BtcTx) *BtcTx {
+	txid, _ := toSpend.Hash()
+	return &BtcTx{
+		In:  []*BtcTxInput{{TXID: Hex32(txid)}},
+		Out: []*BtcTxOutput{{Script: []byte{0x6a}}}, // OP_RETURN
+	}
+}
+
+// BtcSignMessageBip322 signs message with a BIP-322 proof for the output matching k, returned in
+// base64. The simple format only holds the witness and requires a native segwit output, the full
+// format holds the whole signed transaction and can also be used for legacy outputs.
+func BtcSignMessageBip322(message string, full bool, k *BtcTxSign) (string, error) {
+	if k.Key == nil {
+		return "", errors.New("a key is required for message signing")
+	}
+	// work on a copy, the amount and previous script are specific to the message transaction
+	nk := *k
+	k = &nk
+	k.Amount = 0
+	prevOuts, err := signPrevOuts([]*BtcTxSign{k})
+	if err != nil {
+		return "", err
+	}
+	k.PrevScript = prevOuts[0].Script
+	toSign := Bip322ToSign(Bip322ToSpend(k.PrevScript, message))
+	if err := toSign.Sign(k); err != nil {
+		return "", err
+	}
+	if full {
+		return base64.StdEncoding.EncodeToString(toSign.exportBytes(toSign.HasWitness())), nil
+	}
+	in := toSign.In[0]
+	if len(in.Script) != 0 {
+		return "", fmt.Errorf("scheme %s requires a full BIP-322 signature", k.Scheme)
+	}
+	buf := BtcVarInt(len(in.Witnesses)).Bytes()
+	for _, w := range in.Witnesses {
+		buf = slices.Concat(buf, BtcVarInt(len(w)).Bytes(), w)
+	}
+	return base64.StdEncoding.EncodeToString(buf), nil
+}
+
+// BtcVerifyMessage checks signature of message for address, which can be any address accepted by
+// [ParseBitcoinBasedAddress] for network. Signatures can use the BIP-137 compact format, or be a
+// BIP-322 simple or full proof. Full proofs with additional inputs are not supported.
+func BtcVerifyMessage(network, address, message, signature string) error {
+	out, err := ParseBitcoinBasedAddress(network, address)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if len(sig) == 65 && sig[0] >= 27 && sig[0] <= 42 {
+		return verifyBtcMessageCompact(out.Bytes(), message, sig)
+	}
+
+	toSpend := Bip322ToSpend(out.Bytes(), message)
+	toSign := Bip322ToSign(toSpend)
+	if witness, ok := parseBip322Witness(sig); ok {
+		toSign.In[0].Witnesses = witness
+	} else {
+		if err := toSign.UnmarshalBinary(sig); err != nil {
+			return fmt.Errorf("invalid BIP-322 signature: %w", err)
+		}
+		txid, _ := toSpend.Hash()
+		if len(toSign.In) != 1 || toSign.In[0].TXID != Hex32(txid) || toSign.In[0].Vout != 0 {
+			return errors.New("BIP-322 signature must spend the message transaction as its only input")
+		}
+		if len(toSign.Out) != 1 || toSign.Out[0].Amount != 0 || !bytes.Equal(toSign.Out[0].Script, []byte{0x6a}) {
+			return errors.New("BIP-322 signature must have a single empty OP_RETURN output")
+		}
+	}
+	return toSign.VerifyInputFlags(0, toSpend.Out, BtcVerifyAll)
+}
+
+// verifyBtcMessageCompact checks a BIP-137 signature against the output script. As some wallets
+// use the p2pkh header for segwit addresses, any header matching a compressed key is accepted
+// for all the single key schemes.
+func verifyBtcMessageCompact(script []byte, message string, sig []byte) error {
+	r := new(secp256k1.ModNScalar)
+	if overflow := r.SetByteSlice(sig[1:33]); overflow {
+		return errors.New("invalid signature: R >= group order")
+	}
+	s := new(secp256k1.ModNScalar)
+	if overflow := s.SetByteSlice(sig[33:]); overflow {
+		return errors.New("invalid signature: S >= group order")
+	}
+	header := sig[0] - 27
+	pub, err := secp256k1.NewSignatureWithRecoveryCode(r, s, header&3).RecoverPublicKey(BtcMessageHash(message))
+	if err != nil {
+		return err
+	}
+	schemes := []string{"p2pkh", "p2sh:p2wpkh", "p2wpkh"}
+	if header < 4 {
+		schemes = []string{"p2pukh"}
+	}
+	for _, scheme := range schemes {
+		if v, err := New(pub).Generate(scheme); err == nil && bytes.Equal(v, script) {
+			return nil
+		}
+	}
+	return errors.New("signature does not match the address")
+}
+
+// parseBip322Witness parses a BIP-322 simple signature, a witness stack that must use all of buf
+func parseBip322Witness(buf []byte) ([][]byte, bool) {
+	r := bytes.NewReader(buf)
+	h := &readHelper{R: r}
+	var cnt BtcVarInt
+	h.readTo(&cnt)
+	if h.Err != nil || uint64(cnt) > uint64(len(buf)) {
+		return nil, false
+	}
+	res := make([][]byte, cnt)
+	for n := range res {
+		res[n] = h.readVarBuf()
+	}
+	if h.Err != nil || r.Len() != 0 {
+		return nil, false
+	}
+	return res, true
+}
@@ -0,0 +1,139 @@
+package outscript_test
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+)
+
+func TestBip322Vectors(t *testing.T) {
+	// test vectors from BIP-322
+	for msg, exp := range map[string]string{
+		"":            "c90c269c4f8fcbe6880f72a721ddfbf1914268a794cbb21cfafee13770ae19f1",
+		"Hello World": "f0eb03b1a75ac6d9847f55c624a99169b5dccba2a31f5b23bea77ba270de0a7a",
+	} {
+		toSpend := outscript.Bip322ToSpend(nil, msg)
+		if h := hex.EncodeToString(toSpend.In[0].Script[2:]); h != exp {
+			t.Errorf("bad message hash for %q: %s", msg, h)
+		}
+	}
+
+	challenge := must(outscript.ParseBitcoinBasedAddress("bitcoin", "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l")).Bytes()
+	for msg, exp := range map[string][2]string{
+		"":            {"c5680aa69bb8d860bf82d4e9cd3504b55dde018de765a91bb566283c545a99a7", "1e9654e951a5ba44c8604c4de6c67fd78a27e81dcadcfe1edf638ba3aaebaed6"},
+		"Hello World": {"b79d196740ad5217771c1098fc4a4b51e0535c32236c71f1ea4d61a2d603352b", "88737ae86f2077145f93cc4b153ae9a1cb8d56afa511988c149c5c8c9d93bddf"},
+	} {
+		toSpend := outscript.Bip322ToSpend(challenge, msg)
+		toSign := outscript.Bip322ToSign(toSpend)
+		if h := hex.EncodeToString(must(toSpend.Hash())); h != exp[0] {
+			t.Errorf("bad to_spend txid for %q: %s", msg, h)
+		}
+		if h := hex.EncodeToString(must(toSign.Hash())); h != exp[1] {
+			t.Errorf("bad to_sign txid for %q: %s", msg, h)
+		}
+	}
+
+	for _, v := range [][3]string{
+		{"bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", "", "AkcwRAIgM2gBAQqvZX15ZiysmKmQpDrG83avLIT492QBzLnQIxYCIBaTpOaD20qRlEylyxFSeEA2ba9YOixpX8z46TSDtS40ASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI="},
+		{"bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", "Hello World", "AkcwRAIgZRfIY3p7/DoVTty6YZbWS71bc5Vct9p9Fia83eRmw2QCICK/ENGfwLtptFluMGs2KsqoNSk89pO7F29zJLUx9a/sASECx/EgAxlkQpQ9hYjgGu6EBCPMVPwVIVJqO4XCsMvViHI="},
+		{"bc1ppv609nr0vr25u07u95waq5lucwfm6tde4nydujnu8npg4q75mr5sxq8lt3", "Hello World", "AUHd69PrJQEv+oKTfZ8l+WROBHuy9HKrbFCJu7U1iK2iiEy1vMU5EfMtjc+VSHM7aU0SDbak5IUZRVno2P5mjSafAQ=="},
+	} {
+		if err := outscript.BtcVerifyMessage("bitcoin", v[0], v[1], v[2]); err != nil {
+			t.Errorf("failed to verify %q for %s: %s", v[1], v[0], err)
+		}
+		if err := outscript.BtcVerifyMessage("bitcoin", v[0], v[1]+"!", v[2]); err == nil {
+			t.Errorf("expected error verifying another message for %s", v[0])
+		}
+	}
+}
+
+func TestBtcSignMessage(t *testing.T) {
+	key, _ := must2(outscript.ParseWIF("bitcoin", "L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k"))
+
+	// signature for "Hello World", the header byte depending on the scheme
+	sig := "HOW2xi+ebJLeBtr674l4QH76dqDoVjLV80R9EFKFQX5rBrlCXPIZaYs8Yuayg0ZqjyiCbLy9pzZIS7JWT65/nsU="
+	for n, scheme := range []string{"p2pukh", "p2pkh", "p2sh:p2wpkh", "p2wpkh"} {
+		exp := string("HIJK"[n]) + sig[1:]
+		if s := must(outscript.BtcSignMessage(key, scheme, "Hello World")); s != exp {
+			t.Errorf("bad %s signature %s", scheme, s)
+		}
+		addr := must(outscript.New(key.PubKey()).Address(scheme, "bitcoin"))
+		if err := outscript.BtcVerifyMessage("bitcoin", addr, "Hello World", exp); err != nil {
+			t.Errorf("failed to verify %s signature: %s", scheme, err)
+		}
+	}
+
+	// compressed key headers are accepted for all segwit addresses, but not for p2pukh
+	if err := outscript.BtcVerifyMessage("auto", "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", "", "H0Gm/ylcjvGmj29D/r6ASHKKWAyGA/MEU/Eh+IhFw3ZxFxy9b/dJ8/XFsiutCV8DWlFGnO95m/KoZwFPJTF1qaM="); err != nil {
+		t.Errorf("failed to verify p2pkh header signature for p2wpkh: %s", err)
+	}
+	uncomp := must(outscript.New(key.PubKey()).Address("p2pukh", "bitcoin"))
+	if err := outscript.BtcVerifyMessage("bitcoin", uncomp, "", "H0Gm/ylcjvGmj29D/r6ASHKKWAyGA/MEU/Eh+IhFw3ZxFxy9b/dJ8/XFsiutCV8DWlFGnO95m/KoZwFPJTF1qaM="); err == nil {
+		t.Errorf("expected error for compressed signature on uncompressed address")
+	}
+	if err := outscript.BtcVerifyMessage("bitcoin", "1GAehh7TsJAHuUAeKZcXf5CnwuGuGgyX2S", "", "H0Gm/ylcjvGmj29D/r6ASHKKWAyGA/MEU/Eh+IhFw3ZxFxy9b/dJ8/XFsiutCV8DWlFGnO95m/KoZwFPJTF1qaM="); err == nil {
+		t.Errorf("expected error for another address")
+	}
+	if _, err := outscript.BtcSignMessage(key, "p2tr", ""); err == nil {
+		t.Errorf("expected error for unsupported scheme")
+	}
+}
+
+func TestBtcSignMessageBip322(t *testing.T) {
+	key, _ := must2(outscript.ParseWIF("bitcoin", "L3VFeEujGtevx9w18HD1fhRbCH67Az2dpCymeRE1SoPK6XQtaN2k"))
+	key2 := must(outscript.NewHDKey(make([]byte, 32))).PrivKey()
+	ms := must(outscript.NewMultisig(1, key.PubKey(), key2.PubKey()))
+
+	for _, k := range []*outscript.BtcTxSign{
+		{Key: key, Scheme: "p2wpkh"},
+		{Key: key, Scheme: "p2tr"},
+		{Key: key, Scheme: "p2sh:p2wpkh"},
+		{Key: key, Scheme: "p2pkh"},
+		{Key: key, Scheme: "p2pukh"},
+		{Key: key2, Scheme: "p2wsh:multisig", Multisig: ms},
+	} {
+		var addr string
+		if k.Multisig != nil {
+			addr = must(ms.Address("p2wsh", "bitcoin"))
+		} else {
+			addr = must(outscript.New(key.PubKey()).Address(k.Scheme, "bitcoin"))
+		}
+		for _, full := range []bool{false, true} {
+			sig, err := outscript.BtcSignMessageBip322("Hello World", full, k)
+			if err != nil {
+				if !full && (k.Scheme == "p2sh:p2wpkh" || k.Scheme == "p2pkh" || k.Scheme == "p2pukh") {
+					// simple signatures require a native segwit output
+					continue
+				}
+				t.Errorf("failed to sign %s: %s", k.Scheme, err)
+				continue
+			}
+			if err := outscript.BtcVerifyMessage("bitcoin", addr, "Hello World", sig); err != nil {
+				t.Errorf("failed to verify %s signature: %s", k.Scheme, err)
+			}
+			if err := outscript.BtcVerifyMessage("bitcoin", addr, "Hello world", sig); err == nil {
+				t.Errorf("expected error verifying another message with %s signature", k.Scheme)
+			}
+		}
+	}
+
+	// the caller's signing parameters are left unchanged
+	k := &outscript.BtcTxSign{Key: key, Scheme: "p2wpkh", Amount: 5000}
+	must(outscript.BtcSignMessageBip322("Hello World", false, k))
+	if k.Amount != 5000 || k.PrevScript != nil {
+		t.Errorf("signing parameters were modified")
+	}
+
+	// full proofs must spend the message transaction to a single OP_RETURN output
+	sig := must(outscript.BtcSignMessageBip322("Hello World", true, &outscript.BtcTxSign{Key: key, Scheme: "p2wpkh"}))
+	tx := &outscript.BtcTx{}
+	if err := tx.UnmarshalBinary(must(base64.StdEncoding.DecodeString(sig))); err != nil {
+		t.Fatalf("failed to parse signature: %s", err)
+	}
+	tx.Out = append(tx.Out, &outscript.BtcTxOutput{Script: []byte{0x6a}})
+	if err := outscript.BtcVerifyMessage("bitcoin", "bc1q9vza2e8x573nczrlzms0wvx3gsqjx7vavgkx0l", "Hello World", base64.StdEncoding.EncodeToString(tx.Bytes())); err == nil {
+		t.Errorf("expected error for additional output")
+	}
+}
@@ -0,0 +1,164 @@
+package outscript
+
+import (
+	"cmp"
+	"crypto"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+// btcIncrementalRelayFee is the minimum fee rate increase in sat/vB for a replacement, as used by
+// bitcoin core
+const btcIncrementalRelayFee = 1
+
+// SignalsRBF returns true if the transaction signals BIP-125 replaceability, that is if any of its
+// inputs has a sequence lower than 0xfffffffe.
+func (tx *BtcTx) SignalsRBF() bool {
+	for _, in := range tx.In {
+		if in.Sequence < 0xfffffffe {
+			return true
+		}
+	}
+	return false
+}
+
+// BumpFee returns a BIP-125 replacement of tx paying feeRate sat/vB. keys hold the signing
+// parameters of the inputs of tx, with Scheme and Amount set. The fee increase is taken from the
+// output at index change, which is removed if it would become dust, and the given utxos are added
+// as inputs as needed if that is not enough. Pass -1 as change if tx has no change output, in which
+// case no utxo can be added.
+//
+// The replacement pays at least the fee of tx plus the incremental relay fee for its own size, as
+// required by BIP-125. Added utxos must be confirmed. The returned transaction and keys are ready
+// for signing with [BtcTx.Sign].
+func (tx *BtcTx) BumpFee(keys []*BtcTxSign, feeRate float64, change int, utxos ...*BtcUtxo) (*BtcTx, []*BtcTxSign, error) {
+	if len(keys) != len(tx.In) {
+		return nil, nil, errors.New("BumpFee requires as many keys as there are inputs")
+	}
+	if !tx.SignalsRBF() {
+		return nil, nil, errors.New("transaction does not signal replaceability")
+	}
+	if change >= len(tx.Out) || change < -1 {
+		return nil, nil, fmt.Errorf("invalid change output index %d", change)
+	}
+	if feeRate < 0 || math.IsNaN(feeRate) || math.IsInf(feeRate, 0) {
+		return nil, nil, errors.New("invalid fee rate")
+	}
+
+	var schemes []string
+	var prevAmounts []BtcAmount
+	for _, k := range keys {
+		schemes = append(schemes, k.Scheme)
+		prevAmounts = append(prevAmounts, k.Amount)
+	}
+	oldFee, err := tx.Fee(prevAmounts)
+	if err != nil {
+		return nil, nil, err
+	}
+	oldSize, err := tx.EstimateVSize(schemes...)
+	if err != nil {
+		return nil, nil, err
+	}
+	if feeRate <= float64(oldFee)/float64(oldSize) {
+		return nil, nil, errors.New("replacement fee rate must be higher than the original")
+	}
+
+	res := tx.Dup()
+	res.ClearInputs()
+	var resKeys []*BtcTxSign
+	var inSum, outSum BtcAmount
+	for _, k := range keys {
+		nk := *k
+		resKeys = append(resKeys, &nk)
+		inSum += k.Amount
+	}
+	for n, out := range res.Out {
+		if n != change {
+			outSum += out.Amount
+		}
+	}
+	var changeOut *BtcTxOutput
+	if change >= 0 {
+		changeOut = res.Out[change]
+	}
+
+	// add the largest utxos first, keeping the number of added inputs low
+	utxos = slices.Clone(utxos)
+	slices.SortStableFunc(utxos, func(a, b *BtcUtxo) int {
+		return cmp.Compare(b.Amount, a.Amount)
+	})
+
+	for {
+		size, err := res.EstimateVSize(schemes...)
+		if err != nil {
+			return nil, nil, err
+		}
+		fee := max(btcFee(4*size, feeRate), int64(oldFee)+btcFee(4*size, btcIncrementalRelayFee))
+		left := int64(inSum) - int64(outSum) - fee
+		if changeOut == nil {
+			if left < 0 {
+				return nil, nil, errors.New("insufficient funds to bump the fee")
+			}
+			break
+		}
+		if left >= int64(btcDustThreshold(changeOut)) {
+			changeOut.Amount = BtcAmount(left)
+			break
+		}
+		if left < 0 && len(utxos) > 0 {
+			u := utxos[0]
+			utxos = utxos[1:]
+			res.In = append(res.In, &BtcTxInput{TXID: u.TXID, Vout: u.Vout, Sequence: 0xfffffffd})
+			resKeys = append(resKeys, &BtcTxSign{Key: u.Key, Scheme: u.Scheme, Amount: u.Amount, PrevScript: u.Script})
+			schemes = append(schemes, u.Scheme)
+			inSum += u.Amount
+			continue
+		}
+		// the change would be dust, give it to the miners
+		res.Out = slices.DeleteFunc(res.Out, func(out *BtcTxOutput) bool { return out == changeOut })
+		for n, out := range res.Out {
+			out.N = n
+		}
+		changeOut = nil
+	}
+	return res, resKeys, nil
+}
+
+// Cpfp builds a child transaction spending output vout of tx to address, so that both
+// transactions together pay feeRate sat/vB. parentFee is the fee paid by tx, which must be signed.
+// The child signals replaceability and is returned along with its signing parameters.
+func (tx *BtcTx) Cpfp(parentFee BtcAmount, vout int, key crypto.Signer, scheme string, feeRate float64, network, address string) (*BtcTx, []*BtcTxSign, error) {
+	if vout < 0 || vout >= len(tx.Out) {
+		return nil, nil, fmt.Errorf("invalid output index %d", vout)
+	}
+	if feeRate < 0 || math.IsNaN(feeRate) || math.IsInf(feeRate, 0) {
+		return nil, nil, errors.New("invalid fee rate")
+	}
+	txid, err := tx.Hash()
+	if err != nil {
+		return nil, nil, err
+	}
+	prev := tx.Out[vout]
+
+	child := &BtcTx{Version: 2}
+	child.In = append(child.In, &BtcTxInput{TXID: Hex32(txid), Vout: uint32(vout), Sequence: 0xfffffffd})
+	if err := child.AddNetOutput(network, address, 0); err != nil {
+		return nil, nil, err
+	}
+	size, err := child.EstimateVSize(scheme)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// the child pays for the parent's missing fee, and at least its own fee
+	fee := max(btcFee(4*(tx.VSize()+size), feeRate)-int64(parentFee), btcFee(4*size, feeRate))
+	left := int64(prev.Amount) - fee
+	if left < int64(btcDustThreshold(child.Out[0])) {
+		return nil, nil, errors.New("output amount is too low to pay for the fee")
+	}
+	child.Out[0].Amount = BtcAmount(left)
+
+	return child, []*BtcTxSign{{Key: key, Scheme: scheme, Amount: prev.Amount, PrevScript: prev.Script}}, nil
+}
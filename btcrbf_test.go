@@ -0,0 +1,141 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestBtcTxSignalsRBF(t *testing.T) {
+	tx := &outscript.BtcTx{Version: 2}
+	tx.In = append(tx.In, &outscript.BtcTxInput{Sequence: 0xffffffff}, &outscript.BtcTxInput{Sequence: 0xfffffffe})
+	if tx.SignalsRBF() {
+		t.Errorf("final sequences should not signal RBF")
+	}
+	tx.In[1].Sequence = 0xfffffffd
+	if !tx.SignalsRBF() {
+		t.Errorf("expected RBF signal")
+	}
+}
+
+func TestBtcTxBumpFee(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	txid := outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f")))
+	dest := must(outscript.ParseBitcoinBasedAddress("bitcoin", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq")).Bytes()
+	script := must(outscript.New(key.PubKey()).Generate("p2wpkh"))
+	utxo := func(vout uint32, amount outscript.BtcAmount, scheme string) *outscript.BtcUtxo {
+		return &outscript.BtcUtxo{TXID: txid, Vout: vout, Amount: amount, Script: must(outscript.New(key.PubKey()).Generate(scheme)), Key: key, Scheme: scheme}
+	}
+
+	// signs tx and returns its fee and fee rate
+	sign := func(tx *outscript.BtcTx, keys []*outscript.BtcTxSign) (int64, float64) {
+		t.Helper()
+		if err := tx.Sign(keys...); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+		var prevOuts []*outscript.BtcTxOutput
+		var amounts []outscript.BtcAmount
+		for _, k := range keys {
+			prevOuts = append(prevOuts, &outscript.BtcTxOutput{Amount: k.Amount, Script: must(outscript.New(key.PubKey()).Generate(k.Scheme))})
+			amounts = append(amounts, k.Amount)
+		}
+		if err := tx.Verify(prevOuts); err != nil {
+			t.Fatalf("failed to verify: %s", err)
+		}
+		return int64(must(tx.Fee(amounts))), must(tx.FeeRate(amounts))
+	}
+
+	orig, keys, err := outscript.FundBtcTx([]*outscript.BtcUtxo{utxo(0, 100000, "p2wpkh")}, []*outscript.BtcTxOutput{{Amount: 50000, Script: dest}}, 2, "bitcoin", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4")
+	if err != nil {
+		t.Fatalf("failed to fund: %s", err)
+	}
+	if _, _, err := orig.BumpFee(keys, 10, 1); err == nil {
+		t.Errorf("expected error bumping a non replaceable tx")
+	}
+	orig.In[0].Sequence = 0xfffffffd
+	oldFee, _ := sign(orig, keys)
+
+	// the change output pays for the increase
+	tx, k, err := orig.BumpFee(keys, 10, 1)
+	if err != nil {
+		t.Fatalf("failed to bump fee: %s", err)
+	}
+	if len(tx.In) != 1 || len(tx.Out) != 2 || tx.Out[0].Amount != 50000 || tx.Out[1].Amount >= orig.Out[1].Amount {
+		t.Errorf("unexpected replacement")
+	}
+	fee, rate := sign(tx, k)
+	if rate < 10 || fee < oldFee+int64(tx.VSize()) {
+		t.Errorf("replacement fee %d at %f sat/vB does not satisfy BIP-125", fee, rate)
+	}
+	if orig.In[0].Witnesses == nil {
+		t.Errorf("original tx was modified")
+	}
+
+	// a small fee increase still has to pay the incremental relay fee
+	tx, k, err = orig.BumpFee(keys, 2.1, 1)
+	if err != nil {
+		t.Fatalf("failed to bump fee: %s", err)
+	}
+	if fee, _ := sign(tx, k); fee < oldFee+int64(tx.VSize()) {
+		t.Errorf("replacement fee %d does not pay for its size", fee)
+	}
+
+	// the change output is too small, more inputs are added
+	tx, k, err = orig.BumpFee(keys, 500, 1, utxo(1, 20000, "p2pkh"), utxo(2, 80000, "p2tr"))
+	if err != nil {
+		t.Fatalf("failed to bump fee: %s", err)
+	}
+	if len(tx.In) != 2 || tx.In[1].Vout != 2 || len(tx.Out) != 2 {
+		t.Errorf("expected the largest utxo to be added")
+	}
+	if _, rate := sign(tx, k); rate < 500 {
+		t.Errorf("replacement fee rate %f is too low", rate)
+	}
+
+	// change that would become dust is removed
+	tx, k, err = orig.BumpFee(keys, 400, 1)
+	if err != nil {
+		t.Fatalf("failed to bump fee: %s", err)
+	}
+	if len(tx.Out) != 1 {
+		t.Errorf("expected change output to be removed")
+	}
+	sign(tx, k)
+
+	// without change the fee can't be increased
+	if _, _, err := orig.BumpFee(keys, 10, -1); err == nil {
+		t.Errorf("expected error without change output")
+	}
+	if _, _, err := orig.BumpFee(keys, 1, 1); err == nil {
+		t.Errorf("expected error lowering the fee rate")
+	}
+	if _, _, err := orig.BumpFee(keys, 600, 1); err == nil {
+		t.Errorf("expected insufficient funds error")
+	}
+
+	// child pays for parent, the parent paying 1 sat/vB
+	parent := &outscript.BtcTx{Version: 2}
+	parent.In = append(parent.In, &outscript.BtcTxInput{TXID: txid, Vout: 3, Sequence: 0xffffffff})
+	parent.Out = append(parent.Out, &outscript.BtcTxOutput{Amount: 99890, Script: script})
+	parentFee, _ := sign(parent, []*outscript.BtcTxSign{{Key: key, Scheme: "p2wpkh", Amount: 100000}})
+
+	child, k, err := parent.Cpfp(outscript.BtcAmount(parentFee), 0, key, "p2wpkh", 20, "bitcoin", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq")
+	if err != nil {
+		t.Fatalf("failed to build child: %s", err)
+	}
+	if parentHash := must(parent.Hash()); hex.EncodeToString(child.In[0].TXID[:]) != hex.EncodeToString(parentHash) || !child.SignalsRBF() {
+		t.Errorf("child does not spend parent")
+	}
+	childFee, _ := sign(child, k)
+	if rate := float64(parentFee+childFee) / float64(parent.VSize()+child.VSize()); rate < 20 || rate > 20.5 {
+		t.Errorf("unexpected package fee rate %f", rate)
+	}
+	if _, _, err := parent.Cpfp(outscript.BtcAmount(parentFee), 0, key, "p2wpkh", 1000, "bitcoin", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"); err == nil {
+		t.Errorf("expected error when the output can't pay for the fee")
+	}
+	if _, _, err := parent.Cpfp(outscript.BtcAmount(parentFee), 1, key, "p2wpkh", 20, "bitcoin", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq"); err == nil {
+		t.Errorf("expected error with invalid output")
+	}
+}
@@ -0,0 +1,873 @@
+package outscript
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/BottleFmt/gobottle"
+	"github.com/KarpelesLab/secp256k1"
+	"golang.org/x/crypto/ripemd160"
+)
+
+const (
+	sigVersionBase      = iota // legacy scripts and p2sh
+	sigVersionWitnessV0        // BIP-143 p2wpkh and p2wsh scripts
+	sigVersionTapscript        // BIP-342 tapscript
+)
+
+const (
+	maxScriptSize         = 10000
+	maxScriptElementSize  = 520
+	maxScriptOps          = 201
+	maxStackSize          = 1000
+	maxPubKeysPerMultisig = 20
+	lockTimeThreshold     = 500000000
+)
+
+var errStackUnderflow = errors.New("script stack underflow")
+
+// btcScriptEngine executes bitcoin scripts for the input n of tx
+type btcScriptEngine struct {
+	tx       *BtcTx
+	n        int
+	prevOuts []*BtcTxOutput
+	flags    BtcVerifyFlags
+	stack    [][]byte
+	alt      [][]byte
+
+	sigVersion int
+	leafHash   []byte // tapscript leaf hash
+	annex      []byte // taproot annex, if any
+	codeSepPos uint32 // tapscript position of the last executed OP_CODESEPARATOR
+	budget     int    // tapscript remaining validation weight
+}
+
+// readScriptOp reads the operation at pc, returning the opcode, the pushed data if any and the
+// position of the next operation
+func readScriptOp(script []byte, pc int) (byte, []byte, int, error) {
+	op := script[pc]
+	pc++
+	var ln uint64
+	switch {
+	case op < 0x4c:
+		ln = uint64(op)
+	case op == 0x4c: // OP_PUSHDATA1
+		if pc+1 > len(script) {
+			return op, nil, 0, errors.New("truncated script push")
+		}
+		ln = uint64(script[pc])
+		pc++
+	case op == 0x4d: // OP_PUSHDATA2
+		if pc+2 > len(script) {
+			return op, nil, 0, errors.New("truncated script push")
+		}
+		ln = uint64(binary.LittleEndian.Uint16(script[pc:]))
+		pc += 2
+	case op == 0x4e: // OP_PUSHDATA4
+		if pc+4 > len(script) {
+			return op, nil, 0, errors.New("truncated script push")
+		}
+		ln = uint64(binary.LittleEndian.Uint32(script[pc:]))
+		pc += 4
+	default:
+		return op, nil, pc, nil
+	}
+	if ln > uint64(len(script)-pc) {
+		return op, nil, 0, errors.New("truncated script push")
+	}
+	return op, script[pc : pc+int(ln)], pc + int(ln), nil
+}
+
+// isPushOnly returns true if script only contains push operations
+func isPushOnly(script []byte) bool {
+	for pc := 0; pc < len(script); {
+		op, _, next, err := readScriptOp(script, pc)
+		if err != nil || op > 0x60 {
+			return false
+		}
+		pc = next
+	}
+	return true
+}
+
+// isDisabledOp returns true for opcodes that fail the script even when not executed
+func isDisabledOp(op byte) bool {
+	switch op {
+	case 0x7e, 0x7f, 0x80, 0x81, // OP_CAT, OP_SUBSTR, OP_LEFT, OP_RIGHT
+		0x83, 0x84, 0x85, 0x86, // OP_INVERT, OP_AND, OP_OR, OP_XOR
+		0x8d, 0x8e, // OP_2MUL, OP_2DIV
+		0x95, 0x96, 0x97, 0x98, 0x99: // OP_MUL, OP_DIV, OP_MOD, OP_LSHIFT, OP_RSHIFT
+		return true
+	}
+	return false
+}
+
+// isOpSuccess returns true for the BIP-342 OP_SUCCESSx opcodes
+func isOpSuccess(op byte) bool {
+	switch {
+	case op == 80, op == 98, op >= 126 && op <= 129, op >= 131 && op <= 134, op == 137, op == 138,
+		op == 141, op == 142, op >= 149 && op <= 153, op >= 187 && op <= 254:
+		return true
+	}
+	return false
+}
+
+// castToBool returns the boolean value of a stack element, any non zero value except negative
+// zero being true
+func castToBool(v []byte) bool {
+	for i, b := range v {
+		if b != 0 {
+			return i != len(v)-1 || b != 0x80
+		}
+	}
+	return false
+}
+
+// scriptNum decodes a little endian sign-magnitude script number of at most maxLen bytes
+func scriptNum(v []byte, maxLen int) (int64, error) {
+	if len(v) > maxLen {
+		return 0, errors.New("script number overflow")
+	}
+	if len(v) == 0 {
+		return 0, nil
+	}
+	var res int64
+	for i, b := range v {
+		res |= int64(b) << (8 * i)
+	}
+	if v[len(v)-1]&0x80 != 0 {
+		res &^= int64(0x80) << (8 * (len(v) - 1))
+		return -res, nil
+	}
+	return res, nil
+}
+
+// scriptNumBytes encodes n as a minimal script number
+func scriptNumBytes(n int64) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	neg := n < 0
+	if neg {
+		n = -n
+	}
+	var res []byte
+	for ; n > 0; n >>= 8 {
+		res = append(res, byte(n))
+	}
+	switch {
+	case res[len(res)-1]&0x80 != 0 && neg:
+		res = append(res, 0x80)
+	case res[len(res)-1]&0x80 != 0:
+		res = append(res, 0)
+	case neg:
+		res[len(res)-1] |= 0x80
+	}
+	return res
+}
+
+func (e *btcScriptEngine) push(v []byte) {
+	e.stack = append(e.stack, v)
+}
+
+func (e *btcScriptEngine) pushBool(b bool) {
+	if b {
+		e.push([]byte{1})
+	} else {
+		e.push([]byte{})
+	}
+}
+
+func (e *btcScriptEngine) pop() ([]byte, error) {
+	if len(e.stack) == 0 {
+		return nil, errStackUnderflow
+	}
+	v := e.stack[len(e.stack)-1]
+	e.stack = e.stack[:len(e.stack)-1]
+	return v, nil
+}
+
+func (e *btcScriptEngine) popNum() (int64, error) {
+	v, err := e.pop()
+	if err != nil {
+		return 0, err
+	}
+	return scriptNum(v, 4)
+}
+
+func (e *btcScriptEngine) popBool() (bool, error) {
+	v, err := e.pop()
+	if err != nil {
+		return false, err
+	}
+	return castToBool(v), nil
+}
+
+// top returns the i-th element from the top of the stack, 1 being the top
+func (e *btcScriptEngine) top(i int) ([]byte, error) {
+	if i < 1 || i > len(e.stack) {
+		return nil, errStackUnderflow
+	}
+	return e.stack[len(e.stack)-i], nil
+}
+
+// need returns an error if the stack holds less than n elements
+func (e *btcScriptEngine) need(n int) error {
+	if len(e.stack) < n {
+		return errStackUnderflow
+	}
+	return nil
+}
+
+// execute runs script on the current stack
+func (e *btcScriptEngine) execute(script []byte) error {
+	if e.sigVersion != sigVersionTapscript && len(script) > maxScriptSize {
+		return errors.New("script is too large")
+	}
+	var exec []bool
+	e.alt = nil
+	e.codeSepPos = 0xffffffff
+	codeStart := 0
+	opCount := 0
+
+	for pc, opPos := 0, uint32(0); pc < len(script); opPos++ {
+		op, data, next, err := readScriptOp(script, pc)
+		if err != nil {
+			return err
+		}
+		pc = next
+		running := !slices.Contains(exec, false)
+
+		if len(data) > maxScriptElementSize {
+			return errors.New("script push exceeds maximum element size")
+		}
+		if e.sigVersion != sigVersionTapscript && op > 0x60 {
+			opCount++
+			if opCount > maxScriptOps {
+				return errors.New("script exceeds maximum operation count")
+			}
+		}
+		if isDisabledOp(op) {
+			return fmt.Errorf("disabled opcode 0x%02x", op)
+		}
+
+		switch {
+		case op <= 0x4e:
+			if running {
+				e.push(data)
+			}
+		case !running && (op < 0x63 || op > 0x68):
+			// not executed
+		default:
+			if err := e.step(op, script, pc, opPos, &exec, &codeStart, &opCount); err != nil {
+				return err
+			}
+		}
+		if len(e.stack)+len(e.alt) > maxStackSize {
+			return errors.New("script stack size exceeded")
+		}
+	}
+	if len(exec) != 0 {
+		return errors.New("unbalanced script conditional")
+	}
+	return nil
+}
+
+// step executes the non-push opcode op. pc points to the next operation, and opPos is the
+// index of the operation in the script.
+func (e *btcScriptEngine) step(op byte, script []byte, pc int, opPos uint32, exec *[]bool, codeStart, opCount *int) error {
+	switch op {
+	case 0x4f: // OP_1NEGATE
+		e.push(scriptNumBytes(-1))
+	case 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5a, 0x5b, 0x5c, 0x5d, 0x5e, 0x5f, 0x60: // OP_1 - OP_16
+		e.push(scriptNumBytes(int64(op - 0x50)))
+	case 0x61: // OP_NOP
+	case 0x63, 0x64: // OP_IF, OP_NOTIF
+		cond := false
+		if !slices.Contains(*exec, false) {
+			v, err := e.pop()
+			if err != nil {
+				return err
+			}
+			if e.sigVersion == sigVersionTapscript && (len(v) > 1 || (len(v) == 1 && v[0] != 1)) {
+				return errors.New("tapscript OP_IF argument must be minimal")
+			}
+			cond = castToBool(v)
+			if op == 0x64 {
+				cond = !cond
+			}
+		}
+		*exec = append(*exec, cond)
+	case 0x67: // OP_ELSE
+		if len(*exec) == 0 {
+			return errors.New("unbalanced script conditional")
+		}
+		(*exec)[len(*exec)-1] = !(*exec)[len(*exec)-1]
+	case 0x68: // OP_ENDIF
+		if len(*exec) == 0 {
+			return errors.New("unbalanced script conditional")
+		}
+		*exec = (*exec)[:len(*exec)-1]
+	case 0x69: // OP_VERIFY
+		v, err := e.popBool()
+		if err != nil {
+			return err
+		}
+		if !v {
+			return errors.New("OP_VERIFY failed")
+		}
+	case 0x6a: // OP_RETURN
+		return errors.New("OP_RETURN executed")
+
+	// stack operations
+	case 0x6b: // OP_TOALTSTACK
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		e.alt = append(e.alt, v)
+	case 0x6c: // OP_FROMALTSTACK
+		if len(e.alt) == 0 {
+			return errors.New("script alt stack underflow")
+		}
+		e.push(e.alt[len(e.alt)-1])
+		e.alt = e.alt[:len(e.alt)-1]
+	case 0x6d: // OP_2DROP
+		if err := e.need(2); err != nil {
+			return err
+		}
+		e.stack = e.stack[:len(e.stack)-2]
+	case 0x6e, 0x6f, 0x70: // OP_2DUP, OP_3DUP, OP_2OVER
+		// number of elements to copy, and their depth
+		cnt, depth := 2, 2
+		switch op {
+		case 0x6f:
+			cnt, depth = 3, 3
+		case 0x70:
+			depth = 4
+		}
+		if err := e.need(depth); err != nil {
+			return err
+		}
+		l := len(e.stack)
+		e.stack = append(e.stack, e.stack[l-depth:l-depth+cnt]...)
+	case 0x71: // OP_2ROT
+		if err := e.need(6); err != nil {
+			return err
+		}
+		l := len(e.stack)
+		v := slices.Clone(e.stack[l-6 : l-4])
+		e.stack = append(slices.Delete(e.stack, l-6, l-4), v...)
+	case 0x72: // OP_2SWAP
+		if err := e.need(4); err != nil {
+			return err
+		}
+		l := len(e.stack)
+		e.stack[l-4], e.stack[l-3], e.stack[l-2], e.stack[l-1] = e.stack[l-2], e.stack[l-1], e.stack[l-4], e.stack[l-3]
+	case 0x73: // OP_IFDUP
+		v, err := e.top(1)
+		if err != nil {
+			return err
+		}
+		if castToBool(v) {
+			e.push(v)
+		}
+	case 0x74: // OP_DEPTH
+		e.push(scriptNumBytes(int64(len(e.stack))))
+	case 0x75: // OP_DROP
+		if _, err := e.pop(); err != nil {
+			return err
+		}
+	case 0x76: // OP_DUP
+		v, err := e.top(1)
+		if err != nil {
+			return err
+		}
+		e.push(v)
+	case 0x77: // OP_NIP
+		if err := e.need(2); err != nil {
+			return err
+		}
+		e.stack = slices.Delete(e.stack, len(e.stack)-2, len(e.stack)-1)
+	case 0x78: // OP_OVER
+		v, err := e.top(2)
+		if err != nil {
+			return err
+		}
+		e.push(v)
+	case 0x79, 0x7a: // OP_PICK, OP_ROLL
+		n, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		if n < 0 || n >= int64(len(e.stack)) {
+			return errStackUnderflow
+		}
+		pos := len(e.stack) - 1 - int(n)
+		v := e.stack[pos]
+		if op == 0x7a {
+			e.stack = slices.Delete(e.stack, pos, pos+1)
+		}
+		e.push(v)
+	case 0x7b: // OP_ROT
+		if err := e.need(3); err != nil {
+			return err
+		}
+		l := len(e.stack)
+		e.stack[l-3], e.stack[l-2], e.stack[l-1] = e.stack[l-2], e.stack[l-1], e.stack[l-3]
+	case 0x7c: // OP_SWAP
+		if err := e.need(2); err != nil {
+			return err
+		}
+		l := len(e.stack)
+		e.stack[l-2], e.stack[l-1] = e.stack[l-1], e.stack[l-2]
+	case 0x7d: // OP_TUCK
+		if err := e.need(2); err != nil {
+			return err
+		}
+		l := len(e.stack)
+		e.stack = slices.Insert(e.stack, l-2, e.stack[l-1])
+	case 0x82: // OP_SIZE
+		v, err := e.top(1)
+		if err != nil {
+			return err
+		}
+		e.push(scriptNumBytes(int64(len(v))))
+
+	// bitwise logic
+	case 0x87, 0x88: // OP_EQUAL, OP_EQUALVERIFY
+		if err := e.need(2); err != nil {
+			return err
+		}
+		a, _ := e.pop()
+		b, _ := e.pop()
+		if op == 0x88 {
+			if !bytes.Equal(a, b) {
+				return errors.New("OP_EQUALVERIFY failed")
+			}
+			break
+		}
+		e.pushBool(bytes.Equal(a, b))
+
+	// arithmetic
+	case 0x8b, 0x8c, 0x8f, 0x90, 0x91, 0x92: // OP_1ADD, OP_1SUB, OP_NEGATE, OP_ABS, OP_NOT, OP_0NOTEQUAL
+		a, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case 0x8b:
+			a++
+		case 0x8c:
+			a--
+		case 0x8f:
+			a = -a
+		case 0x90:
+			a = max(a, -a)
+		case 0x91:
+			a = boolNum(a == 0)
+		case 0x92:
+			a = boolNum(a != 0)
+		}
+		e.push(scriptNumBytes(a))
+	case 0x93, 0x94, 0x9a, 0x9b, 0x9c, 0x9d, 0x9e, 0x9f, 0xa0, 0xa1, 0xa2, 0xa3, 0xa4:
+		if err := e.need(2); err != nil {
+			return err
+		}
+		b, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		a, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		var res int64
+		switch op {
+		case 0x93: // OP_ADD
+			res = a + b
+		case 0x94: // OP_SUB
+			res = a - b
+		case 0x9a: // OP_BOOLAND
+			res = boolNum(a != 0 && b != 0)
+		case 0x9b: // OP_BOOLOR
+			res = boolNum(a != 0 || b != 0)
+		case 0x9c, 0x9d: // OP_NUMEQUAL, OP_NUMEQUALVERIFY
+			res = boolNum(a == b)
+		case 0x9e: // OP_NUMNOTEQUAL
+			res = boolNum(a != b)
+		case 0x9f: // OP_LESSTHAN
+			res = boolNum(a < b)
+		case 0xa0: // OP_GREATERTHAN
+			res = boolNum(a > b)
+		case 0xa1: // OP_LESSTHANOREQUAL
+			res = boolNum(a <= b)
+		case 0xa2: // OP_GREATERTHANOREQUAL
+			res = boolNum(a >= b)
+		case 0xa3: // OP_MIN
+			res = min(a, b)
+		case 0xa4: // OP_MAX
+			res = max(a, b)
+		}
+		if op == 0x9d {
+			if res == 0 {
+				return errors.New("OP_NUMEQUALVERIFY failed")
+			}
+			break
+		}
+		e.push(scriptNumBytes(res))
+	case 0xa5: // OP_WITHIN
+		if err := e.need(3); err != nil {
+			return err
+		}
+		hi, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		lo, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		x, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		e.pushBool(lo <= x && x < hi)
+
+	// crypto
+	case 0xa6, 0xa7, 0xa8, 0xa9, 0xaa: // OP_RIPEMD160, OP_SHA1, OP_SHA256, OP_HASH160, OP_HASH256
+		v, err := e.pop()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case 0xa6:
+			v = gobottle.Hash(v, ripemd160.New)
+		case 0xa7:
+			v = gobottle.Hash(v, sha1.New)
+		case 0xa8:
+			v = gobottle.Hash(v, sha256.New)
+		case 0xa9:
+			v = gobottle.Hash(v, sha256.New, ripemd160.New)
+		case 0xaa:
+			v = gobottle.Hash(v, sha256.New, sha256.New)
+		}
+		e.push(v)
+	case 0xab: // OP_CODESEPARATOR
+		*codeStart = pc
+		e.codeSepPos = opPos
+	case 0xac, 0xad: // OP_CHECKSIG, OP_CHECKSIGVERIFY
+		if err := e.need(2); err != nil {
+			return err
+		}
+		pub, _ := e.pop()
+		sig, _ := e.pop()
+		var ok bool
+		var err error
+		if e.sigVersion == sigVersionTapscript {
+			ok, err = e.checkTapscriptSig(sig, pub)
+		} else {
+			ok = e.checkSig(sig, pub, e.scriptCode(script[*codeStart:], sig))
+		}
+		if err != nil {
+			return err
+		}
+		if op == 0xad {
+			if !ok {
+				return errors.New("OP_CHECKSIGVERIFY failed")
+			}
+			break
+		}
+		e.pushBool(ok)
+	case 0xae, 0xaf: // OP_CHECKMULTISIG, OP_CHECKMULTISIGVERIFY
+		if e.sigVersion == sigVersionTapscript {
+			return errors.New("OP_CHECKMULTISIG is disabled in tapscript")
+		}
+		ok, err := e.checkMultisig(script[*codeStart:], opCount)
+		if err != nil {
+			return err
+		}
+		if op == 0xaf {
+			if !ok {
+				return errors.New("OP_CHECKMULTISIGVERIFY failed")
+			}
+			break
+		}
+		e.pushBool(ok)
+	case 0xba: // OP_CHECKSIGADD
+		if e.sigVersion != sigVersionTapscript {
+			return fmt.Errorf("bad opcode 0x%02x", op)
+		}
+		if err := e.need(3); err != nil {
+			return err
+		}
+		pub, _ := e.pop()
+		n, err := e.popNum()
+		if err != nil {
+			return err
+		}
+		sig, _ := e.pop()
+		ok, err := e.checkTapscriptSig(sig, pub)
+		if err != nil {
+			return err
+		}
+		if ok {
+			n++
+		}
+		e.push(scriptNumBytes(n))
+
+	// locktime
+	case 0xb1: // OP_CHECKLOCKTIMEVERIFY
+		if e.flags&BtcVerifyCLTV == 0 {
+			break
+		}
+		return e.checkLockTime()
+	case 0xb2: // OP_CHECKSEQUENCEVERIFY
+		if e.flags&BtcVerifyCSV == 0 {
+			break
+		}
+		return e.checkSequence()
+	case 0xb0, 0xb3, 0xb4, 0xb5, 0xb6, 0xb7, 0xb8, 0xb9: // OP_NOP1, OP_NOP4 - OP_NOP10
+	default:
+		return fmt.Errorf("bad opcode 0x%02x", op)
+	}
+	return nil
+}
+
+func boolNum(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// scriptCode returns the script signed by sig for legacy and BIP-143 signatures. Legacy
+// signatures cannot sign themselves and are removed from the script.
+func (e *btcScriptEngine) scriptCode(script []byte, sigs ...[]byte) []byte {
+	if e.sigVersion != sigVersionBase {
+		return script
+	}
+	for _, sig := range sigs {
+		script = findAndDelete(script, PushBytes(sig))
+	}
+	return script
+}
+
+// findAndDelete removes all the operations in script that are equal to op
+func findAndDelete(script, op []byte) []byte {
+	var res []byte
+	for pc := 0; pc < len(script); {
+		_, _, next, err := readScriptOp(script, pc)
+		if err != nil {
+			return append(res, script[pc:]...)
+		}
+		if !bytes.Equal(script[pc:next], op) {
+			res = append(res, script[pc:next]...)
+		}
+		pc = next
+	}
+	return res
+}
+
+// checkSig checks a ECDSA signature for legacy and segwit v0 scripts
+func (e *btcScriptEngine) checkSig(sig, pub, scriptCode []byte) bool {
+	if len(sig) == 0 {
+		return false
+	}
+	hashType := uint32(sig[len(sig)-1])
+	s, err := secp256k1.ParseDERSignature(sig[:len(sig)-1])
+	if err != nil {
+		return false
+	}
+	key, err := secp256k1.ParsePubKey(pub)
+	if err != nil {
+		return false
+	}
+	forkID := e.flags&BtcVerifyForkID != 0
+	if forkID && hashType&SigHashForkID == 0 {
+		return false
+	}
+	var hash []byte
+	if e.sigVersion == sigVersionWitnessV0 || forkID {
+		hash = e.tx.witnessSigHash(e.n, scriptCode, e.prevOuts[e.n].Amount, hashType)
+	} else {
+		hash = e.tx.legacySigHash(e.n, scriptCode, hashType)
+	}
+	return s.Verify(hash, key)
+}
+
+// checkMultisig runs OP_CHECKMULTISIG on the stack
+func (e *btcScriptEngine) checkMultisig(script []byte, opCount *int) (bool, error) {
+	nKeys, err := e.topNum(1)
+	if err != nil {
+		return false, err
+	}
+	if nKeys < 0 || nKeys > maxPubKeysPerMultisig {
+		return false, errors.New("invalid OP_CHECKMULTISIG key count")
+	}
+	*opCount += int(nKeys)
+	if *opCount > maxScriptOps {
+		return false, errors.New("script exceeds maximum operation count")
+	}
+	keyPos := 2
+	sigCountPos := keyPos + int(nKeys)
+	nSigs, err := e.topNum(sigCountPos)
+	if err != nil {
+		return false, err
+	}
+	if nSigs < 0 || nSigs > nKeys {
+		return false, errors.New("invalid OP_CHECKMULTISIG signature count")
+	}
+	sigPos := sigCountPos + 1
+	end := sigPos + int(nSigs) // position of the dummy element
+	if err := e.need(end); err != nil {
+		return false, err
+	}
+
+	var sigs [][]byte
+	for i := range int(nSigs) {
+		sigs = append(sigs, e.stack[len(e.stack)-sigPos-i])
+	}
+	scriptCode := e.scriptCode(script, sigs...)
+
+	ok := true
+	for nSigs > 0 {
+		sig, _ := e.top(sigPos)
+		pub, _ := e.top(keyPos)
+		if e.checkSig(sig, pub, scriptCode) {
+			sigPos++
+			nSigs--
+		}
+		keyPos++
+		nKeys--
+		if nSigs > nKeys {
+			ok = false
+			break
+		}
+	}
+
+	dummy := e.stack[len(e.stack)-end]
+	if e.flags&BtcVerifyNullDummy != 0 && len(dummy) != 0 {
+		return false, errors.New("OP_CHECKMULTISIG dummy element must be empty")
+	}
+	e.stack = e.stack[:len(e.stack)-end]
+	return ok, nil
+}
+
+// topNum returns the i-th element from the top of the stack as a number
+func (e *btcScriptEngine) topNum(i int) (int64, error) {
+	v, err := e.top(i)
+	if err != nil {
+		return 0, err
+	}
+	return scriptNum(v, 4)
+}
+
+// checkTapscriptSig checks a BIP-340 signature in tapscript. Empty signatures return false, and
+// invalid signatures cause the script to fail.
+func (e *btcScriptEngine) checkTapscriptSig(sig, pub []byte) (bool, error) {
+	if len(sig) > 0 {
+		e.budget -= 50
+		if e.budget < 0 {
+			return false, errors.New("tapscript validation weight exceeded")
+		}
+	}
+	switch {
+	case len(pub) == 0:
+		return false, errors.New("tapscript empty public key")
+	case len(sig) == 0:
+		return false, nil
+	case len(pub) != 32:
+		// unknown public key type, reserved for upgrades
+		return true, nil
+	}
+	if err := e.verifySchnorr(pub, sig); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// verifySchnorr checks a taproot signature with its optional sighash type byte
+func (e *btcScriptEngine) verifySchnorr(pub, sig []byte) error {
+	var hashType uint32
+	switch len(sig) {
+	case 64:
+	case 65:
+		hashType = uint32(sig[64])
+		if hashType == 0 {
+			return errors.New("invalid taproot sighash type 0x0 with explicit byte")
+		}
+		sig = sig[:64]
+	default:
+		return errors.New("invalid schnorr signature length")
+	}
+	hash, err := e.tx.taprootSigHashExt(e.n, e.prevOuts, hashType, e.leafHash, e.annex, e.codeSepPos)
+	if err != nil {
+		return err
+	}
+	if !SchnorrVerify(pub, hash, sig) {
+		return errors.New("invalid schnorr signature")
+	}
+	return nil
+}
+
+// checkLockTime implements OP_CHECKLOCKTIMEVERIFY (BIP-65)
+func (e *btcScriptEngine) checkLockTime() error {
+	v, err := e.top(1)
+	if err != nil {
+		return err
+	}
+	lock, err := scriptNum(v, 5)
+	if err != nil {
+		return err
+	}
+	txLock := int64(e.tx.Locktime)
+	switch {
+	case lock < 0:
+		return errors.New("negative locktime")
+	case (lock < lockTimeThreshold) != (txLock < lockTimeThreshold):
+		return errors.New("locktime type mismatch")
+	case lock > txLock:
+		return errors.New("locktime requirement not satisfied")
+	case e.tx.In[e.n].Sequence == 0xffffffff:
+		return errors.New("locktime disabled by input sequence")
+	}
+	return nil
+}
+
+// checkSequence implements OP_CHECKSEQUENCEVERIFY (BIP-112)
+func (e *btcScriptEngine) checkSequence() error {
+	v, err := e.top(1)
+	if err != nil {
+		return err
+	}
+	seq, err := scriptNum(v, 5)
+	if err != nil {
+		return err
+	}
+	if seq < 0 {
+		return errors.New("negative sequence")
+	}
+	if seq&(1<<31) != 0 {
+		// disabled, behaves as a NOP
+		return nil
+	}
+	txSeq := int64(e.tx.In[e.n].Sequence)
+	const mask = 1<<22 | 0xffff
+	switch {
+	case int32(e.tx.Version) < 2:
+		return errors.New("relative locktime requires transaction version 2")
+	case txSeq&(1<<31) != 0:
+		return errors.New("relative locktime disabled by input sequence")
+	case (seq&mask < 1<<22) != (txSeq&mask < 1<<22):
+		return errors.New("relative locktime type mismatch")
+	case seq&mask > txSeq&mask:
+		return errors.New("relative locktime requirement not satisfied")
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package outscript_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+// verifyScript runs the given input script, witness and spent output script on a single input
+// transaction
+func verifyScript(tx *outscript.BtcTx, sig, prevScript string, witness [][]byte, flags outscript.BtcVerifyFlags) error {
+	if tx == nil {
+		tx = &outscript.BtcTx{Version: 2, Locktime: 500}
+		tx.In = append(tx.In, &outscript.BtcTxInput{Vout: 1, Sequence: 10})
+		tx.Out = append(tx.Out, &outscript.BtcTxOutput{Amount: 1000, Script: []byte{0x51}})
+	}
+	tx.In[0].Script = must(hex.DecodeString(sig))
+	tx.In[0].Witnesses = witness
+	prevOut := &outscript.BtcTxOutput{Amount: 2000, Script: must(hex.DecodeString(prevScript))}
+	return tx.VerifyInputFlags(0, []*outscript.BtcTxOutput{prevOut}, flags)
+}
+
+func TestBtcScript(t *testing.T) {
+	all := outscript.BtcVerifyAll
+	vectors := []struct {
+		sig, script string
+		flags       outscript.BtcVerifyFlags
+		ok          bool
+	}{
+		{"", "51", all, true},
+		{"", "00", all, false},
+		{"", "", all, false},
+		{"", "0180", all, false},                            // negative zero
+		{"", "5253935587", all, true},                       // 2 3 ADD 5 EQUAL
+		{"", "5253944f87", all, true},                       // 2 3 SUB -1 EQUAL
+		{"", "5a8b8c8f905a87", all, true},                   // 10 1ADD 1SUB NEGATE ABS 10 EQUAL
+		{"", "0091", all, true},                             // 0 NOT
+		{"", "5254a3a4", all, false},                        // MIN leaves a single value, MAX underflows
+		{"", "5254a3", all, true},                           // 2 4 MIN
+		{"", "51539fa0", all, false},                        // stack underflow
+		{"", "51539c", all, false},                          // 1 3 NUMEQUAL
+		{"", "5151539d", all, false},                        // 1 3 NUMEQUALVERIFY
+		{"", "525153a5", all, true},                         // 2 WITHIN [1, 3)
+		{"", "515253a5", all, false},                        // 1 WITHIN [2, 3)
+		{"51", "63526753685287", all, true},                 // IF 2 ELSE 3 ENDIF 2 EQUAL
+		{"00", "63526753685287", all, false},                // IF 2 ELSE 3 ENDIF 2 EQUAL
+		{"00", "6451670068", all, true},                     // NOTIF 1 ELSE 0 ENDIF
+		{"", "5163", all, false},                            // unbalanced IF
+		{"", "5168", all, false},                            // unbalanced ENDIF
+		{"", "0063626851", all, true},                       // OP_VER not executed
+		{"", "51636268", all, false},                        // OP_VER executed
+		{"", "0063656851", all, false},                      // OP_VERIF always fails
+		{"", "00637e6851", all, false},                      // disabled opcode not executed
+		{"", "00636a6851", all, true},                       // OP_RETURN not executed
+		{"", "516a", all, false},                            // OP_RETURN
+		{"", "5152537b5187695387695287", all, true},         // ROT
+		{"", "515253527951876951", all, true},               // PICK
+		{"", "5152535a79", all, false},                      // PICK out of range
+		{"", "515253527a5187695387695287", all, true},       // ROLL
+		{"", "51525354725287695187695487695387", all, true}, // 2SWAP
+		{"", "51527d5287695187695287", all, true},           // TUCK
+		{"", "51516e6f745787", all, true},                   // 2DUP 3DUP DEPTH 7 EQUAL
+		{"", "51525354555671705687", all, true},             // 2ROT 2OVER
+		{"", "03aabbcc825387", all, true},                   // SIZE
+		{"", "516b006c", all, true},                         // alt stack
+		{"", "6c", all, false},                              // empty alt stack
+		{"", "00a820e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b85587", all, true},
+		{"", "00a914b472a266d0bd89c13706a4132ccfb16f7c3b9fcb87", all, true},
+		{"", "00a6149c1185a5c5e9fc54612808977ee8f548b2258d3187", all, true},
+		{"", "00a714da39a3ee5e6b4b0d3255bfef95601890afd8070987", all, true},
+		{"", "00aa205df6e0e2761359d30a8275058e299fcc0381534545f55cf43e41983f5d4c945687", all, true},
+		{"", "05000000000151938b", all, false},          // 5 bytes numbers are not allowed in arithmetic
+		{"", "000000ae", all, true},                     // 0-of-0 multisig
+		{"", "510000ae", all, false},                    // non-null dummy
+		{"", "510000ae", outscript.BtcVerifyP2SH, true}, // non-null dummy without BIP-147
+		{"", "02f401b17551", all, true},                 // locktime 500
+		{"", "02f501b17551", all, false},                // locktime 501
+		{"", "02f501b17551", outscript.BtcVerifyP2SH, true},
+		{"", "0400ca9a3bb17551", all, false},                                           // time-based locktime
+		{"", "5ab27551", all, true},                                                    // relative locktime 10
+		{"", "5bb27551", all, false},                                                   // relative locktime 11
+		{"", "5bb27551", outscript.BtcVerifyP2SH, true},                                // without BIP-112
+		{"", "050000008000b27551", all, true},                                          // disabled relative locktime
+		{"01", "a9149f7fd096d37ed2c0e3f7f0cfc924beef4ffceb6887", all, false},           // p2sh with truncated push
+		{"0100", "a9149f7fd096d37ed2c0e3f7f0cfc924beef4ffceb6887", all, false},         // p2sh redeem script false
+		{"0100", "a9149f7fd096d37ed2c0e3f7f0cfc924beef4ffceb6887", 0, true},            // p2sh rules disabled
+		{"0452539355", "a91443e6d69a84463ee53b89e7a8ca8fbecd660219b787", all, true},    // p2sh 2 3 ADD
+		{"610452539355", "a91443e6d69a84463ee53b89e7a8ca8fbecd660219b787", all, false}, // p2sh with non push input script
+		{"610452539355", "a91443e6d69a84463ee53b89e7a8ca8fbecd660219b787", 0, true},    // non push input script without p2sh rules
+	}
+	for n, v := range vectors {
+		err := verifyScript(nil, v.sig, v.script, nil, v.flags)
+		if v.ok && err != nil {
+			t.Errorf("vector %d (%s): unexpected error: %s", n, v.script, err)
+		} else if !v.ok && err == nil {
+			t.Errorf("vector %d (%s): expected error", n, v.script)
+		}
+	}
+
+	// locktime and relative locktime depend on the input sequence and tx version
+	tx := &outscript.BtcTx{Version: 1, Locktime: 500}
+	tx.In = append(tx.In, &outscript.BtcTxInput{Vout: 1, Sequence: 0xffffffff})
+	if err := verifyScript(tx, "", "02f401b17551", nil, all); err == nil {
+		t.Errorf("expected error with final input sequence")
+	}
+	if err := verifyScript(tx, "", "5ab27551", nil, all); err == nil {
+		t.Errorf("expected error with version 1 transaction")
+	}
+}
+
+func TestBtcScriptWitness(t *testing.T) {
+	script := must(hex.DecodeString("5253935587")) // 2 3 ADD 5 EQUAL
+	h := sha256.Sum256(script)
+	p2wsh := "0020" + hex.EncodeToString(h[:])
+
+	if err := verifyScript(nil, "", p2wsh, [][]byte{script}, outscript.BtcVerifyAll); err != nil {
+		t.Errorf("failed to verify p2wsh: %s", err)
+	}
+	if err := verifyScript(nil, "", p2wsh, [][]byte{{1}, script}, outscript.BtcVerifyAll); err == nil {
+		t.Errorf("expected error with extra witness element left on the stack")
+	}
+	if err := verifyScript(nil, "", p2wsh, [][]byte{script[1:]}, outscript.BtcVerifyAll); err == nil {
+		t.Errorf("expected error with mismatching witness script")
+	}
+	if err := verifyScript(nil, "00", p2wsh, [][]byte{script}, outscript.BtcVerifyAll); err == nil {
+		t.Errorf("expected error with non-empty input script")
+	}
+	if err := verifyScript(nil, "", "51", [][]byte{script}, outscript.BtcVerifyAll); err == nil {
+		t.Errorf("expected error with unexpected witness")
+	}
+	if err := verifyScript(nil, "", "0014"+hex.EncodeToString(h[:20]), [][]byte{script}, outscript.BtcVerifyAll); err == nil {
+		t.Errorf("expected error with bad p2wpkh witness")
+	}
+	// future witness versions are anyone-can-spend
+	if err := verifyScript(nil, "", "5220"+hex.EncodeToString(h[:]), nil, outscript.BtcVerifyAll); err != nil {
+		t.Errorf("failed to verify witness v2: %s", err)
+	}
+}
+
+func TestBtcScriptTaproot(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	internalKey := must(outscript.New(key.PubKey()).Generate("pubkey:xonly"))
+
+	leaves := []*outscript.TaprootTree{
+		outscript.NewTaprootLeaf([]byte{0x51}),                         // OP_TRUE
+		outscript.NewTaprootLeaf([]byte{0x63, 0x51, 0x67, 0x00, 0x68}), // IF 1 ELSE 0 ENDIF
+		outscript.NewTaprootLeaf([]byte{0x00, 0x00, 0x00, 0xae}),       // CHECKMULTISIG
+		outscript.NewTaprootLeaf([]byte{0x00, 0x7e}),                   // OP_SUCCESS126
+		{Script: []byte{0x00}, Version: 0xc2},                          // unknown leaf version
+	}
+	tree := outscript.NewTaprootTree(leaves...)
+	out := hex.EncodeToString(must(tree.Out(internalKey)).Bytes())
+	spend := func(leaf *outscript.TaprootTree, stack ...[]byte) [][]byte {
+		return append(stack, leaf.Script, must(tree.ControlBlock(internalKey, leaf)))
+	}
+
+	vectors := []struct {
+		witness [][]byte
+		ok      bool
+	}{
+		{spend(leaves[0]), true},
+		{spend(leaves[0], []byte{1}), false}, // not a clean stack
+		{spend(leaves[1], []byte{1}), true},
+		{spend(leaves[1], []byte{2}), false}, // non minimal IF argument
+		{spend(leaves[1], []byte{}), false},
+		{spend(leaves[2]), false}, // disabled in tapscript
+		{spend(leaves[3]), true},
+		{spend(leaves[4]), true},
+		{append(spend(leaves[0]), []byte{0x50, 1, 2, 3}), true}, // annex
+		{[][]byte{leaves[0].Script, must(tree.ControlBlock(internalKey, leaves[1]))}, false},
+		{[][]byte{make([]byte, 64)}, false}, // invalid key path signature
+		{nil, false},
+	}
+	for n, v := range vectors {
+		err := verifyScript(nil, "", out, v.witness, outscript.BtcVerifyAll)
+		if v.ok && err != nil {
+			t.Errorf("vector %d: unexpected error: %s", n, err)
+		} else if !v.ok && err == nil {
+			t.Errorf("vector %d: expected error", n)
+		}
+	}
+
+	// witness v1 outputs are not checked without BIP-341
+	if err := verifyScript(nil, "", out, nil, outscript.BtcVerifyP2SH|outscript.BtcVerifyWitness); err != nil {
+		t.Errorf("expected success without taproot rules: %s", err)
+	}
+}
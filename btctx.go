@@ -3,7 +3,6 @@ package outscript
 import (
 	"bytes"
 	"crypto"
-	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/hex"
@@ -12,6 +11,7 @@ import (
 	"fmt"
 	"io"
 	"slices"
+	"strings"
 
 	"github.com/BottleFmt/gobottle"
 	"golang.org/x/crypto/ripemd160"
@@ -72,26 +72,35 @@ type BtcTxOutput struct {
 
 // BtcTxSign holds the signing parameters for a single transaction input.
 type BtcTxSign struct {
-	Key     crypto.Signer
-	Options crypto.SignerOpts
-	Scheme  string    // "p2pk", "p2wpkh", "p2wsh:p2pkh", etc
-	Amount  BtcAmount // value of input, required for segwit transaction signing
-	SigHash uint32
-}
-
-// Sign will perform signature on the transaction
+	Key        crypto.Signer
+	Options    crypto.SignerOpts
+	Scheme     string        // "p2pk", "p2wpkh", "p2wsh:p2pkh", etc
+	Amount     BtcAmount     // value of input, required for segwit transaction signing
+	SigHash    uint32        // SigHashAll if zero, can be combined with SigHashAnyoneCanPay and SigHashForkID
+	PrevScript []byte        // scriptPubKey of the spent output, generated from Scheme if nil (taproot signing needs all of them)
+	Taproot    *TaprootSpend // taproot script tree details, for "p2tr" outputs with a script tree and "p2tr:script"
+	Multisig   *Multisig     // multisig script, for "p2sh:multisig", "p2wsh:multisig" and "p2sh:p2wsh:multisig"
+}
+
+// Sign will perform signature on the transaction. Entries with a nil Key leave the matching
+// input untouched, which allows signing inputs separately, or signing a single input with
+// multiple keys one call at a time (multisig and taproot script-path inputs keep the
+// signatures already present).
 func (tx *BtcTx) Sign(keys ...*BtcTxSign) error {
 	if len(tx.In) == 0 || len(tx.In) != len(keys) {
 		return errors.New("Sign requires as many keys as there are inputs")
 	}
 
-	wtx := tx.Dup() // work tx, used for signing/etc
-	var pfx, sfx []byte
+	var prevOuts []*BtcTxOutput
 	var err error
 
 	for n, k := range keys {
-		if k.SigHash == 0 {
-			k.SigHash = 1 // default to SIGHASH_ALL
+		if k.Key == nil {
+			// this input is signed separately
+			continue
+		}
+		if k.SigHash == 0 && !strings.HasPrefix(k.Scheme, "p2tr") {
+			k.SigHash = SigHashAll // taproot has its own SIGHASH_DEFAULT
 		}
 		if k.Options == nil {
 			k.Options = crypto.SHA256
@@ -99,45 +108,25 @@ func (tx *BtcTx) Sign(keys ...*BtcTxSign) error {
 
 		switch k.Scheme {
 		case "p2pk":
-			wtx.ClearInputs()
-			wtx.In[n].Script, err = New(k.Key.Public()).Generate("p2pk")
+			script, err := New(k.Key.Public()).Generate("p2pk")
 			if err != nil {
 				return err
 			}
-			buf := wtx.exportBytes(false)
-			buf = binary.LittleEndian.AppendUint32(buf, k.SigHash)
-			signHash := gobottle.Hash(buf, sha256.New, sha256.New)
-			sign, err := k.Key.Sign(rand.Reader, signHash, k.Options)
+			sign, err := k.sign(tx.signHash(n, k, script, false))
 			if err != nil {
 				return err
 			}
-			sign = append(sign, byte(k.SigHash&0xff))
 			tx.In[n].Script = PushBytes(sign)
 		case "p2pkh", "p2pukh":
-			if k.SigHash&0x40 == 0x40 {
-				// bitcoin-cash style sig. the preimage is the same as segwit
-				if pfx == nil {
-					pfx, sfx = tx.preimage()
-				}
-				err := tx.p2wpkhSign(n, k, pfx, sfx)
-				if err != nil {
-					return err
-				}
-				break
-			}
-			wtx.ClearInputs()
-			wtx.In[n].Script, err = New(k.Key.Public()).Generate(k.Scheme)
+			// with SIGHASH_FORKID this is a bitcoin-cash signature, using the segwit algorithm
+			script, err := New(k.Key.Public()).Generate(k.Scheme)
 			if err != nil {
 				return err
 			}
-			buf := wtx.exportBytes(false)
-			buf = binary.LittleEndian.AppendUint32(buf, k.SigHash)
-			signHash := gobottle.Hash(buf, sha256.New, sha256.New)
-			sign, err := k.Key.Sign(rand.Reader, signHash, k.Options)
+			sign, err := k.sign(tx.signHash(n, k, script, false))
 			if err != nil {
 				return err
 			}
-			sign = append(sign, byte(k.SigHash&0xff))
 			var pubkey []byte
 			if k.Scheme == "p2pkh" {
 				pubkey, err = New(k.Key.Public()).Generate("pubkey:comp")
@@ -149,20 +138,41 @@ func (tx *BtcTx) Sign(keys ...*BtcTxSign) error {
 			}
 			tx.In[n].Script = slices.Concat(PushBytes(sign), PushBytes(pubkey))
 		case "p2wpkh", "p2sh:p2wpkh":
-			if pfx == nil {
-				pfx, sfx = tx.preimage()
+			err := tx.p2wpkhSign(n, k)
+			if err != nil {
+				return err
+			}
+		case "p2wsh", "p2wsh:p2pk", "p2wsh:p2puk", "p2wsh:p2pkh", "p2wsh:p2pukh":
+			err := tx.p2wshSign(n, k)
+			if err != nil {
+				return err
+			}
+		case "p2sh:multisig", "p2wsh:multisig", "p2sh:p2wsh:multisig":
+			err := tx.multisigSign(n, k)
+			if err != nil {
+				return err
+			}
+		case "p2tr":
+			if prevOuts == nil {
+				prevOuts, err = signPrevOuts(keys)
+				if err != nil {
+					return err
+				}
 			}
 
-			err := tx.p2wpkhSign(n, k, pfx, sfx)
+			err := tx.p2trSign(n, k, prevOuts)
 			if err != nil {
 				return err
 			}
-		case "p2wsh", "p2wsh:p2pk", "p2wsh:p2puk", "p2wsh:p2pkh", "p2wsh:p2pukh":
-			if pfx == nil {
-				pfx, sfx = tx.preimage()
+		case "p2tr:script":
+			if prevOuts == nil {
+				prevOuts, err = signPrevOuts(keys)
+				if err != nil {
+					return err
+				}
 			}
 
-			err := tx.p2wshSign(n, k, pfx, sfx)
+			err := tx.p2trScriptSign(n, k, prevOuts)
 			if err != nil {
 				return err
 			}
@@ -173,58 +183,61 @@ func (tx *BtcTx) Sign(keys ...*BtcTxSign) error {
 	return nil
 }
 
-func (tx *BtcTx) p2wpkhSign(n int, k *BtcTxSign, pfx, sfx []byte) error {
-	if pfx == nil {
-		pfx, sfx = tx.preimage()
+// signPrevOuts returns the outputs spent by the inputs matching keys, as needed for taproot signatures
+func signPrevOuts(keys []*BtcTxSign) ([]*BtcTxOutput, error) {
+	res := make([]*BtcTxOutput, len(keys))
+	for n, k := range keys {
+		script := k.PrevScript
+		if script == nil {
+			if k.Key == nil {
+				return nil, fmt.Errorf("input %d: unable to determine spent output script, please set PrevScript", n)
+			}
+			var err error
+			if strings.HasPrefix(k.Scheme, "p2tr") {
+				script, err = k.Taproot.outScript(k.Key.Public())
+			} else if k.Multisig != nil {
+				var out *Out
+				out, err = k.Multisig.Out(strings.TrimSuffix(k.Scheme, ":multisig"))
+				if err == nil {
+					script = out.Bytes()
+				}
+			} else {
+				script, err = New(k.Key.Public()).Generate(k.Scheme)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("input %d: unable to determine spent output script, please set PrevScript: %w", n, err)
+			}
+		}
+		res[n] = &BtcTxOutput{Amount: k.Amount, N: n, Script: script}
 	}
+	return res, nil
+}
 
-	// prepare values for segwit signature
-	var pubKey []byte
-	var err error
-
-	if k.Scheme == "p2pukh" {
-		pubKey, err = New(k.Key.Public()).Generate("pubkey:uncomp")
-	} else {
-		pubKey, err = New(k.Key.Public()).Generate("pubkey:comp")
-	}
+func (tx *BtcTx) p2wpkhSign(n int, k *BtcTxSign) error {
+	pubKey, err := New(k.Key.Public()).Generate("pubkey:comp")
 	if err != nil {
 		return err
 	}
-	input, inputSeq := tx.In[n].preimageBytes()
 	pkHash := gobottle.Hash(pubKey, sha256.New, ripemd160.New)
 	scriptCode := append(append([]byte{0x76, 0xa9}, PushBytes(pkHash)...), 0x88, 0xac)
-	amount := binary.LittleEndian.AppendUint64(nil, uint64(k.Amount))
 
-	// perform signature
-	signString := slices.Concat(pfx, input, PushBytes(scriptCode), amount, inputSeq, sfx)
-	signString = binary.LittleEndian.AppendUint32(signString, k.SigHash)
-	signHash := gobottle.Hash(signString, sha256.New, sha256.New)
-	sign, err := k.Key.Sign(rand.Reader, signHash, k.Options)
+	sign, err := k.sign(tx.witnessSigHash(n, scriptCode, k.Amount, k.SigHash))
 	if err != nil {
 		return err
 	}
-	sign = append(sign, byte(k.SigHash&0xff))
 
+	tx.In[n].Witnesses = [][]byte{sign, pubKey}
 	switch k.Scheme {
-	case "p2pkh", "p2pukh":
-		// segwit preimage-style signature, as used by bitcoincash with forkid
-		tx.In[n].Script = slices.Concat(PushBytes(sign), PushBytes(pubKey))
 	case "p2wpkh":
-		tx.In[n].Witnesses = [][]byte{sign, pubKey}
 		tx.In[n].Script = nil
 	case "p2sh:p2wpkh":
-		tx.In[n].Witnesses = [][]byte{sign, pubKey}
 		// 1716001479091972186c449eb1ded22b78e40d009bdf0089
 		tx.In[n].Script = PushBytes(append([]byte{0}, PushBytes(pkHash)...))
 	}
 	return nil
 }
 
-func (tx *BtcTx) p2wshSign(n int, k *BtcTxSign, pfx, sfx []byte) error {
-	if pfx == nil {
-		pfx, sfx = tx.preimage()
-	}
-
+func (tx *BtcTx) p2wshSign(n int, k *BtcTxSign) error {
 	var witnessScript []byte
 	var innerScheme string
 	var err error
@@ -247,17 +260,10 @@ func (tx *BtcTx) p2wshSign(n int, k *BtcTxSign, pfx, sfx []byte) error {
 	}
 
 	// BIP-143 signing with witness script as scriptCode
-	input, inputSeq := tx.In[n].preimageBytes()
-	amount := binary.LittleEndian.AppendUint64(nil, uint64(k.Amount))
-
-	signString := slices.Concat(pfx, input, PushBytes(witnessScript), amount, inputSeq, sfx)
-	signString = binary.LittleEndian.AppendUint32(signString, k.SigHash)
-	signHash := gobottle.Hash(signString, sha256.New, sha256.New)
-	sign, err := k.Key.Sign(rand.Reader, signHash, k.Options)
+	sign, err := k.sign(tx.witnessSigHash(n, witnessScript, k.Amount, k.SigHash))
 	if err != nil {
 		return err
 	}
-	sign = append(sign, byte(k.SigHash&0xff))
 
 	// build witness stack based on inner script type
 	switch innerScheme {
@@ -320,32 +326,6 @@ func (tx *BtcTx) detectP2WSHInner(n int, pub crypto.PublicKey) (string, []byte,
 	return "", nil, errors.New("p2wsh: unable to generate any witness script from the provided key")
 }
 
-// preimage computes the segwit preimage prefix/suffix. The return parts are in brackets below:
-//
-//	preimage = [version + hash256(inputs) + hash256(sequences)] + input + scriptcode + amount + sequence + [hash256(outputs) + locktime]
-func (tx *BtcTx) preimage() ([]byte, []byte) {
-	vers := binary.LittleEndian.AppendUint32(nil, tx.Version)
-	inputsA := sha256.New()
-	inputsB := sha256.New()
-	for _, in := range tx.In {
-		a, b := in.preimageBytes()
-		inputsA.Write(a)
-		inputsB.Write(b)
-	}
-
-	prefix := slices.Concat(vers, gobottle.Hash(inputsA.Sum(nil), sha256.New), gobottle.Hash(inputsB.Sum(nil), sha256.New))
-
-	outputs := sha256.New()
-	for _, out := range tx.Out {
-		outputs.Write(out.Bytes())
-	}
-	locktime := binary.LittleEndian.AppendUint32(nil, tx.Locktime)
-
-	suffix := slices.Concat(gobottle.Hash(outputs.Sum(nil), sha256.New), locktime)
-
-	return prefix, suffix
-}
-
 // MarshalBinary implements [encoding.BinaryMarshaler] and returns the serialized transaction bytes.
 func (tx *BtcTx) MarshalBinary() ([]byte, error) {
 	return tx.Bytes(), nil
@@ -414,34 +394,9 @@ func (tx *BtcTx) ClearInputs() {
 	}
 }
 
-// EstimateSize computes the transaction size, taking into account specific rules for segwit.
+// ComputeSize returns the virtual size of the transaction, see [BtcTx.VSize].
 func (tx *BtcTx) ComputeSize() int {
-	// a tx contains fixed parts: version (4 bytes), number of inputs (1 byte usually), number of outputs (1 byte usually), locktime (4 bytes)
-	ln := 4 + BtcVarInt(len(tx.In)).Len() + BtcVarInt(len(tx.Out)).Len() + 4
-	witln := 0
-
-	for _, in := range tx.In {
-		ln += in.computeSize()
-		witln += in.computeWitnessSize()
-	}
-
-	for _, out := range tx.Out {
-		ln += out.computeSize()
-	}
-
-	if !tx.HasWitness() {
-		return ln
-	}
-
-	witln += 2 // marker, flag
-
-	// witness data counts as 0.25 per byte, but we want to ceil it, check if it's divisible or not
-	add := witln % 4
-	if add != 0 {
-		add = 1
-	}
-
-	return ln + witln/4 + add
+	return tx.VSize()
 }
 
 // exportBytes returns the bytes data for a given transaction
@@ -487,12 +442,18 @@ func (tx *BtcTx) UnmarshalBinary(buf []byte) error {
 
 // ReadFrom reads and parses a Bitcoin transaction from r, including segwit witness data if present.
 func (tx *BtcTx) ReadFrom(r io.Reader) (int64, error) {
+	return tx.readFrom(r, true)
+}
+
+// readFrom reads a transaction from r. When allowWitness is false the transaction is always
+// read in the legacy format, so that a transaction without inputs can be parsed.
+func (tx *BtcTx) readFrom(r io.Reader, allowWitness bool) (int64, error) {
 	h := &readHelper{R: r}
 	tx.Version = h.readUint32le()
 	var inCnt BtcVarInt
 	h.readTo(&inCnt)
 	segwit := false
-	if inCnt == 0 {
+	if inCnt == 0 && allowWitness {
 		// likely segwit tx
 		segwit = true
 		h.readByte() // segwit flag, not sure what to do with this for now
@@ -572,6 +533,8 @@ var (
 	prefillP2PKH          = slices.Concat(PushBytes(prefillEmptySig), PushBytes(prefillEmptyCompKey))
 	prefillP2PUKH         = slices.Concat(PushBytes(prefillEmptySig), PushBytes(prefillEmptyUncompKey))
 	prefillP2WPKH         = [][]byte{prefillEmptySig, prefillEmptyCompKey}
+	prefillP2SHP2WPKH     = PushBytes(make([]byte, 22)) // 0 <20-byte hash>
+	prefillP2TR           = [][]byte{make([]byte, 64)}  // schnorr signature
 
 	// p2wsh witness prefill data: [sig, witnessScript] or [sig, pubkey, witnessScript]
 	prefillEmptyP2PKScript  = make([]byte, 35) // <push33> <33-byte key> OP_CHECKSIG
@@ -600,6 +563,15 @@ func (in *BtcTxInput) Prefill(scheme string) error {
 		in.Script = nil
 		in.Witnesses = prefillP2WPKH
 		return nil
+	case "p2sh:p2wpkh":
+		in.Script = prefillP2SHP2WPKH
+		in.Witnesses = prefillP2WPKH
+		return nil
+	case "p2tr":
+		// key path spending with SIGHASH_DEFAULT, other sighash types add one byte
+		in.Script = nil
+		in.Witnesses = prefillP2TR
+		return nil
 	case "p2wsh:p2pk":
 		in.Script = nil
 		in.Witnesses = prefillP2WSHP2PK
@@ -0,0 +1,243 @@
+package outscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// BtcVerifyFlags selects the consensus rules enforced when verifying transaction inputs.
+type BtcVerifyFlags uint32
+
+const (
+	BtcVerifyP2SH      BtcVerifyFlags = 1 << iota // BIP-16 pay to script hash
+	BtcVerifyWitness                              // BIP-141 segregated witness
+	BtcVerifyNullDummy                            // BIP-147 OP_CHECKMULTISIG dummy must be empty
+	BtcVerifyCLTV                                 // BIP-65 OP_CHECKLOCKTIMEVERIFY
+	BtcVerifyCSV                                  // BIP-112 OP_CHECKSEQUENCEVERIFY
+	BtcVerifyTaproot                              // BIP-341/342 taproot and tapscript
+	BtcVerifyForkID                               // bitcoin-cash signatures, requires SIGHASH_FORKID
+
+	// BtcVerifyAll enables all the rules currently enforced by the bitcoin network
+	BtcVerifyAll = BtcVerifyP2SH | BtcVerifyWitness | BtcVerifyNullDummy | BtcVerifyCLTV | BtcVerifyCSV | BtcVerifyTaproot
+)
+
+// VerifyInput checks that input n validly spends prevOut, by executing its script and witness
+// against the output script with all the consensus rules enabled. Taproot signatures commit to
+// all the outputs spent by the transaction, use [BtcTx.Verify] to check those.
+func (tx *BtcTx) VerifyInput(n int, prevOut *BtcTxOutput) error {
+	if n < 0 || n >= len(tx.In) {
+		return fmt.Errorf("invalid input index %d", n)
+	}
+	prevOuts := make([]*BtcTxOutput, len(tx.In))
+	prevOuts[n] = prevOut
+	return tx.VerifyInputFlags(n, prevOuts, BtcVerifyAll)
+}
+
+// Verify checks all the inputs of the transaction, prevOuts holding the outputs spent by each
+// input, in order.
+func (tx *BtcTx) Verify(prevOuts []*BtcTxOutput) error {
+	if len(prevOuts) != len(tx.In) {
+		return errors.New("Verify requires as many spent outputs as there are inputs")
+	}
+	for n := range tx.In {
+		if err := tx.VerifyInputFlags(n, prevOuts, BtcVerifyAll); err != nil {
+			return fmt.Errorf("input %d: %w", n, err)
+		}
+	}
+	return nil
+}
+
+// VerifyInputFlags checks input n using the given rules. prevOuts must hold the outputs spent by
+// each input in order, entries other than n can be nil if no taproot signature needs them.
+func (tx *BtcTx) VerifyInputFlags(n int, prevOuts []*BtcTxOutput, flags BtcVerifyFlags) error {
+	if n < 0 || n >= len(tx.In) {
+		return fmt.Errorf("invalid input index %d", n)
+	}
+	if len(prevOuts) != len(tx.In) || prevOuts[n] == nil {
+		return errors.New("spent output is required for verification")
+	}
+	in := tx.In[n]
+	prevScript := prevOuts[n].Script
+	e := &btcScriptEngine{tx: tx, n: n, prevOuts: prevOuts, flags: flags}
+
+	if err := e.execute(in.Script); err != nil {
+		return fmt.Errorf("input script: %w", err)
+	}
+	p2shStack := slices.Clone(e.stack)
+	if err := e.execute(prevScript); err != nil {
+		return err
+	}
+	if len(e.stack) == 0 || !castToBool(e.stack[len(e.stack)-1]) {
+		return errors.New("script evaluated to false")
+	}
+
+	hadWitness := false
+	if flags&BtcVerifyWitness != 0 {
+		if version, program, ok := witnessProgram(prevScript); ok {
+			hadWitness = true
+			if len(in.Script) != 0 {
+				return errors.New("witness program spend requires an empty input script")
+			}
+			if err := e.verifyWitnessProgram(version, program, in.Witnesses, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	if flags&BtcVerifyP2SH != 0 && btcScriptType(prevScript) == "p2sh" {
+		if !isPushOnly(in.Script) {
+			return errors.New("p2sh input script must only contain pushes")
+		}
+		if len(p2shStack) == 0 {
+			return errStackUnderflow
+		}
+		redeem := p2shStack[len(p2shStack)-1]
+		e.stack = p2shStack[:len(p2shStack)-1]
+		e.sigVersion = sigVersionBase
+		if err := e.execute(redeem); err != nil {
+			return fmt.Errorf("redeem script: %w", err)
+		}
+		if len(e.stack) == 0 || !castToBool(e.stack[len(e.stack)-1]) {
+			return errors.New("redeem script evaluated to false")
+		}
+		if flags&BtcVerifyWitness != 0 {
+			if version, program, ok := witnessProgram(redeem); ok {
+				hadWitness = true
+				if !bytes.Equal(in.Script, PushBytes(redeem)) {
+					return errors.New("nested witness program input script must be a single push")
+				}
+				if err := e.verifyWitnessProgram(version, program, in.Witnesses, true); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if flags&BtcVerifyWitness != 0 && !hadWitness && len(in.Witnesses) > 0 {
+		return errors.New("unexpected witness")
+	}
+	return nil
+}
+
+// witnessProgram returns the version and program of a segwit output script
+func witnessProgram(script []byte) (int, []byte, bool) {
+	if len(script) < 4 || len(script) > 42 || int(script[1]) != len(script)-2 {
+		return 0, nil, false
+	}
+	switch {
+	case script[0] == 0:
+		return 0, script[2:], true
+	case script[0] >= 0x51 && script[0] <= 0x60:
+		return int(script[0] - 0x50), script[2:], true
+	}
+	return 0, nil, false
+}
+
+// verifyWitnessProgram checks the witness against a segwit program. Unknown witness versions
+// are accepted as they are reserved for future upgrades.
+func (e *btcScriptEngine) verifyWitnessProgram(version int, program []byte, witness [][]byte, p2sh bool) error {
+	stack := slices.Clone(witness)
+	switch {
+	case version == 0 && len(program) == 32:
+		if len(stack) == 0 {
+			return errors.New("empty witness")
+		}
+		script := stack[len(stack)-1]
+		if h := sha256.Sum256(script); !bytes.Equal(h[:], program) {
+			return errors.New("witness script does not match the witness program")
+		}
+		return e.executeWitness(script, stack[:len(stack)-1], sigVersionWitnessV0)
+	case version == 0 && len(program) == 20:
+		if len(stack) != 2 {
+			return errors.New("p2wpkh witness must hold exactly 2 elements")
+		}
+		script := slices.Concat([]byte{0x76, 0xa9}, PushBytes(program), []byte{0x88, 0xac})
+		return e.executeWitness(script, stack, sigVersionWitnessV0)
+	case version == 0:
+		return errors.New("invalid witness program length")
+	case version == 1 && len(program) == 32 && !p2sh && e.flags&BtcVerifyTaproot != 0:
+		return e.verifyTaproot(program, stack)
+	}
+	return nil
+}
+
+// verifyTaproot checks a taproot key-path or script-path spend of the given output key
+func (e *btcScriptEngine) verifyTaproot(outputKey []byte, stack [][]byte) error {
+	if len(stack) == 0 {
+		return errors.New("empty witness")
+	}
+	if last := stack[len(stack)-1]; len(stack) >= 2 && len(last) > 0 && last[0] == 0x50 {
+		e.annex = last
+		stack = stack[:len(stack)-1]
+	}
+	if len(stack) == 1 {
+		return e.verifySchnorr(outputKey, stack[0])
+	}
+
+	control := stack[len(stack)-1]
+	script := stack[len(stack)-2]
+	stack = stack[:len(stack)-2]
+	if len(control) < 33 || len(control) > 33+128*32 || (len(control)-33)%32 != 0 {
+		return errors.New("invalid taproot control block size")
+	}
+	leaf := &TaprootTree{Script: script, Version: control[0] &^ 1}
+	leafHash := leaf.Hash()
+	k := leafHash
+	for i := 33; i < len(control); i += 32 {
+		node := control[i : i+32]
+		if bytes.Compare(k, node) < 0 {
+			k = taggedHash("TapBranch", k, node)
+		} else {
+			k = taggedHash("TapBranch", node, k)
+		}
+	}
+	key, odd, err := taprootTweakPubKey(control[1:33], k)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(key, outputKey) || odd != (control[0]&1 == 1) {
+		return errors.New("taproot control block does not match the output key")
+	}
+	if leaf.Version != TaprootLeafVersion {
+		// unknown leaf version, reserved for future upgrades
+		return nil
+	}
+
+	for pc := 0; pc < len(script); {
+		op, _, next, err := readScriptOp(script, pc)
+		if err != nil {
+			return err
+		}
+		if isOpSuccess(op) {
+			return nil
+		}
+		pc = next
+	}
+	if len(stack) > maxStackSize {
+		return errors.New("script stack size exceeded")
+	}
+	e.leafHash = leafHash
+	e.budget = 50 + e.tx.In[e.n].computeWitnessSize()
+	return e.executeWitness(script, stack, sigVersionTapscript)
+}
+
+// executeWitness runs a witness script, which must leave a single true value on the stack
+func (e *btcScriptEngine) executeWitness(script []byte, stack [][]byte, sigVersion int) error {
+	for _, v := range stack {
+		if len(v) > maxScriptElementSize {
+			return errors.New("witness element exceeds maximum size")
+		}
+	}
+	e.stack = stack
+	e.sigVersion = sigVersion
+	if err := e.execute(script); err != nil {
+		return fmt.Errorf("witness script: %w", err)
+	}
+	if len(e.stack) != 1 || !castToBool(e.stack[0]) {
+		return errors.New("witness script did not leave a single true value")
+	}
+	return nil
+}
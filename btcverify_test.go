@@ -0,0 +1,140 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestBtcTxVerifyMainnet(t *testing.T) {
+	// legacy transaction spending 3 p2pkh outputs with uncompressed keys
+	tx := &outscript.BtcTx{}
+	err := tx.UnmarshalBinary(must(hex.DecodeString("0100000003362c10b042d48378b428d60c5c98d8b8aca7a03e1a2ca1048bfd469934bbda95010000008b483045022046c8bc9fb0e063e2fc8c6b1084afe6370461c16cbf67987d97df87827917d42d022100c807fa0ab95945a6e74c59838cc5f9e850714d8850cec4db1e7f3bcf71d5f5ef0141044450af01b4cc0d45207bddfb47911744d01f768d23686e9ac784162a5b3a15bc01e6653310bdd695d8c35d22e9bb457563f8de116ecafea27a0ec831e4a3e9feffffffffc19529a54ae15c67526cc5e20e535973c2d56ef35ff51bace5444388331c4813000000008b48304502201738185959373f04cc73dbbb1d061623d51dc40aac0220df56dabb9b80b72f49022100a7f76bde06369917c214ee2179e583fefb63c95bf876eb54d05dfdf0721ed772014104e6aa2cf108e1c650e12d8dd7ec0a36e478dad5a5d180585d25c30eb7c88c3df0c6f5fd41b3e70b019b777abd02d319bf724de184001b3d014cb740cb83ed21a6ffffffffbaae89b5d2e3ca78fd3f13cf0058784e7c089fb56e1e596d70adcfa486603967010000008b483045022055efbaddb4c67c1f1a46464c8f770aab03d6b513779ad48735d16d4c5b9907c2022100f469d50a5e5556fc2c932645f6927ac416aa65bc83d58b888b82c3220e1f0b73014104194b3f8aa08b96cae19b14bd6c32a92364bea3051cb9f018b03e3f09a57208ff058f4b41ebf96b9911066aef3be22391ac59175257af0984d1432acb8f2aefcaffffffff0340420f00000000001976a914c0fbb13eb10b57daa78b47660a4ffb79c29e2e6b88ac204e0000000000001976a9142cae94ffdc05f8214ccb2b697861c9c07e3948ee88ac1c2e0100000000001976a9146e03561cd4d6033456cc9036d409d2bf82721e9888ac00000000")))
+	if err != nil {
+		t.Fatalf("failed to parse tx: %s", err)
+	}
+	var prevOuts []*outscript.BtcTxOutput
+	for _, in := range tx.In {
+		// the spent script pays to the key found in the input script
+		_, n := outscript.ParsePushBytes(in.Script)
+		pub, _ := outscript.ParsePushBytes(in.Script[n:])
+		key := must(secp256k1.ParsePubKey(pub))
+		prevOuts = append(prevOuts, &outscript.BtcTxOutput{Script: must(outscript.New(key).Generate("p2pukh"))})
+	}
+	if err := tx.Verify(prevOuts); err != nil {
+		t.Errorf("failed to verify mainnet tx: %s", err)
+	}
+	for n := range tx.In {
+		if err := tx.VerifyInput(n, prevOuts[n]); err != nil {
+			t.Errorf("failed to verify input %d: %s", n, err)
+		}
+	}
+
+	// spending with another key's script fails
+	if err := tx.VerifyInput(0, prevOuts[1]); err == nil {
+		t.Errorf("expected error verifying against the wrong output")
+	}
+	// changing an output invalidates the signatures
+	tx.Out[0].Amount++
+	if err := tx.VerifyInput(1, prevOuts[1]); err == nil {
+		t.Errorf("expected error verifying modified tx")
+	}
+}
+
+func TestBtcTxVerifySchemes(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	schemes := []string{"p2pk", "p2pkh", "p2pukh", "p2wpkh", "p2sh:p2wpkh", "p2wsh:p2pk", "p2wsh:p2puk", "p2wsh:p2pkh", "p2wsh:p2pukh", "p2tr"}
+
+	tx := &outscript.BtcTx{Version: 2}
+	var keys []*outscript.BtcTxSign
+	var prevOuts []*outscript.BtcTxOutput
+	for n, scheme := range schemes {
+		tx.In = append(tx.In, &outscript.BtcTxInput{TXID: outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f"))), Vout: uint32(n), Sequence: 0xffffffff})
+		keys = append(keys, &outscript.BtcTxSign{Key: key, Scheme: scheme, Amount: outscript.BtcAmount(10000 * (n + 1))})
+		prevOuts = append(prevOuts, &outscript.BtcTxOutput{Amount: outscript.BtcAmount(10000 * (n + 1)), Script: must(outscript.New(key.PubKey()).Generate(scheme))})
+	}
+	if err := tx.AddNetOutput("bitcoin", "bc1qar0srrr7xfkvy5l643lydnw9re59gtzzwf5mdq", 500000); err != nil {
+		t.Fatalf("failed to add output: %s", err)
+	}
+	if err := tx.Sign(keys...); err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if err := tx.Verify(prevOuts); err != nil {
+		t.Fatalf("failed to verify signed tx: %s", err)
+	}
+
+	// all but taproot can be checked without the other spent outputs
+	for n, scheme := range schemes {
+		err := tx.VerifyInput(n, prevOuts[n])
+		if scheme == "p2tr" {
+			if err == nil {
+				t.Errorf("expected error verifying p2tr input alone")
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: failed to verify input: %s", scheme, err)
+		}
+	}
+
+	// segwit and taproot signatures commit to the spent amount
+	for n, scheme := range schemes {
+		bad := append([]*outscript.BtcTxOutput{}, prevOuts...)
+		bad[n] = &outscript.BtcTxOutput{Amount: prevOuts[n].Amount + 1, Script: prevOuts[n].Script}
+		err := tx.VerifyInputFlags(n, bad, outscript.BtcVerifyAll)
+		switch scheme {
+		case "p2pk", "p2pkh", "p2pukh":
+			if err != nil {
+				t.Errorf("%s: legacy signature should not depend on amount: %s", scheme, err)
+			}
+		default:
+			if err == nil {
+				t.Errorf("%s: expected error with wrong amount", scheme)
+			}
+		}
+	}
+
+	// damaged witnesses and scripts are rejected
+	for n, scheme := range schemes {
+		in := tx.In[n].Dup()
+		if len(tx.In[n].Witnesses) > 0 {
+			tx.In[n].Witnesses[0][10] ^= 1
+		} else {
+			tx.In[n].Script[10] ^= 1
+		}
+		if err := tx.VerifyInputFlags(n, prevOuts, outscript.BtcVerifyAll); err == nil {
+			t.Errorf("%s: expected error with damaged signature", scheme)
+		}
+		tx.In[n] = in
+	}
+	if err := tx.Verify(prevOuts); err != nil {
+		t.Fatalf("failed to verify restored tx: %s", err)
+	}
+
+	// without the witness flag, segwit outputs are anyone-can-spend
+	tx.In[3].Witnesses = nil
+	if err := tx.VerifyInputFlags(3, prevOuts, outscript.BtcVerifyP2SH); err != nil {
+		t.Errorf("expected p2wpkh to pass without witness rules: %s", err)
+	}
+	if err := tx.VerifyInputFlags(3, prevOuts, outscript.BtcVerifyAll); err == nil {
+		t.Errorf("expected error spending p2wpkh without witness")
+	}
+}
+
+func TestBtcTxVerifyBIP143(t *testing.T) {
+	// signed BIP-143 native p2wpkh example
+	tx := &outscript.BtcTx{}
+	err := tx.UnmarshalBinary(must(hex.DecodeString("01000000000102fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f00000000494830450221008b9d1dc26ba6a9cb62127b02742fa9d754cd3bebf337f7a55d114c8e5cdd30be022040529b194ba3f9281a99f2b1c0a19c0489bc22ede944ccf4ecbab4cc618ef3ed01eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac000247304402203609e17b84f6a7d30c80bfa610b5b4542f32a8a0d5447a12fb1366d7f01cc44a0220573a954c4518331561406f90300e8f3358f51928d43c212a8caed02de67eebee0121025476c2e83188368da1ff3e292e7acafcdb3566bb0ad253f62fc70f07aeee635711000000")))
+	if err != nil {
+		t.Fatalf("failed to parse tx: %s", err)
+	}
+	prevOuts := []*outscript.BtcTxOutput{
+		{Amount: 625000000, Script: must(hex.DecodeString("2103c9f4836b9a4f77fc0d81f7bcb01b7f1b35916864b9476c241ce9fc198bd25432ac"))},
+		{Amount: 600000000, Script: must(hex.DecodeString("00141d0f172a0ecb48aee1be1f2687d2963ae33f71a1"))},
+	}
+	if err := tx.Verify(prevOuts); err != nil {
+		t.Errorf("failed to verify BIP-143 example: %s", err)
+	}
+}
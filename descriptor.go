@@ -0,0 +1,694 @@
+package outscript
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/KarpelesLab/secp256k1"
+)
+
+const (
+	descriptorInputCharset    = "0123456789()[],'/*abcdefgh@:$%{}IJKLMNOPQRSTUVWXYZ&+-.;<=>?!^_|~ijklmnopqrstuvwxyzABCDEFGH`#\"\\ "
+	descriptorChecksumCharset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+)
+
+// Descriptor is an output script descriptor as defined in BIP-380 and related BIPs. The
+// supported expressions are pk, pkh, wpkh, sh, wsh, multi, sortedmulti, tr (with pk, multi_a and
+// sortedmulti_a leaves), addr and raw. Keys can be hex public keys or extended keys with an
+// optional origin and derivation path, possibly ending in a * range.
+type Descriptor struct {
+	desc string // descriptor without checksum
+	root *descNode
+}
+
+// descNode is a script expression of a descriptor
+type descNode struct {
+	fn          string     // pk, wpkh, sh, multi, etc, or "{}" for taproot tree branches
+	k           int        // threshold of multisig expressions
+	keys        []*descKey // key arguments, for tr the internal key
+	sub         *descNode  // sh and wsh content, or tr script tree
+	left, right *descNode  // taproot tree branches
+	out         *Out       // fixed output for addr and raw
+}
+
+// descKey is a key expression of a descriptor
+type descKey struct {
+	pub         []byte // fixed public key, compressed, uncompressed or x-only
+	hd          *HDKey
+	path        []uint32 // derivation path from hd
+	ranged      bool     // path ends in *
+	wildcard    uint32   // 0 or HDHardened, added to the index for ranged keys
+	origin      []uint32 // path from the master key to the key, if an origin was given
+	fingerprint uint32   // master key fingerprint, if an origin was given
+	hasOrigin   bool
+}
+
+// DescriptorChecksum returns the 8 characters checksum of desc, which must not include a checksum.
+func DescriptorChecksum(desc string) (string, error) {
+	polymod := func(c uint64, val int) uint64 {
+		c0 := c >> 35
+		c = ((c & 0x7ffffffff) << 5) ^ uint64(val)
+		for i, g := range []uint64{0xf5dee51989, 0xa9fdca3312, 0x1bab10e32d, 0x3706b1677a, 0x644d626ffd} {
+			if c0&(1<<i) != 0 {
+				c ^= g
+			}
+		}
+		return c
+	}
+
+	c := uint64(1)
+	cls, clsCount := 0, 0
+	for _, ch := range desc {
+		pos := strings.IndexRune(descriptorInputCharset, ch)
+		if pos == -1 {
+			return "", fmt.Errorf("invalid character %q in descriptor", ch)
+		}
+		c = polymod(c, pos&31)
+		cls = cls*3 + pos>>5
+		if clsCount++; clsCount == 3 {
+			c = polymod(c, cls)
+			cls, clsCount = 0, 0
+		}
+	}
+	if clsCount > 0 {
+		c = polymod(c, cls)
+	}
+	for range 8 {
+		c = polymod(c, 0)
+	}
+	c ^= 1
+
+	res := make([]byte, 8)
+	for j := range res {
+		res[j] = descriptorChecksumCharset[(c>>(5*(7-j)))&31]
+	}
+	return string(res), nil
+}
+
+// ParseDescriptor parses a descriptor such as "wpkh([d34db33f/84'/0'/0']xpub.../0/*)". The
+// checksum is optional, but must be valid if present.
+func ParseDescriptor(s string) (*Descriptor, error) {
+	desc, sum, found := strings.Cut(s, "#")
+	expected, err := DescriptorChecksum(desc)
+	if err != nil {
+		return nil, err
+	}
+	if found && sum != expected {
+		return nil, errors.New("invalid descriptor checksum")
+	}
+	root, err := parseDescNode(desc, "")
+	if err != nil {
+		return nil, err
+	}
+	return &Descriptor{desc: desc, root: root}, nil
+}
+
+// parseDescNode parses the expression s, found within the parent expression (empty at the top
+// level)
+func parseDescNode(s, parent string) (*descNode, error) {
+	name, args, ok := strings.Cut(s, "(")
+	if !ok || !strings.HasSuffix(args, ")") {
+		return nil, fmt.Errorf("invalid descriptor expression %q", s)
+	}
+	args = args[:len(args)-1]
+	n := &descNode{fn: name}
+
+	allowed := map[string][]string{
+		"":    {"pk", "pkh", "wpkh", "sh", "wsh", "tr", "addr", "raw"},
+		"sh":  {"wpkh", "wsh", "multi", "sortedmulti"},
+		"wsh": {"pk", "pkh", "multi", "sortedmulti"},
+		"tr":  {"pk", "multi_a", "sortedmulti_a"},
+	}
+	if !slices.Contains(allowed[parent], name) {
+		if parent == "sh" && (name == "pk" || name == "pkh") {
+			return nil, fmt.Errorf("sh(%s()) descriptors are not supported as they cannot be signed, use wsh(%s()) or %s() instead", name, name, name)
+		}
+		if parent == "" {
+			return nil, fmt.Errorf("unsupported descriptor expression %s()", name)
+		}
+		return nil, fmt.Errorf("%s() is not allowed within %s()", name, parent)
+	}
+	segwit := parent == "wsh" || name == "wpkh"
+
+	switch name {
+	case "pk", "pkh", "wpkh":
+		key, err := parseDescKey(args, segwit, parent == "tr")
+		if err != nil {
+			return nil, err
+		}
+		n.keys = []*descKey{key}
+	case "sh", "wsh":
+		sub, err := parseDescNode(args, name)
+		if err != nil {
+			return nil, err
+		}
+		if sub.fn == "wsh" && !strings.HasSuffix(sub.sub.fn, "multi") {
+			return nil, errors.New("sh(wsh()) descriptors are only supported with multisig")
+		}
+		n.sub = sub
+	case "multi", "sortedmulti", "multi_a", "sortedmulti_a":
+		list := splitDescArgs(args)
+		if _, err := fmt.Sscanf(list[0], "%d", &n.k); err != nil || fmt.Sprint(n.k) != list[0] {
+			return nil, fmt.Errorf("invalid multisig threshold %q", list[0])
+		}
+		for _, arg := range list[1:] {
+			key, err := parseDescKey(arg, segwit, parent == "tr")
+			if err != nil {
+				return nil, err
+			}
+			n.keys = append(n.keys, key)
+		}
+		maxKeys := 16
+		if parent == "tr" {
+			maxKeys = 999
+		}
+		if n.k < 1 || n.k > len(n.keys) || len(n.keys) > maxKeys {
+			return nil, fmt.Errorf("invalid multisig %d-of-%d", n.k, len(n.keys))
+		}
+	case "tr":
+		list := splitDescArgs(args)
+		if len(list) > 2 {
+			return nil, errors.New("tr() takes at most 2 arguments")
+		}
+		key, err := parseDescKey(list[0], true, true)
+		if err != nil {
+			return nil, err
+		}
+		n.keys = []*descKey{key}
+		if len(list) == 2 {
+			if n.sub, err = parseDescTree(list[1]); err != nil {
+				return nil, err
+			}
+		}
+	case "addr":
+		out, err := ParseBitcoinBasedAddress("auto", args)
+		if err != nil {
+			return nil, err
+		}
+		n.out = out
+	case "raw":
+		script, err := hex.DecodeString(args)
+		if err != nil {
+			return nil, err
+		}
+		n.out = GuessOut(script, nil)
+	}
+	return n, nil
+}
+
+// parseDescTree parses a taproot script tree, made of leaves and {left,right} branches
+func parseDescTree(s string) (*descNode, error) {
+	if !strings.HasPrefix(s, "{") {
+		return parseDescNode(s, "tr")
+	}
+	if !strings.HasSuffix(s, "}") {
+		return nil, fmt.Errorf("invalid taproot tree %q", s)
+	}
+	list := splitDescArgs(s[1 : len(s)-1])
+	if len(list) != 2 {
+		return nil, errors.New("taproot tree branches must have 2 elements")
+	}
+	left, err := parseDescTree(list[0])
+	if err != nil {
+		return nil, err
+	}
+	right, err := parseDescTree(list[1])
+	if err != nil {
+		return nil, err
+	}
+	return &descNode{fn: "{}", left: left, right: right}, nil
+}
+
+// splitDescArgs splits s at the commas that are not within parentheses, brackets or braces
+func splitDescArgs(s string) []string {
+	var res []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				res = append(res, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, s[start:])
+}
+
+// parseDescKey parses a key expression. Segwit scripts only allow compressed keys, and x-only
+// keys are only allowed in taproot.
+func parseDescKey(s string, segwit, taproot bool) (*descKey, error) {
+	k := &descKey{}
+	// the key origin documents where the key comes from, it is kept for psbt derivations
+	if rest, ok := strings.CutPrefix(s, "["); ok {
+		origin, key, ok := strings.Cut(rest, "]")
+		if !ok {
+			return nil, fmt.Errorf("invalid key origin in %q", s)
+		}
+		fp, path, hasPath := strings.Cut(origin, "/")
+		buf, err := hex.DecodeString(fp)
+		if err != nil || len(buf) != 4 {
+			return nil, fmt.Errorf("invalid key origin fingerprint %q", fp)
+		}
+		k.fingerprint = binary.BigEndian.Uint32(buf)
+		k.hasOrigin = true
+		if hasPath {
+			if k.origin, err = ParseHDPath(path); err != nil {
+				return nil, err
+			}
+		}
+		s = key
+	}
+
+	if buf, err := hex.DecodeString(s); err == nil {
+		pub := buf
+		switch {
+		case len(buf) == 32 && taproot:
+			pub = append([]byte{2}, buf...)
+		case len(buf) == 33:
+		case len(buf) == 65 && !segwit && !taproot:
+		default:
+			return nil, fmt.Errorf("invalid public key %s in this context", s)
+		}
+		if _, err := secp256k1.ParsePubKey(pub); err != nil {
+			return nil, fmt.Errorf("invalid public key %s: %w", s, err)
+		}
+		k.pub = buf
+		return k, nil
+	}
+
+	elems := strings.Split(s, "/")
+	hd, err := ParseHDKey(elems[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid key %q: %w", elems[0], err)
+	}
+	k.hd = hd
+	elems = elems[1:]
+	if l := len(elems) - 1; l >= 0 {
+		switch elems[l] {
+		case "*":
+			k.ranged = true
+			elems = elems[:l]
+		case "*'", "*h", "*H":
+			k.ranged = true
+			k.wildcard = HDHardened
+			elems = elems[:l]
+		}
+	}
+	if len(elems) > 0 {
+		if elems[0] == "m" {
+			return nil, fmt.Errorf("invalid derivation path in key %q", s)
+		}
+		if k.path, err = ParseHDPath(strings.Join(elems, "/")); err != nil {
+			return nil, err
+		}
+	}
+	if !hd.IsPrivate() && (k.wildcard != 0 || slices.ContainsFunc(k.path, func(i uint32) bool { return i >= HDHardened })) {
+		return nil, errors.New("hardened derivation requires a private key")
+	}
+	return k, nil
+}
+
+// derivationPath returns the derivation path from hd at index
+func (k *descKey) derivationPath(index uint32) ([]uint32, error) {
+	if !k.ranged {
+		return k.path, nil
+	}
+	if index >= HDHardened {
+		return nil, fmt.Errorf("invalid descriptor index %d", index)
+	}
+	return append(slices.Clone(k.path), index|k.wildcard), nil
+}
+
+// pubKey returns the public key at index, in the format it was given in for fixed keys and
+// compressed for extended keys
+func (k *descKey) pubKey(index uint32) ([]byte, error) {
+	if k.hd == nil {
+		return k.pub, nil
+	}
+	path, err := k.derivationPath(index)
+	if err != nil {
+		return nil, err
+	}
+	hd := k.hd
+	for _, i := range path {
+		if hd, err = hd.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return hd.PubKey().SerializeCompressed(), nil
+}
+
+// derivation returns the psbt derivation of the key at index, with pubKey as the key. Keys
+// without origin use the fingerprint of the extended key, fixed keys without origin return nil.
+func (k *descKey) derivation(index uint32, pubKey []byte) (*PsbtDerivation, error) {
+	d := &PsbtDerivation{PubKey: pubKey}
+	switch {
+	case k.hasOrigin:
+		d.Fingerprint = k.fingerprint
+		d.Path = slices.Clone(k.origin)
+	case k.hd != nil:
+		d.Fingerprint = k.hd.Fingerprint()
+	default:
+		return nil, nil
+	}
+	if k.hd != nil {
+		path, err := k.derivationPath(index)
+		if err != nil {
+			return nil, err
+		}
+		d.Path = append(d.Path, path...)
+	}
+	return d, nil
+}
+
+// xonlyKey returns the x-only public key at index
+func (k *descKey) xonlyKey(index uint32) ([]byte, error) {
+	pub, err := k.pubKey(index)
+	if err != nil {
+		return nil, err
+	}
+	if len(pub) == 32 {
+		return pub, nil
+	}
+	return pub[1:], nil
+}
+
+// String returns the descriptor with its checksum.
+func (d *Descriptor) String() string {
+	sum, _ := DescriptorChecksum(d.desc)
+	return d.desc + "#" + sum
+}
+
+// IsRange returns true if the descriptor has keys ending in a * range, producing a different
+// output script for each index.
+func (d *Descriptor) IsRange() bool {
+	return d.root.isRange()
+}
+
+func (n *descNode) isRange() bool {
+	if n == nil {
+		return false
+	}
+	for _, k := range n.keys {
+		if k.ranged {
+			return true
+		}
+	}
+	return n.sub.isRange() || n.left.isRange() || n.right.isRange()
+}
+
+// Scheme returns the [BtcTxSign] scheme for spending the outputs of the descriptor, such as
+// "p2wpkh", "p2sh:p2wsh:multisig" or "p2tr", or an empty string for addr and raw descriptors.
+func (d *Descriptor) Scheme() string {
+	return d.root.scheme()
+}
+
+func (n *descNode) scheme() string {
+	switch n.fn {
+	case "pk", "pkh":
+		if len(n.keys[0].pub) == 65 {
+			return "p2" + strings.Replace(n.fn, "k", "uk", 1)
+		}
+		return "p2" + n.fn
+	case "wpkh":
+		return "p2wpkh"
+	case "sh", "wsh":
+		return "p2" + n.fn + ":" + n.sub.scheme()
+	case "multi", "sortedmulti":
+		return "multisig"
+	case "tr":
+		return "p2tr"
+	default:
+		return ""
+	}
+}
+
+// inner returns the innermost expression of sh and wsh
+func (n *descNode) inner() *descNode {
+	for n.fn == "sh" || n.fn == "wsh" {
+		n = n.sub
+	}
+	return n
+}
+
+// Out returns the output for the given index. The index is ignored if the descriptor has no range.
+func (d *Descriptor) Out(index uint32) (*Out, error) {
+	n := d.root
+	scheme := n.scheme()
+	switch inner := n.inner(); inner.fn {
+	case "addr", "raw":
+		return n.out, nil
+	case "multi", "sortedmulti":
+		ms, err := inner.multisig(index)
+		if err != nil {
+			return nil, err
+		}
+		return ms.Out(strings.TrimSuffix(scheme, ":multisig"))
+	case "tr":
+		internalKey, err := n.keys[0].xonlyKey(index)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := n.sub.tapTree(index)
+		if err != nil {
+			return nil, err
+		}
+		return tree.Out(internalKey)
+	default:
+		pub, err := inner.keys[0].pubKey(index)
+		if err != nil {
+			return nil, err
+		}
+		key, err := secp256k1.ParsePubKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		return New(key).Out(scheme)
+	}
+}
+
+// Outs returns the outputs for indexes from start to end (excluded). A descriptor without range
+// always returns a single output.
+func (d *Descriptor) Outs(start, end uint32) ([]*Out, error) {
+	if !d.IsRange() {
+		end = start + 1
+	}
+	var res []*Out
+	for i := start; i < end; i++ {
+		out, err := d.Out(i)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, out)
+	}
+	return res, nil
+}
+
+// multisig returns the multisig script of a multi or sortedmulti expression at index
+func (n *descNode) multisig(index uint32) (*Multisig, error) {
+	ms := &Multisig{M: n.k}
+	for _, k := range n.keys {
+		pub, err := k.pubKey(index)
+		if err != nil {
+			return nil, err
+		}
+		ms.PubKeys = append(ms.PubKeys, pub)
+	}
+	if n.fn == "sortedmulti" {
+		slices.SortFunc(ms.PubKeys, bytes.Compare)
+	}
+	return ms, ms.check()
+}
+
+// tapTree returns the taproot script tree at index, nil for a nil node
+func (n *descNode) tapTree(index uint32) (*TaprootTree, error) {
+	if n == nil {
+		return nil, nil
+	}
+	if n.fn == "{}" {
+		left, err := n.left.tapTree(index)
+		if err != nil {
+			return nil, err
+		}
+		right, err := n.right.tapTree(index)
+		if err != nil {
+			return nil, err
+		}
+		return NewTaprootBranch(left, right), nil
+	}
+
+	var keys [][]byte
+	for _, k := range n.keys {
+		pub, err := k.xonlyKey(index)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pub)
+	}
+	if n.fn == "pk" {
+		return NewTaprootLeaf(slices.Concat(PushBytes(keys[0]), []byte{0xac})), nil
+	}
+
+	// multi_a: <key1> OP_CHECKSIG <key2> OP_CHECKSIGADD ... <k> OP_NUMEQUAL
+	if n.fn == "sortedmulti_a" {
+		slices.SortFunc(keys, bytes.Compare)
+	}
+	var script []byte
+	for i, k := range keys {
+		script = append(script, PushBytes(k)...)
+		if i == 0 {
+			script = append(script, 0xac)
+		} else {
+			script = append(script, 0xba)
+		}
+	}
+	if n.k <= 16 {
+		script = append(script, byte(0x50+n.k))
+	} else {
+		script = append(script, PushBytes(scriptNumBytes(int64(n.k)))...)
+	}
+	return NewTaprootLeaf(append(script, 0x9c)), nil
+}
+
+// SignParams returns the signing parameters for spending amount from the output at index with
+// key, with the scheme, previous output script and multisig or taproot details set. For tr
+// descriptors with a script tree, the key path is used if key is the internal key, otherwise
+// the first leaf using key.
+func (d *Descriptor) SignParams(index uint32, key crypto.Signer, amount BtcAmount) (*BtcTxSign, error) {
+	out, err := d.Out(index)
+	if err != nil {
+		return nil, err
+	}
+	res := &BtcTxSign{Key: key, Scheme: d.Scheme(), Amount: amount, PrevScript: out.Bytes()}
+	n := d.root
+	switch inner := n.inner(); inner.fn {
+	case "addr", "raw":
+		return nil, fmt.Errorf("%s() descriptors have no spending information", inner.fn)
+	case "multi", "sortedmulti":
+		ms, err := inner.multisig(index)
+		if err != nil {
+			return nil, err
+		}
+		if ms.index(key.Public()) == -1 {
+			return nil, errors.New("signing key is not part of the multisig script")
+		}
+		res.Multisig = ms
+		return res, nil
+	case "tr":
+		xonly, err := New(key.Public()).Generate("pubkey:xonly")
+		if err != nil {
+			return nil, err
+		}
+		internalKey, err := n.keys[0].xonlyKey(index)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := n.sub.tapTree(index)
+		if err != nil {
+			return nil, err
+		}
+		if tree != nil {
+			res.Taproot = &TaprootSpend{InternalKey: internalKey, Tree: tree}
+		}
+		if bytes.Equal(xonly, internalKey) {
+			return res, nil
+		}
+		for _, leaf := range tree.Leaves() {
+			if slices.ContainsFunc(tapscriptKeys(leaf.Script), func(k []byte) bool { return bytes.Equal(k, xonly) }) {
+				res.Scheme = "p2tr:script"
+				res.Taproot.Leaf = leaf
+				return res, nil
+			}
+		}
+		return nil, errors.New("signing key is not part of the taproot output")
+	default:
+		script, err := New(key.Public()).Generate(res.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(script, res.PrevScript) {
+			return nil, errors.New("signing key does not match the descriptor")
+		}
+		return res, nil
+	}
+}
+
+// Derivations returns the origin of the keys of the descriptor at index, as found in the key
+// origin or derived from the extended key. These can be set as the [PsbtInput] Derivations, or
+// TapDerivations for tr descriptors. Fixed keys without origin are skipped.
+func (d *Descriptor) Derivations(index uint32) ([]*PsbtDerivation, error) {
+	return d.root.derivations(index, nil, nil)
+}
+
+// derivations appends the derivations of the keys of n to res. leafHash is the hash of the
+// taproot leaf n is part of, if any.
+func (n *descNode) derivations(index uint32, leafHash []byte, res []*PsbtDerivation) ([]*PsbtDerivation, error) {
+	if n == nil {
+		return res, nil
+	}
+	taproot := n.fn == "tr" || leafHash != nil
+	for _, k := range n.keys {
+		var pub []byte
+		var err error
+		if taproot {
+			pub, err = k.xonlyKey(index)
+		} else {
+			pub, err = k.pubKey(index)
+		}
+		if err != nil {
+			return nil, err
+		}
+		d, err := k.derivation(index, pub)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			continue
+		}
+		// a taproot key found in several leaves has a single derivation listing all of them
+		pos := slices.IndexFunc(res, func(v *PsbtDerivation) bool { return bytes.Equal(v.PubKey, pub) })
+		if pos == -1 {
+			res = append(res, d)
+			pos = len(res) - 1
+		}
+		if leafHash != nil {
+			res[pos].LeafHashes = append(res[pos].LeafHashes, leafHash)
+		}
+	}
+
+	switch {
+	case n.fn == "tr" || n.fn == "{}":
+		// the script tree of tr, or a taproot branch
+		var err error
+		for _, sub := range []*descNode{n.sub, n.left, n.right} {
+			if sub == nil {
+				continue
+			}
+			var hash []byte
+			if sub.fn != "{}" {
+				leaf, err := sub.tapTree(index)
+				if err != nil {
+					return nil, err
+				}
+				hash = leaf.Hash()
+			}
+			if res, err = sub.derivations(index, hash, res); err != nil {
+				return nil, err
+			}
+		}
+		return res, nil
+	default:
+		return n.sub.derivations(index, nil, res)
+	}
+}
@@ -0,0 +1,194 @@
+package outscript_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestDescriptorChecksum(t *testing.T) {
+	// BIP-380 checksum test vectors
+	d := must(outscript.ParseDescriptor("raw(deadbeef)#89f8spxm"))
+	if d.String() != "raw(deadbeef)#89f8spxm" {
+		t.Errorf("bad descriptor string %s", d)
+	}
+	if d := must(outscript.ParseDescriptor("raw(deadbeef)")); d.String() != "raw(deadbeef)#89f8spxm" {
+		t.Errorf("checksum not added to %s", d)
+	}
+	for _, s := range []string{
+		"raw(deadbeef)#",
+		"raw(deadbeef)#89f8spxmx",
+		"raw(deadbeef)#89f8spx",
+		"raw(deedbeef)#89f8spxm",
+		"raw(deadbeef)##9f8spxm",
+		"raw(Ü)#00000000",
+	} {
+		if _, err := outscript.ParseDescriptor(s); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
+
+func TestDescriptorOuts(t *testing.T) {
+	vectors := []struct {
+		desc, scheme string
+		scripts      []string
+	}{
+		{"wpkh(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)", "p2wpkh", []string{"00149a1c78a507689f6f54b847ad1cef1e614ee23f1e"}},
+		{"sh(wpkh(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd))", "p2sh:p2wpkh", []string{"a91484ab21b1b2fd065d4504ff693d832434b6108d7b87"}},
+		{"pkh(04a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd5b8dec5235a0fa8722476c7709c02559e3aa73aa03918ba2d492eea75abea235)", "p2pukh", []string{"76a914b5bd079c4d57cc7fc28ecf8213a6b791625b818388ac"}},
+		{"pk(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)", "p2pk", []string{"2103a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bdac"}},
+		{"sh(wsh(sortedmulti(1,03f28773c2d975288bc7d1d205c3748651b075fbc6610e58cddeeddf8f19405aa8,03499fdf9e895e719cfd64e67f07d38e3226aa7b63678949e6e49b241a60e823e4,02d7924d4f7d43ea965a465ae3095ff41131e5946f3c85f79e44adbcf8e27e080e)))", "p2sh:p2wsh:multisig", []string{"a9140b9120bc8738931149aa7ec51a28f15500032b7687"}},
+		{"sh(wsh(multi(1,03f28773c2d975288bc7d1d205c3748651b075fbc6610e58cddeeddf8f19405aa8,03499fdf9e895e719cfd64e67f07d38e3226aa7b63678949e6e49b241a60e823e4,02d7924d4f7d43ea965a465ae3095ff41131e5946f3c85f79e44adbcf8e27e080e)))", "p2sh:p2wsh:multisig", []string{"a914aec509e284f909f769bb7dda299a717c87cc97ac87"}},
+		// BIP-386
+		{"tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)", "p2tr", []string{"512077aab6e066f8a7419c5ab714c12c67d25007ed55a43cadcacb4d7a970a093f11"}},
+		{"tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd,pk(669b8afcec803a0d323e9a17f3ea8e68e8abe5a278020a929adbec52421adbd0))", "p2tr", []string{"512017cf18db381d836d8923b1bdb246cfcd818da1a9f0e6e7907f187f0b2f937754"}},
+		{"tr(xprv9s21ZrQH143K3QTDL4LXw2F7HEK3wJUD2nW2nRk4stbPy6cq3jPPqjiChkVvvNKmPGJxWUtg6LnF5kejMRNNU3TGtRBeJgk33yuGBxrMPHi/0/*)", "p2tr", []string{
+			"5120426e2260470e2ce836014beb79e86185161e8503c5d6235131b1ddf602fb3734",
+			"5120680d6a0649dab14cffebd7b851c83d9372a07c328230aad79a0d401ead2ae235",
+			"5120375683e009c7edb1371054de44c94ad3c36230dc84d51b38cf177a73c3394a43",
+		}},
+		{"addr(bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu)", "", []string{"0014c0cebcd6c3d3ca8c75dc5ec62ebe55330ef910e2"}},
+	}
+
+	for _, v := range vectors {
+		d, err := outscript.ParseDescriptor(v.desc)
+		if err != nil {
+			t.Errorf("failed to parse %s: %s", v.desc, err)
+			continue
+		}
+		if d.Scheme() != v.scheme {
+			t.Errorf("bad scheme %s for %s", d.Scheme(), v.desc)
+		}
+		if d.IsRange() != (len(v.scripts) > 1) {
+			t.Errorf("bad range for %s", v.desc)
+		}
+		outs := must(d.Outs(0, uint32(len(v.scripts))))
+		for n, out := range outs {
+			if out.Script != v.scripts[n] {
+				t.Errorf("bad script %d for %s: %s", n, v.desc, out.Script)
+			}
+		}
+	}
+}
+
+func TestDescriptorInvalid(t *testing.T) {
+	for _, s := range []string{
+		"wpkh(04a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd5b8dec5235a0fa8722476c7709c02559e3aa73aa03918ba2d492eea75abea235)",
+		"pkh(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+		"wpkh(xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8/0'/*)",
+		"wpkh(xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8/*')",
+		"wpkh([d34db3/84'/0'/0']03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+		"wsh(wpkh(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd))",
+		"sh(wsh(pk(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)))",
+		"sh(sh(pk(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)))",
+		"sh(pk(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd))",
+		"sh(pkh(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd))",
+		"wsh(multi(3,03f28773c2d975288bc7d1d205c3748651b075fbc6610e58cddeeddf8f19405aa8,03499fdf9e895e719cfd64e67f07d38e3226aa7b63678949e6e49b241a60e823e4))",
+		"multi(1,03f28773c2d975288bc7d1d205c3748651b075fbc6610e58cddeeddf8f19405aa8)",
+		"tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd,{pk(669b8afcec803a0d323e9a17f3ea8e68e8abe5a278020a929adbec52421adbd0)})",
+		"tr(a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd,wpkh(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd))",
+		"combo(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd)",
+		"wpkh(03a34b99f22c790c4e36b2b3c2c35a36db06226e41c692fc82b8b56ac1c540c5bd",
+	} {
+		if _, err := outscript.ParseDescriptor(s); err == nil {
+			t.Errorf("expected error for %s", s)
+		}
+	}
+}
+
+func TestDescriptorSign(t *testing.T) {
+	master := must(outscript.NewHDKeyFromMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", ""))
+	account := must(master.Derive("m/84'/0'/0'"))
+	xpub := must(must(account.Neuter()).WithPrefix("xpub"))
+
+	// BIP-84 wallet
+	d := must(outscript.ParseDescriptor(fmt.Sprintf("wpkh([%08x/84'/0'/0']%s/0/*)", master.Fingerprint(), xpub)))
+	if addr := must(must(d.Out(0)).Address("bitcoin")); addr != "bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu" {
+		t.Errorf("bad BIP-84 address %s", addr)
+	}
+	if outs := must(d.Outs(0, 20)); len(outs) != 20 || outs[1].Script == outs[0].Script {
+		t.Errorf("bad range expansion")
+	}
+	// the key origin is kept for psbt derivations
+	if v := must(d.Derivations(5)); len(v) != 1 || v[0].Fingerprint != master.Fingerprint() || !slices.Equal(v[0].Path, must(outscript.ParseHDPath("84'/0'/0'/0/5"))) || !bytes.Equal(v[0].PubKey, must(account.Derive("0/5")).PubKey().SerializeCompressed()) {
+		t.Errorf("bad BIP-84 derivations")
+	}
+
+	// the descriptor also gives the details needed to spend its outputs
+	txid := outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f")))
+	keyA := must(account.Derive("0/5")).PrivKey()
+	keyB := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	xonlyB := hex.EncodeToString(keyB.PubKey().SerializeCompressed()[1:])
+	change := must(must(account.Derive("1")).Neuter())
+
+	descs := []struct {
+		desc   string
+		index  uint32
+		key    *secp256k1.PrivateKey
+		scheme string
+	}{
+		{d.String(), 5, keyA, "p2wpkh"},
+		{fmt.Sprintf("sh(wpkh(%s/0/*))", xpub), 5, keyA, "p2sh:p2wpkh"},
+		{fmt.Sprintf("pkh(%s/0/*)", xpub), 5, keyA, "p2pkh"},
+		{fmt.Sprintf("wsh(pkh(%s/0/*))", xpub), 5, keyA, "p2wsh:p2pkh"},
+		{fmt.Sprintf("wsh(pk(%x))", keyB.PubKey().SerializeCompressed()), 0, keyB, "p2wsh:p2pk"},
+		{fmt.Sprintf("wsh(sortedmulti(1,%s/0/*,%x))", xpub, keyB.PubKey().SerializeCompressed()), 5, keyB, "p2wsh:multisig"},
+		{fmt.Sprintf("sh(multi(2,%s/0/*,%x))", xpub, keyB.PubKey().SerializeCompressed()), 5, keyA, "p2sh:multisig"},
+		{fmt.Sprintf("tr(%s/0/*)", xpub), 5, keyA, "p2tr"},
+		{fmt.Sprintf("tr(%s/0/*,{pk(%s),sortedmulti_a(1,%s/*,%s)})", xpub, xonlyB, change, xonlyB), 5, keyA, "p2tr"},
+		{fmt.Sprintf("tr(%s/1/*,{pk(%s/*),pk(%s)})", xpub, change, xonlyB), 3, keyB, "p2tr:script"},
+		{fmt.Sprintf("tr(%s/1/*,multi_a(1,%s/*,%s))", xpub, change, xonlyB), 3, keyB, "p2tr:script"},
+	}
+	for _, v := range descs {
+		d := must(outscript.ParseDescriptor(v.desc))
+		k, err := d.SignParams(v.index, v.key, 100000)
+		if err != nil {
+			t.Errorf("failed to get sign parameters for %s: %s", v.desc, err)
+			continue
+		}
+		if k.Scheme != v.scheme {
+			t.Errorf("bad scheme %s for %s", k.Scheme, v.desc)
+		}
+		tx := &outscript.BtcTx{Version: 2}
+		tx.In = append(tx.In, &outscript.BtcTxInput{TXID: txid, Sequence: 0xffffffff})
+		tx.Out = append(tx.Out, &outscript.BtcTxOutput{Amount: 90000, Script: must(d.Out(v.index)).Bytes()})
+		if err := tx.Sign(k); err != nil {
+			t.Errorf("failed to sign %s: %s", v.desc, err)
+			continue
+		}
+		if v.scheme == "p2sh:multisig" {
+			// 2-of-2, the other key signs too
+			k2 := must(d.SignParams(v.index, keyB, 100000))
+			if err := tx.Sign(k2); err != nil {
+				t.Errorf("failed to sign %s: %s", v.desc, err)
+			}
+		}
+		if err := tx.Verify([]*outscript.BtcTxOutput{{Amount: 100000, Script: must(d.Out(v.index)).Bytes()}}); err != nil {
+			t.Errorf("failed to verify %s: %s", v.desc, err)
+		}
+	}
+
+	// taproot derivations are x-only and list the leaves using the key, fixed keys without
+	// origin are skipped
+	td := must(outscript.ParseDescriptor(fmt.Sprintf("tr([%08x/84'/0'/0']%s/0/*,{pk(%s/*),pk(%s)})", master.Fingerprint(), xpub, change, xonlyB)))
+	v := must(td.Derivations(3))
+	if len(v) != 2 || len(v[0].PubKey) != 32 || len(v[0].LeafHashes) != 0 || !slices.Equal(v[0].Path, must(outscript.ParseHDPath("84'/0'/0'/0/3"))) {
+		t.Errorf("bad taproot internal key derivation")
+	}
+	if len(v) == 2 && (v[1].Fingerprint != change.Fingerprint() || !slices.Equal(v[1].Path, []uint32{3}) || len(v[1].LeafHashes) != 1) {
+		t.Errorf("bad taproot leaf key derivation")
+	}
+
+	if _, err := d.SignParams(4, keyA, 100000); err == nil {
+		t.Errorf("expected error for a key not matching the descriptor")
+	}
+	if _, err := must(outscript.ParseDescriptor("addr(bc1qcr8te4kr609gcawutmrza0j4xv80jy8z306fyu)")).SignParams(0, keyA, 100000); err == nil {
+		t.Errorf("expected error for addr descriptor")
+	}
+}
@@ -0,0 +1,241 @@
+package outscript
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/BottleFmt/gobottle"
+	"golang.org/x/crypto/sha3"
+)
+
+// EvmTypedField is a member of an EIP-712 struct type.
+type EvmTypedField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// EvmTypedData holds EIP-712 typed structured data, in the JSON format used by
+// eth_signTypedData_v4. Values are the result of JSON decoding: map[string]any for structs,
+// []any for arrays, strings or json.Number for numbers, 0x-prefixed hex strings for bytes.
+type EvmTypedData struct {
+	Types       map[string][]EvmTypedField `json:"types"`
+	PrimaryType string                     `json:"primaryType"`
+	Domain      map[string]any             `json:"domain"`
+	Message     map[string]any             `json:"message"`
+}
+
+// evmDomainFields lists the fields of EIP712Domain in their standard order
+var evmDomainFields = []EvmTypedField{
+	{Name: "name", Type: "string"},
+	{Name: "version", Type: "string"},
+	{Name: "chainId", Type: "uint256"},
+	{Name: "verifyingContract", Type: "address"},
+	{Name: "salt", Type: "bytes32"},
+}
+
+// ParseEvmTypedData parses EIP-712 typed data from its JSON representation. If types does not
+// define EIP712Domain, it is built from the fields present in domain.
+func ParseEvmTypedData(buf []byte) (*EvmTypedData, error) {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	td := &EvmTypedData{}
+	if err := dec.Decode(td); err != nil {
+		return nil, err
+	}
+	if td.PrimaryType == "" {
+		return nil, errors.New("typed data primaryType is missing")
+	}
+	if td.Types == nil {
+		td.Types = make(map[string][]EvmTypedField)
+	}
+	if _, ok := td.Types["EIP712Domain"]; !ok {
+		var fields []EvmTypedField
+		for _, f := range evmDomainFields {
+			if _, ok := td.Domain[f.Name]; ok {
+				fields = append(fields, f)
+			}
+		}
+		td.Types["EIP712Domain"] = fields
+	}
+	return td, nil
+}
+
+// evmTypeBase returns the struct or elementary type of typ, without array dimensions
+func evmTypeBase(typ string) string {
+	if pos := strings.IndexByte(typ, '['); pos >= 0 {
+		return typ[:pos]
+	}
+	return typ
+}
+
+// dependencies appends to deps typ and all the struct types it references
+func (td *EvmTypedData) dependencies(typ string, deps []string) []string {
+	typ = evmTypeBase(typ)
+	fields, ok := td.Types[typ]
+	if !ok || slices.Contains(deps, typ) {
+		return deps
+	}
+	deps = append(deps, typ)
+	for _, f := range fields {
+		deps = td.dependencies(f.Type, deps)
+	}
+	return deps
+}
+
+// EncodeType returns the EIP-712 encoding of a struct type, such as
+// "Mail(Person from,Person to,string contents)Person(string name,address wallet)".
+func (td *EvmTypedData) EncodeType(typ string) (string, error) {
+	if _, ok := td.Types[typ]; !ok {
+		return "", fmt.Errorf("unknown typed data type %s", typ)
+	}
+	deps := td.dependencies(typ, nil)
+	slices.Sort(deps[1:])
+
+	var res strings.Builder
+	for _, dep := range deps {
+		res.WriteString(dep + "(")
+		for n, f := range td.Types[dep] {
+			if n > 0 {
+				res.WriteByte(',')
+			}
+			res.WriteString(f.Type + " " + f.Name)
+		}
+		res.WriteByte(')')
+	}
+	return res.String(), nil
+}
+
+// TypeHash returns the keccak256 hash of the encoded type.
+func (td *EvmTypedData) TypeHash(typ string) ([]byte, error) {
+	enc, err := td.EncodeType(typ)
+	if err != nil {
+		return nil, err
+	}
+	return gobottle.Hash([]byte(enc), sha3.NewLegacyKeccak256), nil
+}
+
+// HashStruct returns the EIP-712 hashStruct of data of the given struct type.
+func (td *EvmTypedData) HashStruct(typ string, data map[string]any) ([]byte, error) {
+	enc, err := td.encodeData(typ, data)
+	if err != nil {
+		return nil, err
+	}
+	return gobottle.Hash(enc, sha3.NewLegacyKeccak256), nil
+}
+
+// encodeData returns the typeHash of typ followed by the encoding of each of its fields
+func (td *EvmTypedData) encodeData(typ string, data map[string]any) ([]byte, error) {
+	res, err := td.TypeHash(typ)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range td.Types[typ] {
+		v, ok := data[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing value for %s.%s", typ, f.Name)
+		}
+		enc, err := td.encodeValue(f.Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", typ, f.Name, err)
+		}
+		res = append(res, enc...)
+	}
+	return res, nil
+}
+
+// encodeValue returns the 32 bytes encoding of a single value
+func (td *EvmTypedData) encodeValue(typ string, v any) ([]byte, error) {
+	if strings.HasSuffix(typ, "]") {
+		pos := strings.LastIndexByte(typ, '[')
+		if pos < 0 {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		arr, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("expected array value for %s, got %T", typ, v)
+		}
+		if size := typ[pos+1 : len(typ)-1]; size != "" {
+			if n, err := strconv.Atoi(size); err != nil || n != len(arr) {
+				return nil, fmt.Errorf("expected %s elements for %s, got %d", size, typ, len(arr))
+			}
+		}
+		var res []byte
+		for _, elem := range arr {
+			enc, err := td.encodeValue(typ[:pos], elem)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, enc...)
+		}
+		return gobottle.Hash(res, sha3.NewLegacyKeccak256), nil
+	}
+	if _, ok := td.Types[typ]; ok {
+		data, ok := v.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("expected struct value for %s, got %T", typ, v)
+		}
+		return td.HashStruct(typ, data)
+	}
+	switch typ {
+	case "string":
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string value, got %T", v)
+		}
+		return gobottle.Hash([]byte(s), sha3.NewLegacyKeccak256), nil
+	case "bytes":
+		b, err := abiBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		return gobottle.Hash(b, sha3.NewLegacyKeccak256), nil
+	}
+	return abiWord(typ, v)
+}
+
+// DomainSeparator returns the hashStruct of the domain.
+func (td *EvmTypedData) DomainSeparator() ([]byte, error) {
+	return td.HashStruct("EIP712Domain", td.Domain)
+}
+
+// Hash returns the EIP-712 digest to be signed, keccak256("\x19\x01" ‖ domainSeparator ‖
+// hashStruct(message)).
+func (td *EvmTypedData) Hash() ([]byte, error) {
+	sep, err := td.DomainSeparator()
+	if err != nil {
+		return nil, err
+	}
+	buf := slices.Concat([]byte{0x19, 0x01}, sep)
+	if td.PrimaryType != "EIP712Domain" {
+		h, err := td.HashStruct(td.PrimaryType, td.Message)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, h...)
+	}
+	return gobottle.Hash(buf, sha3.NewLegacyKeccak256), nil
+}
+
+// Sign signs the typed data with key, returning a 65 bytes r||s||v signature.
+func (td *EvmTypedData) Sign(key crypto.Signer) ([]byte, error) {
+	h, err := td.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return EvmSignHash(key, h)
+}
+
+// RecoverSigner returns the EIP-55 address of the key that produced sig for the typed data.
+func (td *EvmTypedData) RecoverSigner(sig []byte) (string, error) {
+	h, err := td.Hash()
+	if err != nil {
+		return "", err
+	}
+	return EvmRecoverHash(h, sig)
+}
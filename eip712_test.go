@@ -0,0 +1,130 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+// example from EIP-712
+const eip712Mail = `{
+	"types": {
+		"EIP712Domain": [
+			{"name": "name", "type": "string"},
+			{"name": "version", "type": "string"},
+			{"name": "chainId", "type": "uint256"},
+			{"name": "verifyingContract", "type": "address"}
+		],
+		"Person": [
+			{"name": "name", "type": "string"},
+			{"name": "wallet", "type": "address"}
+		],
+		"Mail": [
+			{"name": "from", "type": "Person"},
+			{"name": "to", "type": "Person"},
+			{"name": "contents", "type": "string"}
+		]
+	},
+	"primaryType": "Mail",
+	"domain": {"name": "Ether Mail", "version": "1", "chainId": 1, "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"},
+	"message": {
+		"from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+		"to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+		"contents": "Hello, Bob!"
+	}
+}`
+
+func TestEvmTypedDataMail(t *testing.T) {
+	td := must(outscript.ParseEvmTypedData([]byte(eip712Mail)))
+
+	if enc := must(td.EncodeType("Mail")); enc != "Mail(Person from,Person to,string contents)Person(string name,address wallet)" {
+		t.Errorf("bad encoded type %s", enc)
+	}
+	if h := hex.EncodeToString(must(td.TypeHash("Mail"))); h != "a0cedeb2dc280ba39b857546d74f5549c3a1d7bdc2dd96bf881f76108e23dac2" {
+		t.Errorf("bad type hash %s", h)
+	}
+	if h := hex.EncodeToString(must(td.HashStruct("Mail", td.Message))); h != "c52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e" {
+		t.Errorf("bad struct hash %s", h)
+	}
+	if h := hex.EncodeToString(must(td.DomainSeparator())); h != "f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f" {
+		t.Errorf("bad domain separator %s", h)
+	}
+	if h := hex.EncodeToString(must(td.Hash())); h != "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2" {
+		t.Errorf("bad digest %s", h)
+	}
+
+	// private key of Cow, keccak256("cow")
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("c85ef7d79691fe79573b1a7064c19c1a9819ebdbd1faaab1a8ec92344438aaf4")))
+	sig := must(td.Sign(key))
+	if hex.EncodeToString(sig) != "4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c" {
+		t.Errorf("bad signature %x", sig)
+	}
+	if addr := must(td.RecoverSigner(sig)); addr != "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826" {
+		t.Errorf("bad signer %s", addr)
+	}
+}
+
+func TestEvmTypedDataArrays(t *testing.T) {
+	// nested struct arrays, fixed arrays and all elementary types, EIP712Domain inferred from the domain
+	td := must(outscript.ParseEvmTypedData([]byte(`{
+		"types": {
+			"Order": [
+				{"name": "maker", "type": "address"},
+				{"name": "assets", "type": "Asset[]"},
+				{"name": "delta", "type": "int256"},
+				{"name": "partial", "type": "bool"},
+				{"name": "data", "type": "bytes"},
+				{"name": "selector", "type": "bytes4"},
+				{"name": "fees", "type": "uint16[2]"}
+			],
+			"Asset": [
+				{"name": "token", "type": "address"},
+				{"name": "amount", "type": "uint256"}
+			]
+		},
+		"primaryType": "Order",
+		"domain": {"name": "Exchange", "chainId": 137, "salt": "0x0000000000000000000000000000000000000000000000000000000000000001"},
+		"message": {
+			"maker": "0xcd2a3d9f938e13cd947ec05abc7fe734df8dd826",
+			"assets": [
+				{"token": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC", "amount": "1000000000000000000"},
+				{"token": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB", "amount": "0x2a"}
+			],
+			"delta": -5,
+			"partial": true,
+			"data": "0xdeadbeef",
+			"selector": "0xa9059cbb",
+			"fees": [30, 5]
+		}
+	}`)))
+
+	if enc := must(td.EncodeType("Order")); enc != "Order(address maker,Asset[] assets,int256 delta,bool partial,bytes data,bytes4 selector,uint16[2] fees)Asset(address token,uint256 amount)" {
+		t.Errorf("bad encoded type %s", enc)
+	}
+	if h := hex.EncodeToString(must(td.DomainSeparator())); h != "f733c69b0ad23c36a3a80020702720eb183a1ae6a51990b3c8dedf82ee42883d" {
+		t.Errorf("bad domain separator %s", h)
+	}
+	if h := hex.EncodeToString(must(td.HashStruct("Order", td.Message))); h != "dd36addfce062ac8ce611acdabcdd2fe1b2e5fe7fde22bfe0284567154cf076f" {
+		t.Errorf("bad struct hash %s", h)
+	}
+	if h := hex.EncodeToString(must(td.Hash())); h != "cd7cbde90ad1bfe4b5b52dbf17d427fb41eba467e78c22b7ddbac76b828f9fae" {
+		t.Errorf("bad digest %s", h)
+	}
+
+	for field, v := range map[string]any{
+		"fees":     []any{30},      // wrong fixed array length
+		"selector": "0xa9059cbb00", // too long for bytes4
+		"delta":    "0x",           // not a number
+		"maker":    "0x1234",       // bad address
+		"partial":  "yes",          // not a bool
+	} {
+		orig := td.Message[field]
+		td.Message[field] = v
+		if _, err := td.Hash(); err == nil {
+			t.Errorf("expected error for invalid %s", field)
+		}
+		td.Message[field] = orig
+	}
+}
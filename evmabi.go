@@ -1,10 +1,14 @@
 package outscript
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"reflect"
 	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/BottleFmt/gobottle"
@@ -21,7 +25,7 @@ type abiString struct {
 }
 
 // AbiBuffer is a builder for EVM ABI-encoded data. It supports encoding
-// uint256, address, bytes, and string types, as well as generating
+// all the Solidity ABI types (see [AbiBuffer.EncodeTypes]), as well as generating
 // method call data with the 4-byte function selector.
 type AbiBuffer struct {
 	buf []byte
@@ -80,48 +84,50 @@ func (buf *AbiBuffer) EncodeAuto(params ...any) error {
 // EncodeAbi takes as first parameter an abi such as "transfer(address,uint256)" and
 // a matching number of parameters.
 func (buf *AbiBuffer) EncodeAbi(abi string, params ...any) error {
+	types, err := abiParams(abi)
+	if err != nil {
+		return err
+	}
+	return buf.EncodeTypes(types, params...)
+}
+
+// abiParams returns the parameter types of an abi such as "transfer(address,uint256)"
+func abiParams(abi string) ([]string, error) {
 	// we expect abi to be func(a,b,c) where func is a string we do not really care about
 	pos := strings.IndexByte(abi, '(')
 	if pos == -1 {
-		return errors.New("invalid abi format (could not locate start of parameters)")
+		return nil, errors.New("invalid abi format (could not locate start of parameters)")
 	}
 	if !strings.HasSuffix(abi, ")") {
-		return errors.New("invalid abi format (does not end with a closing parenthesis)")
+		return nil, errors.New("invalid abi format (does not end with a closing parenthesis)")
 	}
-	abiParams := abi[pos+1 : len(abi)-1]
-
-	return buf.EncodeTypes(strings.Split(abiParams, ","), params...)
+	return splitAbiTypes(abi[pos+1 : len(abi)-1]), nil
 }
 
-// EncodeTypes encodes the given parameters according to the specified ABI type strings.
-// Supported types are "uint", "uint8"..."uint256", "bytes4", "bytes32", "address", "bytes", and "string".
+// EncodeTypes encodes the given parameters according to the specified ABI type strings, following
+// the Solidity ABI v2 specification. Supported types are all the elementary types ("uint8" to
+// "uint256", "int8" to "int256", "bool", "address", "bytes1" to "bytes32", "bytes" and "string"),
+// fixed "T[k]" and dynamic "T[]" arrays, and tuples such as "(address,uint256)". Arrays and tuples
+// take any slice or array as value, tuples also accept structs with their fields in order.
 func (buf *AbiBuffer) EncodeTypes(types []string, params ...any) error {
 	if len(types) != len(params) {
 		return errors.New("wrong number of arguments")
 	}
-	if len(types) == 0 {
-		return nil
-	}
-
 	for n, t := range types {
-		switch t {
-		case "uint", "uint8", "uint16", "uint32", "uint64", "uint256", "bytes4", "bytes32":
-			err := buf.AppendUint256Any(params[n])
-			if err != nil {
-				return err
-			}
-		case "address":
-			err := buf.AppendAddressAny(params[n])
-			if err != nil {
-				return err
-			}
-		case "bytes", "string":
-			err := buf.AppendBufferAny(params[n])
-			if err != nil {
-				return err
-			}
-		default:
-			return fmt.Errorf("unsupported type: %s", t)
+		typ, err := parseAbiType(t)
+		if err != nil {
+			return err
+		}
+		enc, err := typ.encode(params[n])
+		if err != nil {
+			return err
+		}
+		if typ.dynamic() {
+			// stored at the end of the buffer, see Bytes
+			buf.str = append(buf.str, &abiString{offset: len(buf.buf), data: enc})
+			buf.buf = append(buf.buf, make([]byte, 32)...)
+		} else {
+			buf.buf = append(buf.buf, enc...)
 		}
 	}
 	return nil
@@ -132,7 +138,7 @@ func (buf *AbiBuffer) AppendBigInt(v *big.Int) error {
 	var inbuf [32]byte
 	// should we modulo instead?
 	if v.Sign() < 0 {
-		v = new(big.Int).Sub(big2pow32, v) // if o = -1, it will be set to all 1s (proper negative value for -1 in 256 bits)
+		v = new(big.Int).Add(big2pow32, v) // if o = -1, it will be set to all 1s (proper negative value for -1 in 256 bits)
 		if v.Sign() <= 0 {
 			return errors.New("big.Int value exceeds negative 256 bits")
 		}
@@ -155,29 +161,173 @@ func (buf *AbiBuffer) AppendBytes(v []byte) {
 }
 
 // AppendUint256Any appends a value as a uint256-style ABI parameter.
-// Supported Go types are bool, int, and *big.Int.
+// Supported Go types are bool, integer types, *big.Int, json.Number and strings holding
+// a decimal or 0x-prefixed hex number.
 func (buf *AbiBuffer) AppendUint256Any(v any) error {
-	switch o := v.(type) {
-	case bool:
+	if o, ok := v.(bool); ok {
 		if o {
 			return buf.AppendBigInt(new(big.Int).SetUint64(1))
 		} else {
 			return buf.AppendBigInt(new(big.Int).SetUint64(0))
 		}
-	case int:
-		return buf.AppendBigInt(new(big.Int).SetInt64(int64(o)))
+	}
+	n, err := abiBigInt(v)
+	if err != nil {
+		return err
+	}
+	return buf.AppendBigInt(n)
+}
+
+// abiBigInt returns the value of v as a big.Int
+func abiBigInt(v any) (*big.Int, error) {
+	switch o := v.(type) {
 	case *big.Int:
-		return buf.AppendBigInt(o)
+		return o, nil
+	case json.Number:
+		return abiBigInt(string(o))
+	case string:
+		n, ok := new(big.Int).SetString(o, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid number %q for evm abi", o)
+		}
+		return n, nil
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return new(big.Int).SetInt64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return new(big.Int).SetUint64(rv.Uint()), nil
 	default:
-		return fmt.Errorf("unsupported go type %T for evm abi uint256-style type", o)
+		return nil, fmt.Errorf("unsupported go type %T for evm abi uint256-style type", v)
 	}
 }
 
 // AppendAddressAny appends a value as an ABI address parameter.
+// Supported Go types are *Out, 20 bytes []byte and hex strings.
 func (buf *AbiBuffer) AppendAddressAny(v any) error {
+	addr, err := abiAddress(v)
+	if err != nil {
+		return err
+	}
+	return buf.AppendBigInt(new(big.Int).SetBytes(addr))
+}
+
+// abiAddress returns the 20 bytes of an EVM address
+func abiAddress(v any) ([]byte, error) {
+	switch o := v.(type) {
+	case *Out:
+		if (o.Name == "evm" || o.Name == "eth") && len(o.raw) == 20 {
+			return o.raw, nil
+		}
+		return nil, fmt.Errorf("unsupported value type %s for EVM", o.Name)
+	case []byte:
+		if len(o) != 20 {
+			return nil, errors.New("evm abi address must be 20 bytes long")
+		}
+		return o, nil
+	case string:
+		out, err := ParseEvmAddress(o)
+		if err != nil {
+			return nil, err
+		}
+		return out.raw, nil
+	default:
+		return nil, fmt.Errorf("unsupported go type %T for evm abi type address", o)
+	}
+}
+
+// abiWord encodes a value of a static elementary type ("uint8", "int256", "bool", "address",
+// "bytes4", etc) as a single 32 bytes word
+func abiWord(typ string, v any) ([]byte, error) {
+	buf := &AbiBuffer{}
+	switch {
+	case typ == "address":
+		if err := buf.AppendAddressAny(v); err != nil {
+			return nil, err
+		}
+	case typ == "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("unsupported go type %T for evm abi type bool", v)
+		}
+		if err := buf.AppendUint256Any(b); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(typ, "uint"), strings.HasPrefix(typ, "int"):
+		signed := typ[0] == 'i'
+		bits, err := abiTypeSize(strings.TrimPrefix(strings.TrimPrefix(typ, "u"), "int"), 256, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid type %s: %w", typ, err)
+		}
+		if b, ok := v.(bool); ok {
+			v = 0
+			if b {
+				v = 1
+			}
+		}
+		n, err := abiBigInt(v)
+		if err != nil {
+			return nil, err
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		if signed {
+			limit.Rsh(limit, 1)
+		}
+		if n.Cmp(limit) >= 0 || (!signed && n.Sign() < 0) || (signed && n.Cmp(new(big.Int).Neg(limit)) < 0) {
+			return nil, fmt.Errorf("value %s out of range for %s", n, typ)
+		}
+		if err := buf.AppendBigInt(n); err != nil {
+			return nil, err
+		}
+	case strings.HasPrefix(typ, "bytes"):
+		size, err := abiTypeSize(typ[5:], 32, 1)
+		if err != nil || typ == "bytes" {
+			return nil, fmt.Errorf("invalid type %s", typ)
+		}
+		var b []byte
+		if n, ok := v.(*big.Int); ok {
+			// value of the bytes as a big endian number
+			if n.Sign() < 0 || n.BitLen() > size*8 {
+				return nil, fmt.Errorf("value %s out of range for %s", n, typ)
+			}
+			b = n.FillBytes(make([]byte, size))
+		} else if b, err = abiBytes(v); err != nil {
+			return nil, err
+		}
+		if len(b) > size {
+			return nil, fmt.Errorf("value too long for %s", typ)
+		}
+		buf.buf = append(slices.Clone(b), make([]byte, 32-len(b))...)
+	default:
+		return nil, fmt.Errorf("unsupported type: %s", typ)
+	}
+	return buf.buf, nil
+}
+
+// abiTypeSize parses the size suffix of a type such as uint64, defaulting to max when empty
+func abiTypeSize(s string, max, mult int) (int, error) {
+	if s == "" {
+		return max, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n > max || n%mult != 0 || s[0] == '0' {
+		return 0, fmt.Errorf("invalid size %s", s)
+	}
+	return n, nil
+}
+
+// abiBytes returns the value of v for bytes types, accepting []byte and 0x-prefixed hex strings
+func abiBytes(v any) ([]byte, error) {
 	switch o := v.(type) {
+	case []byte:
+		return o, nil
+	case string:
+		if !strings.HasPrefix(o, "0x") {
+			return nil, errors.New("evm abi bytes value must be a 0x-prefixed hex string")
+		}
+		return hex.DecodeString(o[2:])
 	default:
-		return fmt.Errorf("unsupported go type %T for evm abi type address", o)
+		return nil, fmt.Errorf("unsupported go type %T for evm abi bytes type", o)
 	}
 }
 
@@ -219,3 +219,30 @@ func TestEvmCallError(t *testing.T) {
 		t.Error("expected error for invalid ABI")
 	}
 }
+
+func TestAppendBigIntNegative(t *testing.T) {
+	buf := outscript.NewAbiBuffer(nil)
+	if err := buf.AppendBigInt(big.NewInt(-1)); err != nil {
+		t.Fatalf("AppendBigInt(-1) failed: %s", err)
+	}
+	for _, b := range buf.Bytes() {
+		if b != 0xff {
+			t.Fatalf("expected all 0xff for -1, got %x", buf.Bytes())
+		}
+	}
+}
+
+func TestAppendAddressAny(t *testing.T) {
+	for _, v := range []any{"0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826", must(outscript.ParseEvmAddress("0xcd2a3d9f938e13cd947ec05abc7fe734df8dd826"))} {
+		buf := outscript.NewAbiBuffer(nil)
+		if err := buf.AppendAddressAny(v); err != nil {
+			t.Fatalf("AppendAddressAny(%v) failed: %s", v, err)
+		}
+		if res := buf.Bytes(); len(res) != 32 || res[12] != 0xcd || res[31] != 0x26 {
+			t.Errorf("bad address encoding %x", res)
+		}
+	}
+	if err := outscript.NewAbiBuffer(nil).AppendAddressAny([]byte{1, 2, 3}); err == nil {
+		t.Error("expected error for short address")
+	}
+}
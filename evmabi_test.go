@@ -3,6 +3,7 @@ package outscript_test
 import (
 	"encoding/hex"
 	"math/big"
+	"strings"
 	"testing"
 
 	"github.com/KarpelesLab/outscript"
@@ -31,3 +32,86 @@ func TestEvmScript(t *testing.T) {
 		t.Errorf("castVoteWithReason test call error, got %x", call2)
 	}
 }
+
+func TestEvmAbiSpec(t *testing.T) {
+	// examples from the Solidity ABI specification
+	word := func(s string) string {
+		if text, ok := strings.CutPrefix(s, "$"); ok {
+			// left aligned
+			return hex.EncodeToString([]byte(text)) + strings.Repeat("0", 64-2*len(text))
+		}
+		return strings.Repeat("0", 64-len(s)) + s
+	}
+	vectors := []struct {
+		method string
+		params []any
+		exp    []string
+	}{
+		{"baz(uint32,bool)", []any{69, true}, []string{"cdcd77c0", "45", "1"}},
+		{"bar(bytes3[2])", []any{[][]byte{[]byte("abc"), []byte("def")}}, []string{"fce353f6", "$abc", "$def"}},
+		{"sam(bytes,bool,uint256[])", []any{[]byte("dave"), true, []int{1, 2, 3}}, []string{"a5643bf2", "60", "1", "a0", "4", "$dave", "3", "1", "2", "3"}},
+		{"f(uint256,uint32[],bytes10,bytes)", []any{0x123, []any{0x456, 0x789}, []byte("1234567890"), "Hello, world!"}, []string{"8be65246", "123", "80", "$1234567890", "e0", "2", "456", "789", "d", "$Hello, world!"}},
+		{"g(uint256[][],string[])", []any{[][]int{{1, 2}, {3}}, []string{"one", "two", "three"}}, []string{"2289b18c", "40", "140", "2", "40", "a0", "2", "1", "2", "1", "3", "3", "60", "a0", "e0", "3", "$one", "3", "$two", "5", "$three"}},
+	}
+	for _, v := range vectors {
+		exp := v.exp[0]
+		for _, w := range v.exp[1:] {
+			exp += word(w)
+		}
+		if res := hex.EncodeToString(must(outscript.EvmCall(v.method, v.params...))); res != exp {
+			t.Errorf("bad encoding for %s:\n%s\nexpected:\n%s", v.method, res, exp)
+		}
+	}
+}
+
+func TestEvmAbiTuples(t *testing.T) {
+	type transfer struct {
+		To     *outscript.Out
+		Amount *big.Int
+	}
+	to := must(outscript.ParseEvmAddress("0x5Fb84129AD9E7818F099966de975ff41213F028d"))
+	addr := "0000000000000000000000005fb84129ad9e7818f099966de975ff41213f028d"
+	word := func(n int) string {
+		return hex.EncodeToString(new(big.Int).SetInt64(int64(n)).FillBytes(make([]byte, 32)))
+	}
+
+	// static tuples are encoded inline, in dynamic arrays after the length
+	buf := outscript.NewAbiBuffer(nil)
+	if err := buf.EncodeTypes([]string{"(address,uint256)[]", "int8"}, []transfer{{to, big.NewInt(1)}, {to, big.NewInt(2)}}, -1); err != nil {
+		t.Fatalf("failed to encode: %s", err)
+	}
+	exp := word(0x40) + strings.Repeat("f", 64) + word(2) + addr + word(1) + addr + word(2)
+	if res := hex.EncodeToString(buf.Bytes()); res != exp {
+		t.Errorf("bad tuple array encoding:\n%s\nexpected:\n%s", res, exp)
+	}
+
+	// dynamic tuples are referenced by offset, relative to the start of their enclosing tuple
+	buf = outscript.NewAbiBuffer(nil)
+	if err := buf.EncodeTypes([]string{"(string,address[2])", "bytes4"}, []any{"hi", []any{to, "0x5fb84129ad9e7818f099966de975ff41213f028d"}}, []byte{0xa9, 0x05, 0x9c, 0xbb}); err != nil {
+		t.Fatalf("failed to encode: %s", err)
+	}
+	exp = word(0x40) + "a9059cbb" + strings.Repeat("0", 56) + word(0x60) + addr + addr + word(2) + "6869" + strings.Repeat("0", 60)
+	if res := hex.EncodeToString(buf.Bytes()); res != exp {
+		t.Errorf("bad dynamic tuple encoding:\n%s\nexpected:\n%s", res, exp)
+	}
+
+	for _, v := range []struct {
+		typ string
+		val any
+	}{
+		{"uint8", 256},
+		{"int8", -129},
+		{"uint256", -1},
+		{"bytes2", []byte{1, 2, 3}},
+		{"address[2]", []any{to}},
+		{"(uint256,bool)", []any{1}},
+		{"uint7", 1},
+		{"bytes33", []byte{1}},
+		{"uint256[0]", []any{}},
+		{"bool", 1},
+	} {
+		if err := outscript.NewAbiBuffer(nil).EncodeTypes([]string{v.typ}, v.val); err == nil {
+			t.Errorf("expected error encoding %v as %s", v.val, v.typ)
+		}
+	}
+}
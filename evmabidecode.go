@@ -0,0 +1,282 @@
+package outscript
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/BottleFmt/gobottle"
+	"golang.org/x/crypto/sha3"
+)
+
+// AbiDecodeTypes decodes ABI encoded data such as the return value of eth_call according to the
+// given types, see [AbiBuffer.EncodeTypes]. Integers are returned as *big.Int, addresses as *Out,
+// bytes types as []byte, and arrays and tuples as []any.
+func AbiDecodeTypes(types []string, buf []byte) ([]any, error) {
+	parsed := make([]*abiType, len(types))
+	for n, t := range types {
+		var err error
+		parsed[n], err = parseAbiType(t)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return abiDecodeTuple(parsed, buf)
+}
+
+// AbiDecode decodes the parameters of an abi such as "transfer(address,uint256)" from buf, which
+// does not include the method selector.
+func AbiDecode(abi string, buf []byte) ([]any, error) {
+	types, err := abiParams(abi)
+	if err != nil {
+		return nil, err
+	}
+	return AbiDecodeTypes(types, buf)
+}
+
+// EvmDecodeCall decodes the parameters of calldata after checking it starts with the selector of
+// method, such as "transfer(address,uint256)". It is the inverse of [EvmCall].
+func EvmDecodeCall(method string, data []byte) ([]any, error) {
+	if len(data) < 4 {
+		return nil, errors.New("calldata is too short to contain a method selector")
+	}
+	if sel := gobottle.Hash([]byte(method), sha3.NewLegacyKeccak256)[:4]; !bytes.Equal(sel, data[:4]) {
+		return nil, fmt.Errorf("calldata selector %x does not match %s", data[:4], method)
+	}
+	return AbiDecode(method, data[4:])
+}
+
+// abiDecodeTuple decodes a sequence of values from buf, which starts with their heads
+func abiDecodeTuple(types []*abiType, buf []byte) ([]any, error) {
+	limit := len(buf)
+	return abiDecodeTupleLimit(types, buf, &limit)
+}
+
+// abiUseLimit takes n bytes from the decoding limit. Each word and byte of data found in a valid
+// encoding is decoded once, so the decoded size cannot exceed the size of the encoded data. This
+// prevents offsets pointing several times to the same data from causing huge allocations.
+func abiUseLimit(limit *int, n int) error {
+	if n > *limit {
+		return errors.New("abi data decodes to more than its own size")
+	}
+	*limit -= n
+	return nil
+}
+
+// abiDecodeTupleLimit is [abiDecodeTuple] with a limit on the decoded size, see [abiUseLimit]
+func abiDecodeTupleLimit(types []*abiType, buf []byte, limit *int) ([]any, error) {
+	res := make([]any, len(types))
+	pos := 0
+	for n, t := range types {
+		if !t.dynamic() {
+			if pos+t.headSize() > len(buf) {
+				return nil, errors.New("abi data is too short")
+			}
+			v, err := t.decodeLimit(buf[pos:], limit)
+			if err != nil {
+				return nil, err
+			}
+			res[n] = v
+			pos += t.headSize()
+			continue
+		}
+		if pos+32 > len(buf) {
+			return nil, errors.New("abi data is too short")
+		}
+		if err := abiUseLimit(limit, 32); err != nil {
+			return nil, err
+		}
+		off, err := abiReadSize(buf[pos:pos+32], len(buf))
+		if err != nil {
+			return nil, err
+		}
+		v, err := t.decodeLimit(buf[off:], limit)
+		if err != nil {
+			return nil, err
+		}
+		res[n] = v
+		pos += 32
+	}
+	return res, nil
+}
+
+// abiReadSize reads a word holding an offset or length, which cannot exceed max
+func abiReadSize(word []byte, max int) (int, error) {
+	n := new(big.Int).SetBytes(word)
+	if !n.IsInt64() || n.Int64() > int64(max) {
+		return 0, errors.New("abi offset or length out of range")
+	}
+	return int(n.Int64()), nil
+}
+
+// headSize returns the size of the type in the head of its enclosing tuple
+func (t *abiType) headSize() int {
+	switch {
+	case t.dynamic():
+		return 32
+	case t.elem != nil:
+		return t.size * t.elem.headSize()
+	case t.fields != nil:
+		res := 0
+		for _, f := range t.fields {
+			res += f.headSize()
+		}
+		return res
+	default:
+		return 32
+	}
+}
+
+// decode returns the value encoded at the start of buf
+func (t *abiType) decode(buf []byte) (any, error) {
+	limit := len(buf)
+	return t.decodeLimit(buf, &limit)
+}
+
+// decodeLimit is [abiType.decode] with a limit on the decoded size, see [abiUseLimit]
+func (t *abiType) decodeLimit(buf []byte, limit *int) (any, error) {
+	switch {
+	case t.elem != nil:
+		n := t.size
+		if n < 0 {
+			if len(buf) < 32 {
+				return nil, errors.New("abi data is too short")
+			}
+			if err := abiUseLimit(limit, 32); err != nil {
+				return nil, err
+			}
+			var err error
+			// each element uses at least 32 bytes
+			if n, err = abiReadSize(buf[:32], len(buf)/32); err != nil {
+				return nil, err
+			}
+			buf = buf[32:]
+		} else if n > len(buf)/32 {
+			// each element uses at least 32 bytes
+			return nil, errors.New("abi data is too short")
+		}
+		types := make([]*abiType, n)
+		for i := range types {
+			types[i] = t.elem
+		}
+		return abiDecodeTupleLimit(types, buf, limit)
+	case t.fields != nil:
+		return abiDecodeTupleLimit(t.fields, buf, limit)
+	case t.base == "bytes" || t.base == "string":
+		if len(buf) < 32 {
+			return nil, errors.New("abi data is too short")
+		}
+		n, err := abiReadSize(buf[:32], len(buf)-32)
+		if err != nil {
+			return nil, err
+		}
+		if err := abiUseLimit(limit, 32+n); err != nil {
+			return nil, err
+		}
+		if t.base == "string" {
+			return string(buf[32 : 32+n]), nil
+		}
+		return bytes.Clone(buf[32 : 32+n]), nil
+	}
+
+	if len(buf) < 32 {
+		return nil, errors.New("abi data is too short")
+	}
+	if err := abiUseLimit(limit, 32); err != nil {
+		return nil, err
+	}
+	word := buf[:32]
+	switch {
+	case t.base == "bool":
+		if !bytes.Equal(word[:31], make([]byte, 31)) || word[31] > 1 {
+			return nil, errors.New("invalid abi bool value")
+		}
+		return word[31] == 1, nil
+	case t.base == "address":
+		if !bytes.Equal(word[:12], make([]byte, 12)) {
+			return nil, errors.New("invalid abi address value")
+		}
+		return makeOut("eth", bytes.Clone(word[12:]), "evm"), nil
+	case strings.HasPrefix(t.base, "bytes"):
+		size, _ := abiTypeSize(t.base[5:], 32, 1)
+		if !bytes.Equal(word[size:], make([]byte, 32-size)) {
+			return nil, fmt.Errorf("invalid abi %s value", t.base)
+		}
+		return bytes.Clone(word[:size]), nil
+	case strings.HasPrefix(t.base, "uint"):
+		bits, _ := abiTypeSize(t.base[4:], 256, 8)
+		v := new(big.Int).SetBytes(word)
+		if v.BitLen() > bits {
+			return nil, fmt.Errorf("abi value out of range for %s", t.base)
+		}
+		return v, nil
+	default:
+		// intN, two's complement
+		bits, _ := abiTypeSize(t.base[3:], 256, 8)
+		v := new(big.Int).SetBytes(word)
+		if word[0]&0x80 != 0 {
+			v.Sub(v, big2pow32)
+		}
+		limit := new(big.Int).Lsh(big.NewInt(1), uint(bits-1))
+		if v.Cmp(limit) >= 0 || v.Cmp(new(big.Int).Neg(limit)) < 0 {
+			return nil, fmt.Errorf("abi value out of range for %s", t.base)
+		}
+		return v, nil
+	}
+}
+
+// evmPanicReasons lists the meaning of Solidity panic codes
+var evmPanicReasons = map[uint64]string{
+	0x00: "generic compiler panic",
+	0x01: "assertion failed",
+	0x11: "arithmetic overflow or underflow",
+	0x12: "division or modulo by zero",
+	0x21: "invalid enum value",
+	0x22: "invalid storage byte array encoding",
+	0x31: "pop on empty array",
+	0x32: "array index out of bounds",
+	0x41: "out of memory",
+	0x51: "call to uninitialized internal function",
+}
+
+// EvmRevert is a decoded revert payload, as returned by a failed call.
+type EvmRevert struct {
+	Reason string   // message of Error(string)
+	Panic  *big.Int // code of Panic(uint256), nil for Error(string)
+}
+
+// Error returns the revert reason.
+func (r *EvmRevert) Error() string {
+	if r.Panic == nil {
+		return "execution reverted: " + r.Reason
+	}
+	if r.Panic.IsUint64() {
+		if reason, ok := evmPanicReasons[r.Panic.Uint64()]; ok {
+			return fmt.Sprintf("execution reverted: panic 0x%x (%s)", r.Panic, reason)
+		}
+	}
+	return fmt.Sprintf("execution reverted: panic 0x%x", r.Panic)
+}
+
+// ParseEvmRevert decodes a standard Error(string) or Panic(uint256) revert payload. Custom
+// errors can be decoded with [EvmDecodeCall].
+func ParseEvmRevert(data []byte) (*EvmRevert, error) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x08, 0xc3, 0x79, 0xa0}):
+		v, err := AbiDecodeTypes([]string{"string"}, data[4:])
+		if err != nil {
+			return nil, err
+		}
+		return &EvmRevert{Reason: v[0].(string)}, nil
+	case bytes.HasPrefix(data, []byte{0x4e, 0x48, 0x7b, 0x71}):
+		v, err := AbiDecodeTypes([]string{"uint256"}, data[4:])
+		if err != nil {
+			return nil, err
+		}
+		return &EvmRevert{Panic: v[0].(*big.Int)}, nil
+	default:
+		return nil, errors.New("unsupported revert payload")
+	}
+}
@@ -0,0 +1,115 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+)
+
+func TestEvmDecodeCall(t *testing.T) {
+	to := must(outscript.ParseEvmAddress("0x5Fb84129AD9E7818F099966de975ff41213F028d"))
+	vectors := []struct {
+		method string
+		params []any
+		exp    string // decoded values, formatted with %v
+	}{
+		{"transfer(address,uint256)", []any{to, big.NewInt(123456789)}, "[eth:5fb84129ad9e7818f099966de975ff41213f028d 123456789]"},
+		{"baz(uint32,bool)", []any{69, true}, "[69 true]"},
+		{"bar(bytes3[2])", []any{[][]byte{[]byte("abc"), []byte("def")}}, "[[[97 98 99] [100 101 102]]]"},
+		{"f(uint256,uint32[],bytes10,bytes)", []any{0x123, []any{0x456, 0x789}, []byte("1234567890"), "Hello, world!"}, "[291 [1110 1929] [49 50 51 52 53 54 55 56 57 48] [72 101 108 108 111 44 32 119 111 114 108 100 33]]"},
+		{"g(uint256[][],string[])", []any{[][]int{{1, 2}, {3}}, []string{"one", "two", "three"}}, "[[[1 2] [3]] [one two three]]"},
+		{"h(int8,int256,(string,address[2])[])", []any{-128, -1, []any{[]any{"hi", []any{to, to}}}}, "[-128 -1 [[hi [eth:5fb84129ad9e7818f099966de975ff41213f028d eth:5fb84129ad9e7818f099966de975ff41213f028d]]]]"},
+	}
+	for _, v := range vectors {
+		data := must(outscript.EvmCall(v.method, v.params...))
+		res, err := outscript.EvmDecodeCall(v.method, data)
+		if err != nil {
+			t.Errorf("failed to decode %s: %s", v.method, err)
+			continue
+		}
+		if s := fmt.Sprintf("%v", res); s != v.exp {
+			t.Errorf("bad decoded values for %s: %s", v.method, s)
+		}
+
+		// decoded values can be encoded again
+		if again := must(outscript.EvmCall(v.method, res...)); hex.EncodeToString(again) != hex.EncodeToString(data) {
+			t.Errorf("bad encoding of decoded values for %s", v.method)
+		}
+	}
+
+	data := must(outscript.EvmCall("transfer(address,uint256)", to, 1))
+	if _, err := outscript.EvmDecodeCall("approve(address,uint256)", data); err == nil {
+		t.Errorf("expected error for wrong selector")
+	}
+	if _, err := outscript.EvmDecodeCall("transfer(address,uint256)", data[:60]); err == nil {
+		t.Errorf("expected error for truncated calldata")
+	}
+}
+
+func TestAbiDecodeInvalid(t *testing.T) {
+	word := func(s string) string { return strings.Repeat("0", 64-len(s)) + s }
+	for _, v := range []struct {
+		types []string
+		data  string
+	}{
+		{[]string{"bool"}, word("2")},
+		{[]string{"uint8"}, word("100")},
+		{[]string{"int8"}, word("80")},
+		{[]string{"address"}, word("1" + strings.Repeat("0", 40))},
+		{[]string{"bytes4"}, "a9059cbb" + strings.Repeat("0", 54) + "01"},
+		{[]string{"string"}, word("20")},                         // missing length
+		{[]string{"string"}, word("20") + word("21") + word("")}, // length exceeds the data
+		{[]string{"uint256[]"}, word("40")},                      // offset out of range
+		{[]string{"uint256[]"}, word("20") + word("ffffffffffffffff")},
+		{[]string{"uint256", "uint256"}, word("1")},
+		// 64 elements pointing to the same 1024 bytes value
+		{[]string{"bytes[]"}, word("20") + word("40") + strings.Repeat(word("800"), 64) + word("400") + strings.Repeat("ab", 1024)},
+		// array sizes overflowing the head size
+		{[]string{"uint256[576460752303423488]"}, word("") + word("")},
+		{[]string{"uint256[4294967296][4294967296]"}, word("") + word("")},
+		{[]string{"(uint256[67108864],uint256[67108864])"}, word("")},
+		{[]string{"string[1000000]"}, word("20") + word("")},
+	} {
+		if res, err := outscript.AbiDecodeTypes(v.types, must(hex.DecodeString(v.data))); err == nil {
+			t.Errorf("expected error decoding %s as %v, got %v", v.data, v.types, res)
+		}
+	}
+
+	// return data of a call
+	res := must(outscript.AbiDecodeTypes([]string{"uint256", "int16", "bytes32"}, must(hex.DecodeString(word("2a")+strings.Repeat("f", 60)+"fffe"+strings.Repeat("ab", 32)))))
+	if s := fmt.Sprintf("%v", res[:2]); s != "[42 -2]" || hex.EncodeToString(res[2].([]byte)) != strings.Repeat("ab", 32) {
+		t.Errorf("bad decoded return data %v", res)
+	}
+}
+
+func TestParseEvmRevert(t *testing.T) {
+	// require(msg.value >= price, "Not enough Ether provided.")
+	data := must(hex.DecodeString("08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000001a" +
+		"4e6f7420656e6f7567682045746865722070726f76696465642e000000000000"))
+	r := must(outscript.ParseEvmRevert(data))
+	if r.Reason != "Not enough Ether provided." || r.Panic != nil {
+		t.Errorf("bad revert reason %q", r.Reason)
+	}
+	if r.Error() != "execution reverted: Not enough Ether provided." {
+		t.Errorf("bad error message %s", r.Error())
+	}
+
+	// arithmetic overflow
+	r = must(outscript.ParseEvmRevert(must(outscript.EvmCall("Panic(uint256)", 0x11))))
+	if r.Panic == nil || r.Panic.Int64() != 0x11 {
+		t.Errorf("bad panic code %v", r.Panic)
+	}
+	if r.Error() != "execution reverted: panic 0x11 (arithmetic overflow or underflow)" {
+		t.Errorf("bad error message %s", r.Error())
+	}
+
+	if _, err := outscript.ParseEvmRevert(must(outscript.EvmCall("InsufficientBalance(uint256)", 1))); err == nil {
+		t.Errorf("expected error for custom error")
+	}
+}
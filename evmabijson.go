@@ -0,0 +1,239 @@
+package outscript
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/BottleFmt/gobottle"
+	"golang.org/x/crypto/sha3"
+)
+
+// EvmAbiParam is an input or output parameter of a JSON ABI entry.
+type EvmAbiParam struct {
+	Name         string         `json:"name"`
+	Type         string         `json:"type"` // "uint256", "tuple[]", etc
+	InternalType string         `json:"internalType,omitempty"`
+	Components   []*EvmAbiParam `json:"components,omitempty"` // tuple members
+	Indexed      bool           `json:"indexed,omitempty"`    // event parameters stored in topics
+}
+
+// CanonicalType returns the type as used in signatures, with tuples expanded such as
+// "(address,uint256)[]".
+func (p *EvmAbiParam) CanonicalType() (string, error) {
+	typ := p.Type
+	if rest, ok := strings.CutPrefix(typ, "tuple"); ok {
+		types, err := evmAbiTypes(p.Components)
+		if err != nil {
+			return "", err
+		}
+		typ = "(" + strings.Join(types, ",") + ")" + rest
+	}
+	t, err := parseAbiType(typ)
+	if err != nil {
+		return "", fmt.Errorf("parameter %s: %w", p.Name, err)
+	}
+	return t.String(), nil
+}
+
+// evmAbiTypes returns the canonical types of params
+func evmAbiTypes(params []*EvmAbiParam) ([]string, error) {
+	res := make([]string, len(params))
+	for n, p := range params {
+		if p == nil {
+			return nil, errors.New("invalid null abi parameter")
+		}
+		var err error
+		res[n], err = p.CanonicalType()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// EvmAbiEntry is a function, event, error or constructor of a JSON ABI.
+type EvmAbiEntry struct {
+	Type            string         `json:"type"` // "function", "event", "error", "constructor", "fallback" or "receive"
+	Name            string         `json:"name,omitempty"`
+	Inputs          []*EvmAbiParam `json:"inputs,omitempty"`
+	Outputs         []*EvmAbiParam `json:"outputs,omitempty"`
+	StateMutability string         `json:"stateMutability,omitempty"`
+	Anonymous       bool           `json:"anonymous,omitempty"`
+
+	sig string
+}
+
+// Signature returns the canonical signature of the entry, such as "transfer(address,uint256)".
+func (e *EvmAbiEntry) Signature() string {
+	return e.sig
+}
+
+// ID returns the keccak256 hash of the signature, which is the topic of events.
+func (e *EvmAbiEntry) ID() []byte {
+	return gobottle.Hash([]byte(e.sig), sha3.NewLegacyKeccak256)
+}
+
+// Selector returns the 4 bytes selector of functions and errors.
+func (e *EvmAbiEntry) Selector() []byte {
+	return e.ID()[:4]
+}
+
+// InputTypes returns the canonical types of the inputs.
+func (e *EvmAbiEntry) InputTypes() []string {
+	res, _ := evmAbiTypes(e.Inputs) // checked when parsing
+	return res
+}
+
+// OutputTypes returns the canonical types of the outputs.
+func (e *EvmAbiEntry) OutputTypes() []string {
+	res, _ := evmAbiTypes(e.Outputs) // checked when parsing
+	return res
+}
+
+// EvmAbi is a parsed Solidity JSON ABI, as produced by the compiler.
+type EvmAbi struct {
+	Constructor *EvmAbiEntry // nil if the contract has no explicit constructor
+	Methods     []*EvmAbiEntry
+	Events      []*EvmAbiEntry
+	Errors      []*EvmAbiEntry
+}
+
+// ParseEvmAbi parses a JSON ABI.
+func ParseEvmAbi(buf []byte) (*EvmAbi, error) {
+	var entries []*EvmAbiEntry
+	if err := json.Unmarshal(buf, &entries); err != nil {
+		return nil, err
+	}
+	abi := &EvmAbi{}
+	for n, e := range entries {
+		if e == nil {
+			return nil, fmt.Errorf("invalid null abi entry at index %d", n)
+		}
+		types, err := evmAbiTypes(e.Inputs)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", e.Type, e.Name, err)
+		}
+		if _, err := evmAbiTypes(e.Outputs); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", e.Type, e.Name, err)
+		}
+		e.sig = e.Name + "(" + strings.Join(types, ",") + ")"
+
+		switch e.Type {
+		case "function", "":
+			abi.Methods = append(abi.Methods, e)
+		case "event":
+			abi.Events = append(abi.Events, e)
+		case "error":
+			abi.Errors = append(abi.Errors, e)
+		case "constructor":
+			abi.Constructor = e
+		case "fallback", "receive":
+			// nothing to encode
+		default:
+			return nil, fmt.Errorf("unsupported abi entry type %s", e.Type)
+		}
+	}
+	return abi, nil
+}
+
+// findAbiEntry returns the entry matching name, which can also be a full signature to select
+// among overloaded entries
+func findAbiEntry(entries []*EvmAbiEntry, kind, name string) (*EvmAbiEntry, error) {
+	var res *EvmAbiEntry
+	for _, e := range entries {
+		if e.sig == name {
+			return e, nil
+		}
+		if e.Name == name {
+			if res != nil {
+				return nil, fmt.Errorf("%s %s is overloaded, please use its full signature", kind, name)
+			}
+			res = e
+		}
+	}
+	if res == nil {
+		return nil, fmt.Errorf("%s %s not found in abi", kind, name)
+	}
+	return res, nil
+}
+
+// Method returns the function matching name, or its signature if overloaded.
+func (abi *EvmAbi) Method(name string) (*EvmAbiEntry, error) {
+	return findAbiEntry(abi.Methods, "method", name)
+}
+
+// Event returns the event matching name, or its signature if overloaded.
+func (abi *EvmAbi) Event(name string) (*EvmAbiEntry, error) {
+	return findAbiEntry(abi.Events, "event", name)
+}
+
+// CustomError returns the error matching name, or its signature if overloaded.
+func (abi *EvmAbi) CustomError(name string) (*EvmAbiEntry, error) {
+	return findAbiEntry(abi.Errors, "error", name)
+}
+
+// Pack returns the calldata of a call to method with the given parameters. An empty method
+// name encodes the constructor parameters, to be appended to the contract bytecode.
+func (abi *EvmAbi) Pack(method string, params ...any) ([]byte, error) {
+	if method == "" {
+		var types []string
+		if abi.Constructor != nil {
+			types = abi.Constructor.InputTypes()
+		}
+		buf := &AbiBuffer{}
+		if err := buf.EncodeTypes(types, params...); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	m, err := abi.Method(method)
+	if err != nil {
+		return nil, err
+	}
+	return EvmCall(m.Signature(), params...)
+}
+
+// Unpack decodes the return data of a call to method.
+func (abi *EvmAbi) Unpack(method string, data []byte) ([]any, error) {
+	m, err := abi.Method(method)
+	if err != nil {
+		return nil, err
+	}
+	return AbiDecodeTypes(m.OutputTypes(), data)
+}
+
+// DecodeCall returns the method called by calldata and its decoded parameters.
+func (abi *EvmAbi) DecodeCall(data []byte) (*EvmAbiEntry, []any, error) {
+	m := findAbiSelector(abi.Methods, data)
+	if m == nil {
+		return nil, nil, errors.New("calldata does not match any method of the abi")
+	}
+	res, err := AbiDecodeTypes(m.InputTypes(), data[4:])
+	return m, res, err
+}
+
+// DecodeError returns the error matching a revert payload and its decoded parameters. Standard
+// Error(string) and Panic(uint256) payloads are decoded by [ParseEvmRevert].
+func (abi *EvmAbi) DecodeError(data []byte) (*EvmAbiEntry, []any, error) {
+	e := findAbiSelector(abi.Errors, data)
+	if e == nil {
+		return nil, nil, errors.New("revert payload does not match any error of the abi")
+	}
+	res, err := AbiDecodeTypes(e.InputTypes(), data[4:])
+	return e, res, err
+}
+
+// findAbiSelector returns the entry whose selector starts data
+func findAbiSelector(entries []*EvmAbiEntry, data []byte) *EvmAbiEntry {
+	if len(data) < 4 {
+		return nil
+	}
+	for _, e := range entries {
+		if string(e.Selector()) == string(data[:4]) {
+			return e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+)
+
+const testEvmAbi = `[
+	{"type": "constructor", "inputs": [{"name": "name_", "type": "string"}, {"name": "supply", "type": "uint256"}], "stateMutability": "nonpayable"},
+	{"type": "function", "name": "balanceOf", "inputs": [{"name": "account", "type": "address"}], "outputs": [{"name": "", "type": "uint256"}], "stateMutability": "view"},
+	{"type": "function", "name": "transfer", "inputs": [{"name": "to", "type": "address"}, {"name": "value", "type": "uint256"}], "outputs": [{"name": "", "type": "bool"}], "stateMutability": "nonpayable"},
+	{"type": "function", "name": "swapExactTokensForTokens", "inputs": [
+		{"name": "amountIn", "type": "uint256"},
+		{"name": "amountOutMin", "type": "uint256"},
+		{"name": "path", "type": "address[]"},
+		{"name": "to", "type": "address"},
+		{"name": "deadline", "type": "uint256"}
+	], "outputs": [{"name": "amounts", "type": "uint256[]"}], "stateMutability": "nonpayable"},
+	{"type": "function", "name": "exactInputSingle", "inputs": [{"name": "params", "type": "tuple", "internalType": "struct ISwapRouter.ExactInputSingleParams", "components": [
+		{"name": "tokenIn", "type": "address"},
+		{"name": "tokenOut", "type": "address"},
+		{"name": "fee", "type": "uint24"},
+		{"name": "recipient", "type": "address"},
+		{"name": "deadline", "type": "uint256"},
+		{"name": "amountIn", "type": "uint256"},
+		{"name": "amountOutMinimum", "type": "uint256"},
+		{"name": "sqrtPriceLimitX96", "type": "uint160"}
+	]}], "outputs": [{"name": "amountOut", "type": "uint256"}], "stateMutability": "payable"},
+	{"type": "function", "name": "safeTransferFrom", "inputs": [{"name": "from", "type": "address"}, {"name": "to", "type": "address"}, {"name": "tokenId", "type": "uint256"}], "outputs": []},
+	{"type": "function", "name": "safeTransferFrom", "inputs": [{"name": "from", "type": "address"}, {"name": "to", "type": "address"}, {"name": "tokenId", "type": "uint256"}, {"name": "data", "type": "bytes"}], "outputs": []},
+	{"type": "event", "name": "Transfer", "inputs": [{"name": "from", "type": "address", "indexed": true}, {"name": "to", "type": "address", "indexed": true}, {"name": "value", "type": "uint256", "indexed": false}], "anonymous": false},
+	{"type": "error", "name": "InsufficientBalance", "inputs": [{"name": "available", "type": "uint256"}, {"name": "required", "type": "uint256"}]},
+	{"type": "receive", "stateMutability": "payable"}
+]`
+
+func TestEvmAbiJSON(t *testing.T) {
+	abi := must(outscript.ParseEvmAbi([]byte(testEvmAbi)))
+
+	for _, v := range [][3]string{
+		{"balanceOf", "balanceOf(address)", "70a08231"},
+		{"transfer", "transfer(address,uint256)", "a9059cbb"},
+		{"swapExactTokensForTokens", "swapExactTokensForTokens(uint256,uint256,address[],address,uint256)", "38ed1739"},
+		{"exactInputSingle", "exactInputSingle((address,address,uint24,address,uint256,uint256,uint256,uint160))", "414bf389"},
+		{"safeTransferFrom(address,address,uint256,bytes)", "safeTransferFrom(address,address,uint256,bytes)", "b88d4fde"},
+	} {
+		m := must(abi.Method(v[0]))
+		if m.Signature() != v[1] || hex.EncodeToString(m.Selector()) != v[2] {
+			t.Errorf("bad method %s: %s %x", v[0], m.Signature(), m.Selector())
+		}
+	}
+	if _, err := abi.Method("safeTransferFrom"); err == nil {
+		t.Errorf("expected error for overloaded method")
+	}
+	if _, err := abi.Method("approve"); err == nil {
+		t.Errorf("expected error for unknown method")
+	}
+	if e := must(abi.Event("Transfer")); hex.EncodeToString(e.ID()) != "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef" {
+		t.Errorf("bad event topic %x", e.ID())
+	}
+	if e := must(abi.CustomError("InsufficientBalance")); e.Signature() != "InsufficientBalance(uint256,uint256)" {
+		t.Errorf("bad error signature %s", e.Signature())
+	}
+
+	// calls use the same encoding as EvmCall
+	to := must(outscript.ParseEvmAddress("0x5Fb84129AD9E7818F099966de975ff41213F028d"))
+	data := must(abi.Pack("transfer", to, big.NewInt(1000)))
+	if hex.EncodeToString(data) != hex.EncodeToString(must(outscript.EvmCall("transfer(address,uint256)", to, big.NewInt(1000)))) {
+		t.Errorf("bad packed call %x", data)
+	}
+	tx := &outscript.EvmTx{}
+	if err := tx.Call(must(abi.Method("transfer")).Signature(), to, big.NewInt(1000)); err != nil || hex.EncodeToString(tx.Data) != hex.EncodeToString(data) {
+		t.Errorf("bad tx call data %x", tx.Data)
+	}
+	m, params, err := abi.DecodeCall(data)
+	if err != nil || m.Name != "transfer" || fmt.Sprintf("%v", params) != "[eth:5fb84129ad9e7818f099966de975ff41213f028d 1000]" {
+		t.Errorf("bad decoded call %v: %s", params, err)
+	}
+
+	// struct parameters
+	data = must(abi.Pack("exactInputSingle", []any{to, to, 3000, to, 1, 2, 3, 0}))
+	if _, params, err := abi.DecodeCall(data); err != nil || len(params) != 1 || len(params[0].([]any)) != 8 {
+		t.Errorf("bad decoded struct call %v: %s", params, err)
+	}
+
+	// return values
+	buf := outscript.NewAbiBuffer(nil)
+	if err := buf.EncodeTypes([]string{"uint256[]"}, []int{100, 95}); err != nil {
+		t.Fatalf("failed to encode: %s", err)
+	}
+	res := must(abi.Unpack("swapExactTokensForTokens", buf.Bytes()))
+	if fmt.Sprintf("%v", res) != "[[100 95]]" {
+		t.Errorf("bad unpacked values %v", res)
+	}
+
+	// constructor parameters, without selector
+	if args := must(abi.Pack("", "Token", 1000)); len(args) != 4*32 {
+		t.Errorf("bad constructor parameters %x", args)
+	}
+
+	// custom errors
+	revert := must(outscript.EvmCall("InsufficientBalance(uint256,uint256)", 10, 20))
+	if e, params, err := abi.DecodeError(revert); err != nil || e.Name != "InsufficientBalance" || fmt.Sprintf("%v", params) != "[10 20]" {
+		t.Errorf("bad decoded error %v: %s", params, err)
+	}
+
+	if _, err := outscript.ParseEvmAbi([]byte(`[{"type": "function", "name": "f", "inputs": [{"name": "a", "type": "uint7"}]}]`)); err == nil {
+		t.Errorf("expected error for invalid type")
+	}
+	for _, v := range []string{
+		`[null]`,
+		`[{"type": "function", "name": "f", "inputs": [null]}]`,
+		`[{"type": "function", "name": "f", "inputs": [{"name": "a", "type": "tuple", "components": [null]}]}]`,
+	} {
+		if _, err := outscript.ParseEvmAbi([]byte(v)); err == nil {
+			t.Errorf("expected error for null entry in %s", v)
+		}
+	}
+}
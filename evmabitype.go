@@ -0,0 +1,254 @@
+package outscript
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// abiMaxHeadSize is the maximum size of the head of a type, so that computing sizes cannot
+// overflow
+const abiMaxHeadSize = math.MaxInt32
+
+// abiType is a parsed Solidity ABI type
+type abiType struct {
+	base   string     // elementary type such as "uint256", empty for arrays and tuples
+	elem   *abiType   // array element type
+	size   int        // array length, -1 for dynamic arrays
+	fields []*abiType // tuple members, non-nil for tuples
+}
+
+// parseAbiType parses a type such as "uint256", "bytes32[2]" or "(address,uint256)[]"
+func parseAbiType(s string) (*abiType, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasSuffix(s, "]") {
+		pos := strings.LastIndexByte(s, '[')
+		if pos <= 0 {
+			return nil, fmt.Errorf("invalid abi type %s", s)
+		}
+		elem, err := parseAbiType(s[:pos])
+		if err != nil {
+			return nil, err
+		}
+		size := -1
+		if n := s[pos+1 : len(s)-1]; n != "" {
+			size, err = strconv.Atoi(n)
+			if err != nil || size <= 0 || size > abiMaxHeadSize/elem.headSize() {
+				return nil, fmt.Errorf("invalid array length in abi type %s", s)
+			}
+		}
+		return &abiType{elem: elem, size: size}, nil
+	}
+	if strings.HasSuffix(s, ")") && (strings.HasPrefix(s, "(") || strings.HasPrefix(s, "tuple(")) {
+		inner := s[strings.IndexByte(s, '(')+1 : len(s)-1]
+		t := &abiType{fields: []*abiType{}}
+		for _, f := range splitAbiTypes(inner) {
+			ft, err := parseAbiType(f)
+			if err != nil {
+				return nil, err
+			}
+			t.fields = append(t.fields, ft)
+		}
+		if t.headSize() > abiMaxHeadSize {
+			return nil, fmt.Errorf("abi type %s is too large", s)
+		}
+		return t, nil
+	}
+
+	switch {
+	case s == "uint" || s == "int":
+		s += "256"
+	case s == "address" || s == "bool" || s == "string" || s == "bytes":
+	case strings.HasPrefix(s, "uint"):
+		if _, err := abiTypeSize(s[4:], 256, 8); err != nil {
+			return nil, fmt.Errorf("invalid abi type %s", s)
+		}
+	case strings.HasPrefix(s, "int"):
+		if _, err := abiTypeSize(s[3:], 256, 8); err != nil {
+			return nil, fmt.Errorf("invalid abi type %s", s)
+		}
+	case strings.HasPrefix(s, "bytes"):
+		if _, err := abiTypeSize(s[5:], 32, 1); err != nil {
+			return nil, fmt.Errorf("invalid abi type %s", s)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported type: %s", s)
+	}
+	return &abiType{base: s}, nil
+}
+
+// splitAbiTypes splits a comma separated list of types, ignoring commas within tuples
+func splitAbiTypes(s string) []string {
+	if strings.TrimSpace(s) == "" {
+		return nil
+	}
+	var res []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				res = append(res, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(res, s[start:])
+}
+
+// String returns the canonical representation of the type, as used in method signatures
+func (t *abiType) String() string {
+	switch {
+	case t.elem != nil && t.size < 0:
+		return t.elem.String() + "[]"
+	case t.elem != nil:
+		return t.elem.String() + "[" + strconv.Itoa(t.size) + "]"
+	case t.fields != nil:
+		names := make([]string, len(t.fields))
+		for n, f := range t.fields {
+			names[n] = f.String()
+		}
+		return "(" + strings.Join(names, ",") + ")"
+	default:
+		return t.base
+	}
+}
+
+// dynamic returns true if the type is encoded in the tail, with an offset in the head
+func (t *abiType) dynamic() bool {
+	switch {
+	case t.elem != nil:
+		return t.size < 0 || t.elem.dynamic()
+	case t.fields != nil:
+		for _, f := range t.fields {
+			if f.dynamic() {
+				return true
+			}
+		}
+		return false
+	default:
+		return t.base == "bytes" || t.base == "string"
+	}
+}
+
+// encode returns the ABI encoding of v. Values of dynamic types are returned without their offset.
+func (t *abiType) encode(v any) ([]byte, error) {
+	switch {
+	case t.elem != nil:
+		vals, err := abiValues(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t, err)
+		}
+		if t.size >= 0 && len(vals) != t.size {
+			return nil, fmt.Errorf("%s: expected %d values, got %d", t, t.size, len(vals))
+		}
+		types := make([]*abiType, len(vals))
+		for n := range types {
+			types[n] = t.elem
+		}
+		res, err := abiEncodeTuple(types, vals)
+		if err != nil {
+			return nil, err
+		}
+		if t.size < 0 {
+			res = append(abiUint(len(vals)), res...)
+		}
+		return res, nil
+	case t.fields != nil:
+		vals, err := abiValues(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t, err)
+		}
+		if len(vals) != len(t.fields) {
+			return nil, fmt.Errorf("%s: expected %d values, got %d", t, len(t.fields), len(vals))
+		}
+		return abiEncodeTuple(t.fields, vals)
+	case t.base == "bytes" || t.base == "string":
+		var b []byte
+		switch o := v.(type) {
+		case []byte:
+			b = o
+		case string:
+			b = []byte(o)
+		default:
+			return nil, fmt.Errorf("unsupported go type %T for evm abi buffer type", o)
+		}
+		res := append(abiUint(len(b)), b...)
+		if x := len(b) % 32; x != 0 {
+			res = append(res, make([]byte, 32-x)...)
+		}
+		return res, nil
+	default:
+		return abiWord(t.base, v)
+	}
+}
+
+// abiEncodeTuple encodes values as a sequence of heads followed by the tails of dynamic values
+func abiEncodeTuple(types []*abiType, vals []any) ([]byte, error) {
+	enc := make([][]byte, len(types))
+	headLen := 0
+	for n, t := range types {
+		var err error
+		enc[n], err = t.encode(vals[n])
+		if err != nil {
+			return nil, err
+		}
+		if t.dynamic() {
+			headLen += 32
+		} else {
+			headLen += len(enc[n])
+		}
+	}
+	var head, tail []byte
+	for n, t := range types {
+		if t.dynamic() {
+			head = append(head, abiUint(headLen+len(tail))...)
+			tail = append(tail, enc[n]...)
+		} else {
+			head = append(head, enc[n]...)
+		}
+	}
+	return append(head, tail...), nil
+}
+
+// abiUint returns n as a 32 bytes word
+func abiUint(n int) []byte {
+	return new(big.Int).SetInt64(int64(n)).FillBytes(make([]byte, 32))
+}
+
+// abiValues returns the elements of a slice, array or struct value
+func abiValues(v any) ([]any, error) {
+	if vals, ok := v.([]any); ok {
+		return vals, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		res := make([]any, rv.Len())
+		for n := range res {
+			res[n] = rv.Index(n).Interface()
+		}
+		return res, nil
+	case reflect.Pointer:
+		if rv.Elem().Kind() == reflect.Struct {
+			return abiValues(rv.Elem().Interface())
+		}
+	case reflect.Struct:
+		var res []any
+		for n := 0; n < rv.NumField(); n++ {
+			if rv.Type().Field(n).IsExported() {
+				res = append(res, rv.Field(n).Interface())
+			}
+		}
+		return res, nil
+	}
+	return nil, errors.New("array and tuple values must be slices, arrays or structs")
+}
@@ -0,0 +1,202 @@
+package outscript
+
+import (
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/BottleFmt/gobottle"
+	"github.com/KarpelesLab/rlp"
+	"github.com/KarpelesLab/secp256k1"
+	"golang.org/x/crypto/sha3"
+)
+
+// EvmAuthorization is an EIP-7702 authorization, signed by an account to run with the code of the
+// contract at Address. It is included in the AuthorizationList of a set code transaction, which
+// can be sent by anyone. Address must be a 20 bytes hex address, and the zero address
+// 0x0000000000000000000000000000000000000000 clears the delegation.
+type EvmAuthorization struct {
+	ChainId uint64 // 0 for an authorization valid on all chains
+	Address string // contract whose code is used, the zero address to clear the delegation
+	Nonce   uint64 // nonce of the signing account
+	Y, R, S *big.Int
+}
+
+type evmAuthorizationJson struct {
+	ChainId string `json:"chainId"`
+	Address string `json:"address"`
+	Nonce   string `json:"nonce"`
+	Y       string `json:"yParity"`
+	R       string `json:"r"`
+	S       string `json:"s"`
+}
+
+// SignHash returns the hash signed by the authority, keccak256(0x05 || rlp([chain_id, address, nonce])).
+func (a *EvmAuthorization) SignHash() ([]byte, error) {
+	addr, err := a.addressBytes()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := rlp.EncodeValue([]any{a.ChainId, addr, a.Nonce})
+	if err != nil {
+		return nil, err
+	}
+	return gobottle.Hash(append([]byte{0x05}, buf...), sha3.NewLegacyKeccak256), nil
+}
+
+// Sign signs the authorization with the key of the account delegating its code.
+func (a *EvmAuthorization) Sign(key crypto.Signer) error {
+	h, err := a.SignHash()
+	if err != nil {
+		return err
+	}
+	sig, err := evmSignHash(key, h, crypto.Hash(0))
+	if err != nil {
+		return err
+	}
+	var v byte
+	a.R, a.S, v = sig.Export()
+	a.Y = big.NewInt(int64(v))
+	return nil
+}
+
+// AuthorityPubkey recovers the public key of the account that signed the authorization.
+func (a *EvmAuthorization) AuthorityPubkey() (*secp256k1.PublicKey, error) {
+	if a.Y == nil || a.R == nil || a.S == nil {
+		return nil, errors.New("cannot recover the authority of an unsigned authorization")
+	}
+	if !a.Y.IsUint64() || a.Y.Uint64() > 1 {
+		return nil, errors.New("invalid authorization y parity")
+	}
+	r := new(secp256k1.ModNScalar)
+	if overflow := r.SetByteSlice(a.R.Bytes()); overflow {
+		return nil, errors.New("cannot read signature: invalid value for R >= group order")
+	}
+	s := new(secp256k1.ModNScalar)
+	if overflow := s.SetByteSlice(a.S.Bytes()); overflow {
+		return nil, errors.New("cannot read signature: invalid value for S >= group order")
+	}
+	if s.IsOverHalfOrder() {
+		return nil, errors.New("invalid authorization signature: high S value")
+	}
+	h, err := a.SignHash()
+	if err != nil {
+		return nil, err
+	}
+	return secp256k1.NewSignatureWithRecoveryCode(r, s, byte(a.Y.Uint64())).RecoverPublicKey(h)
+}
+
+// Authority recovers and returns the EIP-55 checksummed address of the account that signed the
+// authorization.
+func (a *EvmAuthorization) Authority() (string, error) {
+	pubkey, err := a.AuthorityPubkey()
+	if err != nil {
+		return "", err
+	}
+	addr, err := New(pubkey).Generate("eth")
+	if err != nil {
+		return "", err
+	}
+	return eip55(addr), nil
+}
+
+// addressBytes returns Address as 20 bytes
+func (a *EvmAuthorization) addressBytes() ([]byte, error) {
+	s, ok := strings.CutPrefix(a.Address, "0x")
+	if !ok || len(s) != 40 {
+		return nil, fmt.Errorf("invalid authorization address %q, a 20 bytes address is required", a.Address)
+	}
+	return hex.DecodeString(s)
+}
+
+// rlpValue returns the authorization as rlp fields
+func (a *EvmAuthorization) rlpValue() ([]any, error) {
+	addr, err := a.addressBytes()
+	if err != nil {
+		return nil, err
+	}
+	return []any{a.ChainId, addr, a.Nonce, a.Y, a.R, a.S}, nil
+}
+
+// MarshalJSON encodes the authorization as in the authorizationList of JSON-RPC transactions.
+func (a *EvmAuthorization) MarshalJSON() ([]byte, error) {
+	obj := &evmAuthorizationJson{
+		ChainId: "0x" + strconv.FormatUint(a.ChainId, 16),
+		Address: a.Address,
+		Nonce:   "0x" + strconv.FormatUint(a.Nonce, 16),
+	}
+	if a.Y != nil && a.R != nil && a.S != nil {
+		obj.Y = "0x" + a.Y.Text(16)
+		obj.R = "0x" + a.R.Text(16)
+		obj.S = "0x" + a.S.Text(16)
+	}
+	return json.Marshal(obj)
+}
+
+// UnmarshalJSON decodes an authorization from its JSON-RPC representation.
+func (a *EvmAuthorization) UnmarshalJSON(b []byte) error {
+	var obj *evmAuthorizationJson
+	err := json.Unmarshal(b, &obj)
+	if err != nil {
+		return err
+	}
+	a.Address = obj.Address
+	if a.ChainId, err = strconv.ParseUint(obj.ChainId, 0, 64); err != nil {
+		return err
+	}
+	if a.Nonce, err = strconv.ParseUint(obj.Nonce, 0, 64); err != nil {
+		return err
+	}
+	for _, v := range []struct {
+		s    string
+		p    **big.Int
+		name string
+	}{{obj.Y, &a.Y, "yParity"}, {obj.R, &a.R, "r"}, {obj.S, &a.S, "s"}} {
+		if v.s == "" {
+			continue
+		}
+		var ok bool
+		if *v.p, ok = new(big.Int).SetString(v.s, 0); !ok {
+			return errors.New("invalid value in " + v.name)
+		}
+	}
+	return nil
+}
+
+// parseEvmAuthorizationList decodes an rlp encoded authorization list
+func parseEvmAuthorizationList(v any) ([]*EvmAuthorization, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, errors.New("invalid authorization list in transaction")
+	}
+	res := make([]*EvmAuthorization, len(list))
+	for n, item := range list {
+		t, ok := item.([]any)
+		if !ok || len(t) != 6 {
+			return nil, errors.New("invalid authorization in transaction")
+		}
+		f := make([][]byte, 6)
+		for i, x := range t {
+			if f[i], ok = x.([]byte); !ok {
+				return nil, errors.New("invalid authorization in transaction")
+			}
+		}
+		if len(f[1]) != 20 {
+			return nil, errors.New("invalid authorization address in transaction")
+		}
+		res[n] = &EvmAuthorization{
+			ChainId: rlp.DecodeUint64(f[0]),
+			Address: "0x" + hex.EncodeToString(f[1]),
+			Nonce:   rlp.DecodeUint64(f[2]),
+			Y:       new(big.Int).SetBytes(f[3]),
+			R:       new(big.Int).SetBytes(f[4]),
+			S:       new(big.Int).SetBytes(f[5]),
+		}
+	}
+	return res, nil
+}
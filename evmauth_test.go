@@ -0,0 +1,103 @@
+package outscript_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestEvmTxEIP7702(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	authKey := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")))
+
+	auth := &outscript.EvmAuthorization{ChainId: 1, Address: "0x63c0c19a282a1b52b07dd5a65b58948a07dae32b", Nonce: 3}
+	if h := hex.EncodeToString(must(auth.SignHash())); h != "71fc1b707b00e9a89ef0c53069b77ea1ace2c999eb27bbded3deb3881d545dd2" {
+		t.Errorf("bad authorization hash %s", h)
+	}
+	if err := auth.Sign(authKey); err != nil {
+		t.Fatalf("failed to sign authorization: %s", err)
+	}
+	if auth.Y.Int64() != 1 || auth.R.Text(16) != "6f0fa8b68d24a222555cd7f3d4c322843b18d70ca123374f47a6d98c6bfef47d" {
+		t.Errorf("bad authorization signature %v %x", auth.Y, auth.R)
+	}
+	if a := must(auth.Authority()); a != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("bad authority %s", a)
+	}
+
+	tx := &outscript.EvmTx{
+		Type:              outscript.EvmTxEIP7702,
+		ChainId:           1,
+		Nonce:             7,
+		GasTipCap:         big.NewInt(1000000000),
+		GasFeeCap:         big.NewInt(30000000000),
+		Gas:               100000,
+		To:                "0x5fb84129ad9e7818f099966de975ff41213f028d",
+		Value:             big.NewInt(0),
+		Data:              must(hex.DecodeString("a9059cbb")),
+		AuthorizationList: []*outscript.EvmAuthorization{auth},
+	}
+	if err := tx.Sign(key); err != nil {
+		t.Fatalf("Sign failed: %s", err)
+	}
+	const exp = "04f8ce0107843b9aca008506fc23ac00830186a0945fb84129ad9e7818f099966de975ff41213f028d8084a9059cbbc0f85cf85a019463c0c19a282a1b52b07dd5a65b58948a07dae32b0301a06f0fa8b68d24a222555cd7f3d4c322843b18d70ca123374f47a6d98c6bfef47da00710700a635e079842b0e1093947893fed2f64cfef34d4233943aa459c06a64280a0e57ac447a71633ec01123b1cb5608edb69f8ba75fb1ece8be78b831554c1c4d2a002c3e1c4b20ebc868cd10d733fa3980a176ba5c17dc055ade3eef124a468fbe0"
+	if res := hex.EncodeToString(must(tx.MarshalBinary())); res != exp {
+		t.Errorf("bad signed set code tx %s", res)
+	}
+
+	var tx2 outscript.EvmTx
+	if err := tx2.UnmarshalBinary(must(hex.DecodeString(exp))); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %s", err)
+	}
+	if tx2.Type != outscript.EvmTxEIP7702 || len(tx2.AuthorizationList) != 1 || tx2.AuthorizationList[0].Nonce != 3 {
+		t.Errorf("bad parsed set code tx %+v", tx2)
+	}
+	if must(tx2.SenderAddress()) != "0x2AeB8ADD8337360E088B7D9ce4e857b9BE60f3a7" {
+		t.Errorf("unexpected sender: %s", must(tx2.SenderAddress()))
+	}
+	if a := must(tx2.AuthorizationList[0].Authority()); a != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("bad parsed authority %s", a)
+	}
+
+	jsonData := must(json.Marshal(tx))
+	if !bytes.Contains(jsonData, []byte(`"authorizationList":[{"chainId":"0x1","address":"0x63c0c19a282a1b52b07dd5a65b58948a07dae32b","nonce":"0x3","yParity":"0x1","r":"0x6f0fa8b68d24a222555cd7f3d4c322843b18d70ca123374f47a6d98c6bfef47d"`)) {
+		t.Errorf("bad json %s", jsonData)
+	}
+	var tx3 outscript.EvmTx
+	if err := json.Unmarshal(jsonData, &tx3); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %s", err)
+	}
+	if res := hex.EncodeToString(must(tx3.MarshalBinary())); res != exp {
+		t.Errorf("bad set code tx after json round-trip %s", res)
+	}
+
+	// high S values are invalid in authorizations
+	auth.S = new(big.Int).Sub(secp256k1.S256().N, auth.S)
+	auth.Y = big.NewInt(0)
+	if _, err := auth.Authority(); err == nil {
+		t.Errorf("expected error for high S authorization")
+	}
+
+	// the delegation is cleared with the zero address, which must be given in full
+	unset := &outscript.EvmAuthorization{ChainId: 1, Address: "0x0000000000000000000000000000000000000000", Nonce: 4}
+	if err := unset.Sign(authKey); err != nil {
+		t.Fatalf("failed to sign clearing authorization: %s", err)
+	}
+	if a := must(unset.Authority()); a != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("bad clearing authority %s", a)
+	}
+	for _, addr := range []string{"0x0", "", "0x63c0c19a282a1b52b07dd5a65b58948a07dae3", "63c0c19a282a1b52b07dd5a65b58948a07dae32b"} {
+		bad := &outscript.EvmAuthorization{ChainId: 1, Address: addr, Nonce: 4}
+		if _, err := bad.SignHash(); err == nil {
+			t.Errorf("expected error for authorization address %q", addr)
+		}
+		tx.AuthorizationList = []*outscript.EvmAuthorization{unset, bad}
+		if err := tx.Sign(key); err == nil {
+			t.Errorf("expected error signing transaction with authorization address %q", addr)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package outscript
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/BottleFmt/gobottle"
+)
+
+// EvmBlobSize is the size of an EIP-4844 blob, 4096 field elements of 32 bytes.
+const EvmBlobSize = 131072
+
+// EvmBlobSidecar holds the blobs of a blob transaction with their KZG commitments and proofs. It
+// is only part of the network form of the transaction, as sent to eth_sendRawTransaction, and not
+// of its hash. Commitments and proofs must be computed by a KZG library using the Ethereum trusted
+// setup.
+type EvmBlobSidecar struct {
+	Version     byte     // 0 for EIP-4844 blob proofs, 1 for EIP-7594 cell proofs
+	Blobs       [][]byte // EvmBlobSize bytes each
+	Commitments [][]byte // 48 bytes each
+	Proofs      [][]byte // 48 bytes each, one per blob in version 0 or 128 per blob in version 1
+}
+
+// EvmBlobVersionedHash returns the versioned hash of a KZG commitment, as listed in the
+// BlobVersionedHashes of a blob transaction.
+func EvmBlobVersionedHash(commitment []byte) []byte {
+	h := gobottle.Hash(commitment, sha256.New)
+	h[0] = 0x01 // VERSIONED_HASH_VERSION_KZG
+	return h
+}
+
+// VersionedHashes returns the versioned hashes of the commitments of the sidecar.
+func (s *EvmBlobSidecar) VersionedHashes() [][]byte {
+	res := make([][]byte, len(s.Commitments))
+	for n, c := range s.Commitments {
+		res[n] = EvmBlobVersionedHash(c)
+	}
+	return res
+}
+
+// rlpValue returns the network wrapper of the rlp fields of a signed transaction
+func (s *EvmBlobSidecar) rlpValue(tx []any) []any {
+	if s.Version == 0 {
+		return []any{tx, evmRlpList(s.Blobs), evmRlpList(s.Commitments), evmRlpList(s.Proofs)}
+	}
+	return []any{tx, s.Version, evmRlpList(s.Blobs), evmRlpList(s.Commitments), evmRlpList(s.Proofs)}
+}
+
+// parseEvmBlobSidecar decodes the sidecar of a network wrapper, following the transaction fields
+func parseEvmBlobSidecar(v []any) (*EvmBlobSidecar, error) {
+	s := &EvmBlobSidecar{}
+	if len(v) == 4 {
+		// EIP-7594 added a version
+		ver, ok := v[0].([]byte)
+		if !ok || len(ver) != 1 || ver[0] == 0 {
+			return nil, errors.New("invalid blob sidecar version")
+		}
+		s.Version = ver[0]
+		v = v[1:]
+	}
+	if len(v) != 3 {
+		return nil, errors.New("invalid blob transaction network wrapper")
+	}
+	var err error
+	if s.Blobs, err = parseEvmRlpList(v[0], EvmBlobSize); err != nil {
+		return nil, err
+	}
+	if s.Commitments, err = parseEvmRlpList(v[1], 48); err != nil {
+		return nil, err
+	}
+	if s.Proofs, err = parseEvmRlpList(v[2], 48); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// evmRlpList returns a list of buffers as rlp fields
+func evmRlpList(l [][]byte) []any {
+	res := make([]any, len(l))
+	for n, v := range l {
+		res[n] = v
+	}
+	return res
+}
+
+// parseEvmRlpList decodes a rlp list of buffers of the given size
+func parseEvmRlpList(v any, size int) ([][]byte, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, errors.New("invalid rlp list in transaction")
+	}
+	res := make([][]byte, len(list))
+	for n, item := range list {
+		buf, ok := item.([]byte)
+		if !ok || len(buf) != size {
+			return nil, errors.New("invalid rlp list element in transaction")
+		}
+		res[n] = buf
+	}
+	return res, nil
+}
@@ -0,0 +1,117 @@
+package outscript_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+	"golang.org/x/crypto/sha3"
+)
+
+func TestEvmBlobVersionedHash(t *testing.T) {
+	// commitment of the empty blob, the point at infinity
+	comm := append([]byte{0xc0}, make([]byte, 47)...)
+	if res := hex.EncodeToString(outscript.EvmBlobVersionedHash(comm)); res != "010657f37554c781402a22917dee2f75def7ab966d7b770905398eba3c444014" {
+		t.Errorf("bad versioned hash %s", res)
+	}
+}
+
+func TestEvmTxEIP4844(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	comm := append([]byte{0xc0}, make([]byte, 47)...)
+	sidecar := &outscript.EvmBlobSidecar{
+		Blobs:       [][]byte{make([]byte, outscript.EvmBlobSize)},
+		Commitments: [][]byte{comm},
+		Proofs:      [][]byte{comm},
+	}
+	tx := &outscript.EvmTx{
+		Type:                outscript.EvmTxEIP4844,
+		ChainId:             1,
+		Nonce:               7,
+		GasTipCap:           big.NewInt(1000000000),
+		GasFeeCap:           big.NewInt(30000000000),
+		Gas:                 21000,
+		To:                  "0x5fb84129ad9e7818f099966de975ff41213f028d",
+		Value:               big.NewInt(0),
+		MaxFeePerBlobGas:    big.NewInt(3000000000),
+		BlobVersionedHashes: sidecar.VersionedHashes(),
+	}
+	if err := tx.Sign(key); err != nil {
+		t.Fatalf("Sign failed: %s", err)
+	}
+	const exp = "03f8920107843b9aca008506fc23ac00825208945fb84129ad9e7818f099966de975ff41213f028d8080c084b2d05e00e1a0010657f37554c781402a22917dee2f75def7ab966d7b770905398eba3c44401480a05e8f41d0fb4fd8e7d2b0eed774bec727203a2baa3643a5b9c81238d97a9ad664a026107165772e13843a7ae640ba85ff3253c42cf4d22b5598d7ed6d375ea29908"
+	if res := hex.EncodeToString(must(tx.MarshalBinary())); res != exp {
+		t.Errorf("bad signed blob tx %s", res)
+	}
+
+	var tx2 outscript.EvmTx
+	if err := tx2.UnmarshalBinary(must(hex.DecodeString(exp))); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %s", err)
+	}
+	if tx2.Type != outscript.EvmTxEIP4844 || tx2.MaxFeePerBlobGas.Int64() != 3000000000 || len(tx2.BlobVersionedHashes) != 1 || tx2.Sidecar != nil {
+		t.Errorf("bad parsed blob tx %+v", tx2)
+	}
+	if must(tx2.SenderAddress()) != "0x2AeB8ADD8337360E088B7D9ce4e857b9BE60f3a7" {
+		t.Errorf("unexpected sender: %s", must(tx2.SenderAddress()))
+	}
+
+	// network form includes the blobs, but not the hash
+	for _, v := range []struct {
+		version byte
+		proofs  int
+		exp     string // keccak256 of the network form
+	}{
+		{0, 1, "8e61d1f2ff83567fc8d73003b758a55d7704b14e6ff53e79babd55c2b5604415"},
+		{1, 128, "a13f13331a33d4e43b47efc67d70312ef04a4e48a97e5d5b9e69f01d319058ed"},
+	} {
+		sidecar.Version = v.version
+		sidecar.Proofs = make([][]byte, v.proofs)
+		for n := range sidecar.Proofs {
+			sidecar.Proofs[n] = comm
+		}
+		tx.Sidecar = sidecar
+		data := must(tx.MarshalBinary())
+		h := sha3.NewLegacyKeccak256()
+		h.Write(data)
+		if res := hex.EncodeToString(h.Sum(nil)); res != v.exp {
+			t.Errorf("bad network form for version %d: %s", v.version, res)
+		}
+		if res := hex.EncodeToString(must(tx.Hash())); res != "c2af1b6b21e38457ca215bb9ab096cc7cd9ce506e6c25ed76e1a1ab423c3d6bd" {
+			t.Errorf("bad blob tx hash %s", res)
+		}
+
+		var tx3 outscript.EvmTx
+		if err := tx3.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary of network form failed: %s", err)
+		}
+		if tx3.Sidecar == nil || tx3.Sidecar.Version != v.version || len(tx3.Sidecar.Proofs) != v.proofs || !bytes.Equal(must(tx3.MarshalBinary()), data) {
+			t.Errorf("bad parsed network form for version %d", v.version)
+		}
+	}
+
+	// JSON
+	jsonData := must(json.Marshal(tx))
+	if !bytes.Contains(jsonData, []byte(`"maxFeePerBlobGas":"0xb2d05e00","blobVersionedHashes":["0x010657f37554c781402a22917dee2f75def7ab966d7b770905398eba3c444014"]`)) {
+		t.Errorf("bad json %s", jsonData)
+	}
+	var tx4 outscript.EvmTx
+	if err := json.Unmarshal(jsonData, &tx4); err != nil {
+		t.Fatalf("UnmarshalJSON failed: %s", err)
+	}
+	if res := hex.EncodeToString(must(tx4.MarshalBinary())); res != exp {
+		t.Errorf("bad blob tx after json round-trip %s", res)
+	}
+	tx4.MaxFeePerBlobGas = nil
+	if jsonData := must(json.Marshal(&tx4)); !bytes.Contains(jsonData, []byte(`"maxFeePerBlobGas":"0x0"`)) {
+		t.Errorf("bad json without blob fee %s", jsonData)
+	}
+
+	if err := tx4.UnmarshalBinary([]byte{0x03, 0xc0, 0xc0}); err == nil || !strings.Contains(err.Error(), "EIP-4844") {
+		t.Errorf("expected EIP-4844 error for invalid rlp data, got %v", err)
+	}
+}
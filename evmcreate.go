@@ -0,0 +1,54 @@
+package outscript
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/BottleFmt/gobottle"
+	"github.com/KarpelesLab/rlp"
+	"golang.org/x/crypto/sha3"
+)
+
+// EvmCreateAddress returns the address of the contract deployed with the CREATE opcode or a
+// contract creation transaction by sender with the given nonce, keccak256(rlp([sender, nonce]))[12:].
+// sender can be a *Out, a 0x-prefixed hex string or a 20 bytes []byte.
+func EvmCreateAddress(sender any, nonce uint64) (*Out, error) {
+	addr, err := abiAddress(sender)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := rlp.EncodeValue([]any{addr, nonce})
+	if err != nil {
+		return nil, err
+	}
+	return makeOut("eth", gobottle.Hash(buf, sha3.NewLegacyKeccak256)[12:], "evm"), nil
+}
+
+// EvmCreate2Address returns the address of the contract deployed with the CREATE2 opcode by
+// deployer, keccak256(0xff || deployer || salt || keccak256(initCode))[12:]. salt must be 32 bytes
+// long and deployer is given as in [EvmCreateAddress].
+func EvmCreate2Address(deployer any, salt, initCode []byte) (*Out, error) {
+	addr, err := abiAddress(deployer)
+	if err != nil {
+		return nil, err
+	}
+	if len(salt) != 32 {
+		return nil, errors.New("CREATE2 salt must be 32 bytes long")
+	}
+	codeHash := gobottle.Hash(initCode, sha3.NewLegacyKeccak256)
+	buf := slices.Concat([]byte{0xff}, addr, salt, codeHash)
+	return makeOut("eth", gobottle.Hash(buf, sha3.NewLegacyKeccak256)[12:], "evm"), nil
+}
+
+// ContractAddress returns the address of the contract deployed by a signed contract creation
+// transaction.
+func (tx *EvmTx) ContractAddress() (*Out, error) {
+	if !tx.IsContractCreation() {
+		return nil, errors.New("transaction does not create a contract")
+	}
+	sender, err := tx.SenderAddress()
+	if err != nil {
+		return nil, err
+	}
+	return EvmCreateAddress(sender, tx.Nonce)
+}
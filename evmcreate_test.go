@@ -0,0 +1,119 @@
+package outscript_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestEvmCreateAddress(t *testing.T) {
+	for n, exp := range []string{
+		"0xcd234a471b72ba2f1ccf0a70fcaba648a5eecd8d",
+		"0x343c43a37d37dff08ae8c4a11544c718abb4fcf8",
+		"0xf778b86fa74e846c4f0a1fbd1335fe81c00a0c91",
+	} {
+		out := must(outscript.EvmCreateAddress("0x6ac7ea33f8831ea9dcc53393aaa88b25a785dbf0", uint64(n)))
+		if addr := must(out.Address("eth")); strings.ToLower(addr) != exp {
+			t.Errorf("bad CREATE address for nonce %d: %s", n, addr)
+		}
+	}
+}
+
+func TestEvmCreate2Address(t *testing.T) {
+	// EIP-1014 examples
+	for _, v := range [][4]string{
+		{"0x0000000000000000000000000000000000000000", strings.Repeat("00", 32), "00", "0x4D1A2e2bB4F88F0250f26Ffff098B0b30B26BF38"},
+		{"0xdeadbeef00000000000000000000000000000000", strings.Repeat("00", 32), "00", "0xB928f69Bb1D91Cd65274e3c79d8986362984fDA3"},
+		{"0x00000000000000000000000000000000deadbeef", strings.Repeat("00", 28) + "cafebabe", strings.Repeat("deadbeef", 11), "0x1d8bfDC5D46DC4f61D6b6115972536eBE6A8854C"},
+		{"0x0000000000000000000000000000000000000000", strings.Repeat("00", 32), "", "0xE33C0C7F7df4809055C3ebA6c09CFe4BaF1BD9e0"},
+	} {
+		out := must(outscript.EvmCreate2Address(v[0], must(hex.DecodeString(v[1])), must(hex.DecodeString(v[2]))))
+		if addr := must(out.Address("eth")); addr != v[3] {
+			t.Errorf("bad CREATE2 address for %s: %s", v[0], addr)
+		}
+	}
+	if _, err := outscript.EvmCreate2Address("0x0000000000000000000000000000000000000000", []byte{1}, nil); err == nil {
+		t.Errorf("expected error for short salt")
+	}
+}
+
+func TestEvmTxContractCreation(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	for _, typ := range []outscript.EvmTxType{outscript.EvmTxLegacy, outscript.EvmTxEIP1559} {
+		tx := &outscript.EvmTx{
+			Type:      typ,
+			ChainId:   1,
+			Nonce:     2,
+			GasTipCap: big.NewInt(1000000000),
+			GasFeeCap: big.NewInt(30000000000),
+			Gas:       100000,
+			Value:     big.NewInt(0),
+			Data:      must(hex.DecodeString("6080604052")),
+		}
+		if err := tx.Sign(key); err != nil {
+			t.Fatalf("Sign failed: %s", err)
+		}
+		data := must(tx.MarshalBinary())
+
+		var tx2 outscript.EvmTx
+		if err := tx2.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %s", err)
+		}
+		if !tx2.IsContractCreation() || tx2.To != "" || !bytes.Equal(must(tx2.MarshalBinary()), data) {
+			t.Errorf("bad contract creation round-trip for type %d: %q", typ, tx2.To)
+		}
+		// 0x2aeb8add8337360e088b7d9ce4e857b9be60f3a7 with nonce 2
+		if addr := must(must(tx2.ContractAddress()).Address("eth")); addr != must(must(outscript.EvmCreateAddress("0x2aeb8add8337360e088b7d9ce4e857b9be60f3a7", 2)).Address("eth")) {
+			t.Errorf("bad contract address %s", addr)
+		}
+
+		jsonData := must(json.Marshal(tx))
+		if bytes.Contains(jsonData, []byte(`"to"`)) {
+			t.Errorf("unexpected destination in json %s", jsonData)
+		}
+		var tx3 outscript.EvmTx
+		if err := json.Unmarshal(jsonData, &tx3); err != nil {
+			t.Fatalf("UnmarshalJSON failed: %s", err)
+		}
+		if !bytes.Equal(must(tx3.MarshalBinary()), data) {
+			t.Errorf("bad contract creation after json round-trip for type %d", typ)
+		}
+	}
+
+	// legacy "0x" destination is still a contract creation
+	tx := &outscript.EvmTx{To: "0x", Value: big.NewInt(0), GasFeeCap: big.NewInt(1)}
+	if !tx.IsContractCreation() {
+		t.Errorf("expected contract creation")
+	}
+	if _, err := tx.MarshalBinary(); err != nil {
+		t.Errorf("failed to encode contract creation: %s", err)
+	}
+
+	// blob and set code transactions must have a destination
+	for _, typ := range []outscript.EvmTxType{outscript.EvmTxEIP4844, outscript.EvmTxEIP7702} {
+		tx := &outscript.EvmTx{Type: typ, ChainId: 1, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1), Value: big.NewInt(0), MaxFeePerBlobGas: big.NewInt(1)}
+		if tx.RlpFields() != nil {
+			t.Errorf("expected no rlp fields for contract creation of type %d", typ)
+		}
+		if _, err := tx.SignBytes(); err == nil {
+			t.Errorf("expected error encoding contract creation of type %d", typ)
+		}
+		if err := tx.Sign(key); err == nil {
+			t.Errorf("expected error signing contract creation of type %d", typ)
+		}
+		tx.To = "0x5fb84129ad9e7818f099966de975ff41213f028d"
+		data := must(tx.SignBytes())
+		// replace the destination with an empty value
+		data = slices.Concat([]byte{data[0], data[1] - 20}, bytes.Replace(data[2:], must(hex.DecodeString("945fb84129ad9e7818f099966de975ff41213f028d")), []byte{0x80}, 1))
+		if err := new(outscript.EvmTx).UnmarshalBinary(data); err == nil {
+			t.Errorf("expected error parsing contract creation of type %d", typ)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package outscript
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/BottleFmt/gobottle"
+	"golang.org/x/crypto/sha3"
+)
+
+// EvmLog is an event log as returned by eth_getLogs or in transaction receipts.
+type EvmLog struct {
+	Address     string
+	Topics      [][]byte
+	Data        []byte
+	BlockNumber uint64
+	BlockHash   []byte
+	TxHash      []byte
+	TxIndex     uint64
+	LogIndex    uint64
+	Removed     bool // log removed by a chain reorganization
+}
+
+type evmLogJson struct {
+	Address     string   `json:"address"`
+	Topics      []string `json:"topics"`
+	Data        string   `json:"data"`
+	BlockNumber string   `json:"blockNumber"`
+	BlockHash   string   `json:"blockHash"`
+	TxHash      string   `json:"transactionHash"`
+	TxIndex     string   `json:"transactionIndex"`
+	LogIndex    string   `json:"logIndex"`
+	Removed     bool     `json:"removed"`
+}
+
+// UnmarshalJSON decodes a log as returned by the JSON-RPC API.
+func (l *EvmLog) UnmarshalJSON(b []byte) error {
+	var obj *evmLogJson
+	err := json.Unmarshal(b, &obj)
+	if err != nil {
+		return err
+	}
+	l.Address = obj.Address
+	l.Removed = obj.Removed
+	l.Topics = make([][]byte, len(obj.Topics))
+	for n, t := range obj.Topics {
+		if l.Topics[n], err = parseEthBufferHex(t); err != nil {
+			return err
+		}
+	}
+	if l.Data, err = parseEthBufferHex(obj.Data); err != nil {
+		return err
+	}
+	if obj.BlockHash != "" {
+		// pending logs have no block
+		if l.BlockHash, err = parseEthBufferHex(obj.BlockHash); err != nil {
+			return err
+		}
+	}
+	if obj.TxHash != "" {
+		if l.TxHash, err = parseEthBufferHex(obj.TxHash); err != nil {
+			return err
+		}
+	}
+	for _, v := range []struct {
+		s string
+		p *uint64
+	}{{obj.BlockNumber, &l.BlockNumber}, {obj.TxIndex, &l.TxIndex}, {obj.LogIndex, &l.LogIndex}} {
+		if v.s == "" {
+			continue
+		}
+		if *v.p, err = strconv.ParseUint(v.s, 0, 64); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evmEvent is an event definition with the indexed flag of each of its parameters
+type evmEvent struct {
+	sig       string
+	types     []*abiType
+	indexed   []bool
+	anonymous bool // no signature topic
+}
+
+// parseEvmEvent parses an event such as "Transfer(address indexed,address indexed,uint256)"
+func parseEvmEvent(event string) (*evmEvent, error) {
+	params, err := abiParams(event)
+	if err != nil {
+		return nil, err
+	}
+	ev := &evmEvent{types: make([]*abiType, len(params)), indexed: make([]bool, len(params))}
+	names := make([]string, len(params))
+	for n, p := range params {
+		p, ev.indexed[n] = strings.CutSuffix(strings.TrimSpace(p), " indexed")
+		if ev.types[n], err = parseAbiType(p); err != nil {
+			return nil, err
+		}
+		names[n] = ev.types[n].String()
+	}
+	ev.sig = strings.TrimSpace(event[:strings.IndexByte(event, '(')]) + "(" + strings.Join(names, ",") + ")"
+	return ev, nil
+}
+
+// evmAbiEvent returns the event definition of a JSON ABI event entry
+func evmAbiEvent(e *EvmAbiEntry) *evmEvent {
+	ev := &evmEvent{sig: e.sig, types: make([]*abiType, len(e.Inputs)), indexed: make([]bool, len(e.Inputs)), anonymous: e.Anonymous}
+	for n, t := range e.InputTypes() {
+		ev.types[n], _ = parseAbiType(t) // checked when parsing
+		ev.indexed[n] = e.Inputs[n].Indexed
+	}
+	return ev
+}
+
+// topicHashed returns true if indexed values of type t are stored as the keccak256 hash of their
+// value rather than the value itself
+func (t *abiType) topicHashed() bool {
+	return t.elem != nil || t.fields != nil || t.dynamic()
+}
+
+// EvmEventTopic returns the first topic of logs emitted by event, which is the keccak256 hash of
+// its signature such as "Transfer(address,address,uint256)". Indexed markers are ignored.
+func EvmEventTopic(event string) ([]byte, error) {
+	ev, err := parseEvmEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	return gobottle.Hash([]byte(ev.sig), sha3.NewLegacyKeccak256), nil
+}
+
+// EvmDecodeLog decodes the parameters of a log emitted by event, which is the event signature
+// with its indexed parameters marked such as "Transfer(address indexed,address indexed,uint256)".
+// Indexed parameters are read from topics and the others from data, and values are returned in
+// the order of the signature using the same types as [AbiDecodeTypes]. Indexed strings, bytes,
+// arrays and tuples are only stored as the keccak256 hash of their value, which is returned as
+// a 32 bytes []byte.
+func EvmDecodeLog(event string, topics [][]byte, data []byte) ([]any, error) {
+	ev, err := parseEvmEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	return ev.decode(topics, data)
+}
+
+func (ev *evmEvent) decode(topics [][]byte, data []byte) ([]any, error) {
+	if !ev.anonymous {
+		if len(topics) == 0 || !bytes.Equal(topics[0], gobottle.Hash([]byte(ev.sig), sha3.NewLegacyKeccak256)) {
+			return nil, fmt.Errorf("log does not match event %s", ev.sig)
+		}
+		topics = topics[1:]
+	}
+
+	var dataTypes []*abiType
+	for n, t := range ev.types {
+		if !ev.indexed[n] {
+			dataTypes = append(dataTypes, t)
+		}
+	}
+	vals, err := abiDecodeTuple(dataTypes, data)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]any, len(ev.types))
+	for n, t := range ev.types {
+		if !ev.indexed[n] {
+			res[n], vals = vals[0], vals[1:]
+			continue
+		}
+		if len(topics) == 0 {
+			return nil, fmt.Errorf("log has not enough topics for event %s", ev.sig)
+		}
+		topic := topics[0]
+		topics = topics[1:]
+		if len(topic) != 32 {
+			return nil, errors.New("invalid log topic length")
+		}
+		if t.topicHashed() {
+			res[n] = bytes.Clone(topic)
+			continue
+		}
+		if res[n], err = t.decode(topic); err != nil {
+			return nil, err
+		}
+	}
+	if len(topics) != 0 {
+		return nil, fmt.Errorf("log has too many topics for event %s", ev.sig)
+	}
+	return res, nil
+}
+
+// EvmLogTopics returns the topics filter of eth_getLogs matching logs of event, given as in
+// [EvmDecodeLog]. args are the values of the indexed parameters in order: nil matches any value
+// and a []any matches any of the values it contains. Missing trailing args match any value. The
+// returned value is ready to be encoded as the "topics" field of the filter.
+func EvmLogTopics(event string, args ...any) ([]any, error) {
+	ev, err := parseEvmEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	return ev.topics(args)
+}
+
+func (ev *evmEvent) topics(args []any) ([]any, error) {
+	var res []any
+	if !ev.anonymous {
+		res = append(res, "0x"+hex.EncodeToString(gobottle.Hash([]byte(ev.sig), sha3.NewLegacyKeccak256)))
+	}
+	var types []*abiType
+	for n, t := range ev.types {
+		if ev.indexed[n] {
+			types = append(types, t)
+		}
+	}
+	if len(args) > len(types) {
+		return nil, fmt.Errorf("event %s has only %d indexed parameters", ev.sig, len(types))
+	}
+	for n, arg := range args {
+		switch a := arg.(type) {
+		case nil:
+			res = append(res, nil)
+		case []any:
+			alt := make([]string, len(a))
+			for i, v := range a {
+				topic, err := evmTopic(types[n], v)
+				if err != nil {
+					return nil, err
+				}
+				alt[i] = "0x" + hex.EncodeToString(topic)
+			}
+			res = append(res, alt)
+		default:
+			topic, err := evmTopic(types[n], a)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, "0x"+hex.EncodeToString(topic))
+		}
+	}
+	// trailing wildcards are implicit
+	for len(res) > 0 && res[len(res)-1] == nil {
+		res = res[:len(res)-1]
+	}
+	return res, nil
+}
+
+// evmTopic returns the topic of an indexed value of type t
+func evmTopic(t *abiType, v any) ([]byte, error) {
+	switch {
+	case t.base == "string" || t.base == "bytes":
+		var b []byte
+		if s, ok := v.(string); ok && t.base == "string" {
+			b = []byte(s)
+		} else {
+			var err error
+			if b, err = abiBytes(v); err != nil {
+				return nil, err
+			}
+		}
+		return gobottle.Hash(b, sha3.NewLegacyKeccak256), nil
+	case t.topicHashed():
+		return nil, fmt.Errorf("indexed %s values are not supported in topic filters", t)
+	default:
+		return abiWord(t.base, v)
+	}
+}
+
+// DecodeLog returns the event matching a log and its decoded parameters, see [EvmDecodeLog].
+// Anonymous events cannot be matched and are ignored.
+func (abi *EvmAbi) DecodeLog(topics [][]byte, data []byte) (*EvmAbiEntry, []any, error) {
+	if len(topics) > 0 {
+		for _, e := range abi.Events {
+			if !e.Anonymous && bytes.Equal(e.ID(), topics[0]) {
+				res, err := evmAbiEvent(e).decode(topics, data)
+				return e, res, err
+			}
+		}
+	}
+	return nil, nil, errors.New("log does not match any event of the abi")
+}
+
+// LogTopics returns the topics filter of eth_getLogs matching logs of event, see [EvmLogTopics].
+func (abi *EvmAbi) LogTopics(event string, args ...any) ([]any, error) {
+	e, err := abi.Event(event)
+	if err != nil {
+		return nil, err
+	}
+	return evmAbiEvent(e).topics(args)
+}
@@ -0,0 +1,105 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+)
+
+const testEvmLog = `{
+	"address": "0xdac17f958d2ee523a2206206994597c13d831ec7",
+	"topics": [
+		"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",
+		"0x0000000000000000000000005fb84129ad9e7818f099966de975ff41213f028d",
+		"0x000000000000000000000000dac17f958d2ee523a2206206994597c13d831ec7"
+	],
+	"data": "0x00000000000000000000000000000000000000000000000000000000000f4240",
+	"blockNumber": "0x1312d00",
+	"blockHash": "0x1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8",
+	"transactionHash": "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925",
+	"transactionIndex": "0x2a",
+	"logIndex": "0x7",
+	"removed": false
+}`
+
+func TestEvmEventTopic(t *testing.T) {
+	for _, v := range [][2]string{
+		{"Transfer(address,address,uint256)", "ddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"},
+		{"Approval(address indexed,address indexed,uint)", "8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"},
+		{"Swap(address indexed, uint256, uint256, uint256, uint256, address indexed)", "d78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822"},
+	} {
+		if res := hex.EncodeToString(must(outscript.EvmEventTopic(v[0]))); res != v[1] {
+			t.Errorf("bad topic for %s: %s", v[0], res)
+		}
+	}
+}
+
+func TestEvmDecodeLog(t *testing.T) {
+	var l *outscript.EvmLog
+	if err := json.Unmarshal([]byte(testEvmLog), &l); err != nil {
+		t.Fatalf("failed to parse log: %s", err)
+	}
+	if l.BlockNumber != 20000000 || l.TxIndex != 42 || l.LogIndex != 7 || len(l.Topics) != 3 {
+		t.Errorf("bad parsed log %+v", l)
+	}
+
+	res := must(outscript.EvmDecodeLog("Transfer(address indexed,address indexed,uint256)", l.Topics, l.Data))
+	if s := fmt.Sprintf("%v", res); s != "[eth:5fb84129ad9e7818f099966de975ff41213f028d eth:dac17f958d2ee523a2206206994597c13d831ec7 1000000]" {
+		t.Errorf("bad decoded log %s", s)
+	}
+	if _, err := outscript.EvmDecodeLog("Transfer(address indexed,address,uint256)", l.Topics, l.Data); err == nil {
+		t.Errorf("expected error for wrong number of indexed parameters")
+	}
+	if _, err := outscript.EvmDecodeLog("Approval(address indexed,address indexed,uint256)", l.Topics, l.Data); err == nil {
+		t.Errorf("expected error for wrong event")
+	}
+
+	// same log, using the JSON ABI
+	abi := must(outscript.ParseEvmAbi([]byte(testEvmAbi)))
+	e, res, err := abi.DecodeLog(l.Topics, l.Data)
+	if err != nil || e.Name != "Transfer" || len(res) != 3 {
+		t.Errorf("bad decoded abi log %v: %s", res, err)
+	}
+
+	// indexed strings are hashed
+	topics := [][]byte{must(outscript.EvmEventTopic("Named(string,uint256)")), must(hex.DecodeString("1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8"))}
+	res = must(outscript.EvmDecodeLog("Named(string indexed,uint256)", topics, l.Data))
+	if hex.EncodeToString(res[0].([]byte)) != hex.EncodeToString(topics[1]) || fmt.Sprintf("%v", res[1]) != "1000000" {
+		t.Errorf("bad decoded log with indexed string %v", res)
+	}
+}
+
+func TestEvmLogTopics(t *testing.T) {
+	from := must(outscript.ParseEvmAddress("0x5Fb84129AD9E7818F099966de975ff41213F028d"))
+	to := must(outscript.ParseEvmAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7"))
+
+	for _, v := range []struct {
+		args []any
+		exp  string
+	}{
+		{nil, `["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"]`},
+		{[]any{nil, to}, `["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",null,"0x000000000000000000000000dac17f958d2ee523a2206206994597c13d831ec7"]`},
+		{[]any{[]any{from, to}, nil}, `["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef",["0x0000000000000000000000005fb84129ad9e7818f099966de975ff41213f028d","0x000000000000000000000000dac17f958d2ee523a2206206994597c13d831ec7"]]`},
+	} {
+		topics := must(outscript.EvmLogTopics("Transfer(address indexed,address indexed,uint256)", v.args...))
+		if s := string(must(json.Marshal(topics))); s != v.exp {
+			t.Errorf("bad topics for %v: %s", v.args, s)
+		}
+	}
+
+	topics := must(outscript.EvmLogTopics("Named(string indexed,uint256)", "hello"))
+	if topics[1] != "0x1c8aff950685c2ed4bc3174f3472287b56d9517b9c948127319a09a7a36deac8" {
+		t.Errorf("bad topic for indexed string %v", topics[1])
+	}
+
+	abi := must(outscript.ParseEvmAbi([]byte(testEvmAbi)))
+	if topics := must(abi.LogTopics("Transfer", from)); len(topics) != 2 || topics[1] != "0x0000000000000000000000005fb84129ad9e7818f099966de975ff41213f028d" {
+		t.Errorf("bad abi topics %v", topics)
+	}
+	if _, err := outscript.EvmLogTopics("Transfer(address indexed,address indexed,uint256)", from, to, 1); err == nil {
+		t.Errorf("expected error for non indexed parameter")
+	}
+}
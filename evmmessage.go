@@ -0,0 +1,101 @@
+package outscript
+
+import (
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"slices"
+	"strconv"
+
+	"github.com/BottleFmt/gobottle"
+	"github.com/KarpelesLab/secp256k1"
+	"golang.org/x/crypto/sha3"
+)
+
+// evmSignHash signs hash with key and returns the signature with its recovery code set
+func evmSignHash(key crypto.Signer, hash []byte, opts crypto.SignerOpts) (*secp256k1.Signature, error) {
+	pub, ok := key.Public().(*secp256k1.PublicKey)
+	if !ok {
+		return nil, errors.New("EVM signatures require a secp256k1 key")
+	}
+	sig, err := key.Sign(rand.Reader, hash, opts)
+	if err != nil {
+		return nil, err
+	}
+	// expect sig to be in DER format
+	sigO, err := secp256k1.ParseDERSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	// find recovery bit
+	if !sigO.BruteforceRecoveryCode(hash, pub) {
+		return nil, errors.New("unable to find signature recovery code")
+	}
+	return sigO, nil
+}
+
+// EvmMessageHash returns the EIP-191 hash of message as signed by personal_sign.
+func EvmMessageHash(message []byte) []byte {
+	prefix := "\x19Ethereum Signed Message:\n" + strconv.Itoa(len(message))
+	return gobottle.Hash(slices.Concat([]byte(prefix), message), sha3.NewLegacyKeccak256)
+}
+
+// EvmSignHash signs a 32 bytes hash with key, returning a 65 bytes r||s||v signature where v is
+// 27 or 28.
+func EvmSignHash(key crypto.Signer, hash []byte) ([]byte, error) {
+	if len(hash) != 32 {
+		return nil, errors.New("EVM signatures require a 32 bytes hash")
+	}
+	sig, err := evmSignHash(key, hash, crypto.Hash(0))
+	if err != nil {
+		return nil, err
+	}
+	r, s, v := sig.Export()
+	buf := make([]byte, 65)
+	r.FillBytes(buf[:32])
+	s.FillBytes(buf[32:64])
+	buf[64] = 27 + v
+	return buf, nil
+}
+
+// EvmSignMessage signs message with key as done by personal_sign (EIP-191 version 0x45).
+func EvmSignMessage(key crypto.Signer, message []byte) ([]byte, error) {
+	return EvmSignHash(key, EvmMessageHash(message))
+}
+
+// EvmRecoverHash returns the EIP-55 address of the key that produced the 65 bytes r||s||v
+// signature of hash. v can be either 0/1 or 27/28.
+func EvmRecoverHash(hash, sig []byte) (string, error) {
+	if len(sig) != 65 {
+		return "", errors.New("EVM signatures must be 65 bytes long")
+	}
+	r := new(secp256k1.ModNScalar)
+	if overflow := r.SetByteSlice(sig[:32]); overflow {
+		return "", errors.New("cannot read signature: invalid value for R >= group order")
+	}
+	s := new(secp256k1.ModNScalar)
+	if overflow := s.SetByteSlice(sig[32:64]); overflow {
+		return "", errors.New("cannot read signature: invalid value for S >= group order")
+	}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return "", errors.New("invalid signature recovery value")
+	}
+	pub, err := secp256k1.NewSignatureWithRecoveryCode(r, s, v).RecoverPublicKey(hash)
+	if err != nil {
+		return "", err
+	}
+	addr, err := New(pub).Generate("eth")
+	if err != nil {
+		return "", err
+	}
+	return eip55(addr), nil
+}
+
+// EvmRecoverMessage returns the EIP-55 address of the signer of a personal_sign message.
+func EvmRecoverMessage(message, sig []byte) (string, error) {
+	return EvmRecoverHash(EvmMessageHash(message), sig)
+}
@@ -0,0 +1,61 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestEvmSignMessage(t *testing.T) {
+	// web3.eth.accounts.sign example
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318")))
+	msg := []byte("Some data")
+
+	if h := hex.EncodeToString(outscript.EvmMessageHash(msg)); h != "1da44b586eb0729ff70a73c326926f6ed5a25f5b056e7f47fbc6e58d86871655" {
+		t.Errorf("bad message hash %s", h)
+	}
+	sig := must(outscript.EvmSignMessage(key, msg))
+	if hex.EncodeToString(sig) != "b91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c" {
+		t.Errorf("bad signature %x", sig)
+	}
+	if addr := must(outscript.EvmRecoverMessage(msg, sig)); addr != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("bad recovered address %s", addr)
+	}
+
+	// v can also be 0 or 1
+	sig[64] -= 27
+	if addr := must(outscript.EvmRecoverMessage(msg, sig)); addr != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("bad recovered address %s", addr)
+	}
+	if addr, err := outscript.EvmRecoverMessage([]byte("Other data"), sig); err == nil && addr == "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("recovered signer of another message")
+	}
+	sig[64] = 2
+	if _, err := outscript.EvmRecoverMessage(msg, sig); err == nil {
+		t.Errorf("expected error for invalid v")
+	}
+	if _, err := outscript.EvmRecoverMessage(msg, sig[:64]); err == nil {
+		t.Errorf("expected error for short signature")
+	}
+
+	// transactions are signed the same way
+	tx := &outscript.EvmTx{
+		Type:      outscript.EvmTxEIP1559,
+		ChainId:   1,
+		Nonce:     1,
+		GasTipCap: big.NewInt(1000000000),
+		GasFeeCap: big.NewInt(30000000000),
+		Gas:       21000,
+		To:        "0x5fb84129ad9e7818f099966de975ff41213f028d",
+		Value:     big.NewInt(1000),
+	}
+	if err := tx.Sign(key); err != nil {
+		t.Fatalf("Sign failed: %s", err)
+	}
+	if addr := must(tx.SenderAddress()); addr != "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23" {
+		t.Errorf("bad transaction sender %s", addr)
+	}
+}
@@ -2,7 +2,6 @@ package outscript
 
 import (
 	"crypto"
-	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -26,6 +25,8 @@ import (
 // EIP-2930 = 0x01 || rlp([chainId, nonce, gasPrice, gasLimit, to, value, data, accessList, signatureYParity, signatureR, signatureS])
 // EIP-1559 = 0x02 || rlp([chain_id, nonce, max_priority_fee_per_gas, max_fee_per_gas, gas_limit, destination, amount, data, access_list, signature_y_parity, signature_r, signature_s])
 // EIP-4844 = 0x03 || [chain_id, nonce, max_priority_fee_per_gas, max_fee_per_gas, gas_limit, to, value, data, access_list, max_fee_per_blob_gas, blob_versioned_hashes, y_parity, r, s]
+// EIP-4844 network form = 0x03 || rlp([tx_payload_body, blobs, commitments, proofs]), with wrapper_version after tx_payload_body since EIP-7594
+// EIP-7702 = 0x04 || rlp([chain_id, nonce, max_priority_fee_per_gas, max_fee_per_gas, gas_limit, destination, value, data, access_list, authorization_list, signature_y_parity, signature_r, signature_s])
 // however, EIP-2930 is so rare we can probably forget about it
 
 // EvmTxType represents the type of EVM transaction encoding.
@@ -36,24 +37,83 @@ const (
 	EvmTxEIP2930                  // EIP-2930 access list transaction
 	EvmTxEIP1559                  // EIP-1559 dynamic fee transaction
 	EvmTxEIP4844                  // EIP-4844 blob transaction
+	EvmTxEIP7702                  // EIP-7702 set code transaction
 )
 
 // EvmTx represents an Ethereum Virtual Machine transaction. It supports legacy,
-// EIP-2930, EIP-1559, and EIP-4844 transaction types, and can be signed, serialized,
+// EIP-2930, EIP-1559, EIP-4844 and EIP-7702 transaction types, and can be signed, serialized,
 // parsed, and converted to/from JSON.
 type EvmTx struct {
 	Nonce      uint64
 	GasTipCap  *big.Int // a.k.a. maxPriorityFeePerGas
 	GasFeeCap  *big.Int // a.k.a. maxFeePerGas, correspond to GasFee if tx type is legacy or eip2930
 	Gas        uint64   // gas of tx, can be obtained with eth_estimateGas, 21000 if Data is empty
-	To         string
+	To         string   // empty for contract creation
 	Value      *big.Int
 	Data       []byte
-	ChainId    uint64    // in legacy tx, chainId is encoded in v before signature
-	Type       EvmTxType // type of transaction: legacy, eip2930 or eip1559
-	AccessList []any     // TODO
+	ChainId    uint64        // in legacy tx, chainId is encoded in v before signature
+	Type       EvmTxType     // type of transaction: legacy, eip2930 or eip1559
+	AccessList EvmAccessList // EIP-2930 access list, not available in legacy transactions
 	Signed     bool
 	Y, R, S    *big.Int
+
+	MaxFeePerBlobGas    *big.Int        // EIP-4844 only
+	BlobVersionedHashes [][]byte        // EIP-4844 only, see [EvmBlobVersionedHash]
+	Sidecar             *EvmBlobSidecar // EIP-4844 blobs, only included in the network form
+
+	AuthorizationList []*EvmAuthorization // EIP-7702 only
+}
+
+// EvmAccessTuple is an entry of an EIP-2930 access list, listing the storage keys of a contract
+// accessed by the transaction. Values are 0x-prefixed hex strings, storage keys are 32 bytes long.
+type EvmAccessTuple struct {
+	Address     string   `json:"address"`
+	StorageKeys []string `json:"storageKeys"`
+}
+
+// EvmAccessList is an EIP-2930 access list, which makes the listed accesses cheaper.
+type EvmAccessList []*EvmAccessTuple
+
+// rlpValue returns the access list as rlp fields
+func (l EvmAccessList) rlpValue() []any {
+	res := make([]any, len(l))
+	for n, t := range l {
+		keys := make([]any, len(t.StorageKeys))
+		for i, k := range t.StorageKeys {
+			keys[i] = k
+		}
+		res[n] = []any{t.Address, keys}
+	}
+	return res
+}
+
+// parseEvmAccessList decodes an rlp encoded access list
+func parseEvmAccessList(v any) (EvmAccessList, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, errors.New("invalid access list in transaction")
+	}
+	res := make(EvmAccessList, len(list))
+	for n, item := range list {
+		t, ok := item.([]any)
+		if !ok || len(t) != 2 {
+			return nil, errors.New("invalid access list entry in transaction")
+		}
+		addr, ok := t[0].([]byte)
+		keys, ok2 := t[1].([]any)
+		if !ok || !ok2 || len(addr) != 20 {
+			return nil, errors.New("invalid access list entry in transaction")
+		}
+		res[n] = &EvmAccessTuple{Address: "0x" + hex.EncodeToString(addr), StorageKeys: make([]string, len(keys))}
+		for i, k := range keys {
+			key, ok := k.([]byte)
+			if !ok || len(key) != 32 {
+				return nil, errors.New("invalid access list storage key in transaction")
+			}
+			res[n].StorageKeys[i] = "0x" + hex.EncodeToString(key)
+		}
+	}
+	return res, nil
 }
 
 // evmTxJson is used when encoding/decoding evmTx into json
@@ -69,34 +129,54 @@ type evmTxJson struct {
 	To        string `json:"to,omitempty"`
 	Value     string `json:"value"`
 	ChainId   string `json:"chainId"`
-	V         string `json:"v"`
-	R         string `json:"r"`
-	S         string `json:"s"`
+	Type      string `json:"type,omitempty"`
+	// AccessList is a pointer so an empty list is kept when encoding typed transactions
+	AccessList *EvmAccessList `json:"accessList,omitempty"`
+	V          string         `json:"v"`
+	R          string         `json:"r"`
+	S          string         `json:"s"`
+
+	// EIP-4844
+	MaxFeePerBlobGas    string   `json:"maxFeePerBlobGas,omitempty"`
+	BlobVersionedHashes []string `json:"blobVersionedHashes,omitempty"`
+
+	// EIP-7702
+	AuthorizationList []*EvmAuthorization `json:"authorizationList,omitempty"`
 }
 
-// RlpFields returns the Rlp fields for the given transaction, less the signature fields
+// RlpFields returns the Rlp fields for the given transaction, less the signature fields. It returns
+// nil if the transaction cannot be encoded, the error being returned by [EvmTx.SignBytes].
 func (tx *EvmTx) RlpFields() []any {
+	res, _ := tx.rlpFields()
+	return res
+}
+
+func (tx *EvmTx) rlpFields() ([]any, error) {
+	if (tx.Type == EvmTxEIP4844 || tx.Type == EvmTxEIP7702) && tx.IsContractCreation() {
+		// EIP-4844 and EIP-7702 require a 20 bytes destination
+		return nil, errors.New("blob and set code transactions cannot create contracts")
+	}
 	switch tx.Type {
 	case EvmTxLegacy:
 		return []any{
 			tx.Nonce,
 			tx.GasFeeCap,
 			tx.Gas,
-			tx.To,
+			tx.rlpTo(),
 			tx.Value,
 			tx.Data,
-		}
+		}, nil
 	case EvmTxEIP2930:
 		return []any{
 			tx.ChainId,
 			tx.Nonce,
 			tx.GasFeeCap,
 			tx.Gas,
-			tx.To,
+			tx.rlpTo(),
 			tx.Value,
 			tx.Data,
-			[]any{},
-		}
+			tx.AccessList.rlpValue(),
+		}, nil
 	case EvmTxEIP1559:
 		return []any{
 			tx.ChainId,
@@ -104,13 +184,47 @@ func (tx *EvmTx) RlpFields() []any {
 			tx.GasTipCap,
 			tx.GasFeeCap,
 			tx.Gas,
-			tx.To,
+			tx.rlpTo(),
 			tx.Value,
 			tx.Data,
-			[]any{},
+			tx.AccessList.rlpValue(),
+		}, nil
+	case EvmTxEIP4844:
+		return []any{
+			tx.ChainId,
+			tx.Nonce,
+			tx.GasTipCap,
+			tx.GasFeeCap,
+			tx.Gas,
+			tx.rlpTo(),
+			tx.Value,
+			tx.Data,
+			tx.AccessList.rlpValue(),
+			tx.MaxFeePerBlobGas,
+			evmRlpList(tx.BlobVersionedHashes),
+		}, nil
+	case EvmTxEIP7702:
+		auths := make([]any, len(tx.AuthorizationList))
+		for n, a := range tx.AuthorizationList {
+			var err error
+			if auths[n], err = a.rlpValue(); err != nil {
+				return nil, err
+			}
 		}
+		return []any{
+			tx.ChainId,
+			tx.Nonce,
+			tx.GasTipCap,
+			tx.GasFeeCap,
+			tx.Gas,
+			tx.rlpTo(),
+			tx.Value,
+			tx.Data,
+			tx.AccessList.rlpValue(),
+			auths,
+		}, nil
 	default:
-		return nil
+		return nil, errors.New("unsupported transaction type")
 	}
 }
 
@@ -124,25 +238,36 @@ func (tx *EvmTx) typeValue() byte {
 		return 2
 	case EvmTxEIP4844:
 		return 3
+	case EvmTxEIP7702:
+		return 4
 	default:
 		return 0xff // :(
 	}
 }
 
-// MarshalBinary transforms the transaction into its binary representation
+// MarshalBinary transforms the transaction into its binary representation. Signed blob
+// transactions with a Sidecar are encoded in their network form, including the blobs.
 func (tx *EvmTx) MarshalBinary() ([]byte, error) {
+	return tx.marshalBinary(true)
+}
+
+func (tx *EvmTx) marshalBinary(sidecar bool) ([]byte, error) {
 	if !tx.Signed {
 		return tx.SignBytes()
 	}
 
+	f, err := tx.rlpFields()
+	if err != nil {
+		return nil, err
+	}
+	f = append(f, tx.Y, tx.R, tx.S)
 	switch tx.Type {
 	case EvmTxLegacy:
-		f := tx.RlpFields()
-		f = append(f, tx.Y, tx.R, tx.S)
 		return rlp.EncodeValue(f)
 	default:
-		f := tx.RlpFields()
-		f = append(f, tx.Y, tx.R, tx.S)
+		if sidecar && tx.Type == EvmTxEIP4844 && tx.Sidecar != nil {
+			f = tx.Sidecar.rlpValue(f)
+		}
 		buf, err := rlp.EncodeValue(f)
 		if err != nil {
 			return nil, err
@@ -153,16 +278,19 @@ func (tx *EvmTx) MarshalBinary() ([]byte, error) {
 
 // SignBytes returns the bytes used to sign the transaction
 func (tx *EvmTx) SignBytes() ([]byte, error) {
+	f, err := tx.rlpFields()
+	if err != nil {
+		return nil, err
+	}
 	switch tx.Type {
 	case EvmTxLegacy:
-		f := tx.RlpFields()
 		if tx.ChainId != 0 {
 			// if ChainId == 0, we assume no EIP-155
 			f = append(f, tx.ChainId, 0, 0)
 		}
 		return rlp.EncodeValue(f)
 	default:
-		buf, err := rlp.EncodeValue(tx.RlpFields())
+		buf, err := rlp.EncodeValue(f)
 		if err != nil {
 			return nil, err
 		}
@@ -202,7 +330,7 @@ func (tx *EvmTx) ParseTransaction(buf []byte) error {
 		tx.Nonce = rlp.DecodeUint64(txData[0])
 		tx.GasFeeCap = new(big.Int).SetBytes(txData[1])
 		tx.Gas = rlp.DecodeUint64(txData[2])
-		tx.To = "0x" + hex.EncodeToString(txData[3])
+		tx.To = parseEvmTo(txData[3])
 		tx.Value = new(big.Int).SetBytes(txData[4])
 		tx.Data = txData[5]
 		if ln == 9 {
@@ -235,10 +363,13 @@ func (tx *EvmTx) ParseTransaction(buf []byte) error {
 		tx.Nonce = rlp.DecodeUint64(txData[1].([]byte))
 		tx.GasFeeCap = new(big.Int).SetBytes(txData[2].([]byte))
 		tx.Gas = rlp.DecodeUint64(txData[3].([]byte))
-		tx.To = "0x" + hex.EncodeToString(txData[4].([]byte))
+		tx.To = parseEvmTo(txData[4].([]byte))
 		tx.Value = new(big.Int).SetBytes(txData[5].([]byte))
 		tx.Data = txData[6].([]byte)
-		tx.AccessList = txData[7].([]any) // TODO
+		tx.AccessList, err = parseEvmAccessList(txData[7])
+		if err != nil {
+			return err
+		}
 		if ln == 11 {
 			tx.Signed = true
 			tx.Y = new(big.Int).SetBytes(txData[8].([]byte))
@@ -267,10 +398,13 @@ func (tx *EvmTx) ParseTransaction(buf []byte) error {
 		tx.GasTipCap = new(big.Int).SetBytes(txData[2].([]byte))
 		tx.GasFeeCap = new(big.Int).SetBytes(txData[3].([]byte))
 		tx.Gas = rlp.DecodeUint64(txData[4].([]byte))
-		tx.To = "0x" + hex.EncodeToString(txData[5].([]byte))
+		tx.To = parseEvmTo(txData[5].([]byte))
 		tx.Value = new(big.Int).SetBytes(txData[6].([]byte))
 		tx.Data = txData[7].([]byte)
-		tx.AccessList = txData[8].([]any) // TODO
+		tx.AccessList, err = parseEvmAccessList(txData[8])
+		if err != nil {
+			return err
+		}
 		if ln == 12 {
 			tx.Signed = true
 			tx.Y = new(big.Int).SetBytes(txData[9].([]byte))
@@ -280,6 +414,102 @@ func (tx *EvmTx) ParseTransaction(buf []byte) error {
 			tx.Signed = false
 		}
 		return nil
+	case 3: // EvmTxEIP4844
+		dec, err := rlp.Decode(buf[1:])
+		if err != nil {
+			return err
+		}
+		if len(dec) != 1 {
+			return errors.New("invalid rlp data for EIP-4844 transaction")
+		}
+		txData := dec[0].([]any)
+		tx.Sidecar = nil
+		if len(txData) > 0 {
+			if body, ok := txData[0].([]any); ok {
+				// network form, including the blobs
+				tx.Sidecar, err = parseEvmBlobSidecar(txData[1:])
+				if err != nil {
+					return err
+				}
+				txData = body
+			}
+		}
+		ln := len(txData)
+		if ln != 11 && ln != 14 {
+			return fmt.Errorf("EIP-4844 transaction must have 11 or 14 fields, got %d", ln)
+		}
+		tx.Type = EvmTxEIP4844
+		tx.ChainId = rlp.DecodeUint64(txData[0].([]byte))
+		tx.Nonce = rlp.DecodeUint64(txData[1].([]byte))
+		tx.GasTipCap = new(big.Int).SetBytes(txData[2].([]byte))
+		tx.GasFeeCap = new(big.Int).SetBytes(txData[3].([]byte))
+		tx.Gas = rlp.DecodeUint64(txData[4].([]byte))
+		tx.To = parseEvmTo(txData[5].([]byte))
+		if tx.To == "" {
+			return errors.New("EIP-4844 transaction cannot create contracts")
+		}
+		tx.Value = new(big.Int).SetBytes(txData[6].([]byte))
+		tx.Data = txData[7].([]byte)
+		tx.AccessList, err = parseEvmAccessList(txData[8])
+		if err != nil {
+			return err
+		}
+		tx.MaxFeePerBlobGas = new(big.Int).SetBytes(txData[9].([]byte))
+		tx.BlobVersionedHashes, err = parseEvmRlpList(txData[10], 32)
+		if err != nil {
+			return err
+		}
+		if ln == 14 {
+			tx.Signed = true
+			tx.Y = new(big.Int).SetBytes(txData[11].([]byte))
+			tx.R = new(big.Int).SetBytes(txData[12].([]byte))
+			tx.S = new(big.Int).SetBytes(txData[13].([]byte))
+		} else {
+			tx.Signed = false
+		}
+		return nil
+	case 4: // EvmTxEIP7702
+		dec, err := rlp.Decode(buf[1:])
+		if err != nil {
+			return err
+		}
+		if len(dec) != 1 {
+			return errors.New("invalid rlp data for EIP-7702 transaction")
+		}
+		txData := dec[0].([]any)
+		ln := len(txData)
+		if ln != 10 && ln != 13 {
+			return fmt.Errorf("EIP-7702 transaction must have 10 or 13 fields, got %d", ln)
+		}
+		tx.Type = EvmTxEIP7702
+		tx.ChainId = rlp.DecodeUint64(txData[0].([]byte))
+		tx.Nonce = rlp.DecodeUint64(txData[1].([]byte))
+		tx.GasTipCap = new(big.Int).SetBytes(txData[2].([]byte))
+		tx.GasFeeCap = new(big.Int).SetBytes(txData[3].([]byte))
+		tx.Gas = rlp.DecodeUint64(txData[4].([]byte))
+		tx.To = parseEvmTo(txData[5].([]byte))
+		if tx.To == "" {
+			return errors.New("EIP-7702 transaction cannot create contracts")
+		}
+		tx.Value = new(big.Int).SetBytes(txData[6].([]byte))
+		tx.Data = txData[7].([]byte)
+		tx.AccessList, err = parseEvmAccessList(txData[8])
+		if err != nil {
+			return err
+		}
+		tx.AuthorizationList, err = parseEvmAuthorizationList(txData[9])
+		if err != nil {
+			return err
+		}
+		if ln == 13 {
+			tx.Signed = true
+			tx.Y = new(big.Int).SetBytes(txData[10].([]byte))
+			tx.R = new(big.Int).SetBytes(txData[11].([]byte))
+			tx.S = new(big.Int).SetBytes(txData[12].([]byte))
+		} else {
+			tx.Signed = false
+		}
+		return nil
 	}
 
 	return errors.New("not supported")
@@ -359,18 +589,10 @@ func (tx *EvmTx) SignWithOptions(key crypto.Signer, opts crypto.SignerOpts) erro
 	if err != nil {
 		return err
 	}
-	h := gobottle.Hash(buf, sha3.NewLegacyKeccak256)
-	sig, err := key.Sign(rand.Reader, h, opts)
+	sigO, err := evmSignHash(key, gobottle.Hash(buf, sha3.NewLegacyKeccak256), opts)
 	if err != nil {
 		return err
 	}
-	// expect sig to be in DER format
-	sigO, err := secp256k1.ParseDERSignature(sig)
-	if err != nil {
-		return err
-	}
-	// find recovery bit
-	sigO.BruteforceRecoveryCode(h, key.Public().(*secp256k1.PublicKey))
 	// apply signature
 	tx.Signed = true
 	var v byte
@@ -389,9 +611,10 @@ func (tx *EvmTx) SignWithOptions(key crypto.Signer, opts crypto.SignerOpts) erro
 	return nil
 }
 
-// Hash returns the Keccak-256 hash of the signed transaction's binary encoding.
+// Hash returns the Keccak-256 hash of the signed transaction's binary encoding, which does not
+// include the blobs of blob transactions.
 func (tx *EvmTx) Hash() ([]byte, error) {
-	data, err := tx.MarshalBinary()
+	data, err := tx.marshalBinary(false)
 	if err != nil {
 		return nil, err
 	}
@@ -404,17 +627,44 @@ func (tx *EvmTx) MarshalJSON() ([]byte, error) {
 		Gas:     "0x" + strconv.FormatUint(tx.Gas, 16),
 		Input:   "0x" + hex.EncodeToString(tx.Data),
 		Nonce:   "0x" + strconv.FormatUint(tx.Nonce, 16),
-		To:      tx.To,
 		Value:   "0x" + tx.Value.Text(16),
 		ChainId: "0x" + strconv.FormatUint(tx.ChainId, 16),
 	}
+	if !tx.IsContractCreation() {
+		obj.To = tx.To
+	}
 
-	if tx.Type == EvmTxLegacy {
+	switch tx.Type {
+	case EvmTxLegacy:
 		obj.GasPrice = "0x" + tx.GasFeeCap.Text(16)
-	} else {
+	case EvmTxEIP2930:
+		obj.GasPrice = "0x" + tx.GasFeeCap.Text(16)
+	default:
 		obj.GasFeeCap = "0x" + tx.GasFeeCap.Text(16)
 		obj.GasTipCap = "0x" + tx.GasTipCap.Text(16)
 	}
+	if tx.Type != EvmTxLegacy {
+		obj.Type = "0x" + strconv.FormatUint(uint64(tx.typeValue()), 16)
+		accessList := tx.AccessList
+		if accessList == nil {
+			accessList = EvmAccessList{}
+		}
+		obj.AccessList = &accessList
+	}
+	if tx.Type == EvmTxEIP4844 {
+		maxFeePerBlobGas := tx.MaxFeePerBlobGas
+		if maxFeePerBlobGas == nil {
+			maxFeePerBlobGas = new(big.Int)
+		}
+		obj.MaxFeePerBlobGas = "0x" + maxFeePerBlobGas.Text(16)
+		obj.BlobVersionedHashes = make([]string, len(tx.BlobVersionedHashes))
+		for n, h := range tx.BlobVersionedHashes {
+			obj.BlobVersionedHashes[n] = "0x" + hex.EncodeToString(h)
+		}
+	}
+	if tx.Type == EvmTxEIP7702 {
+		obj.AuthorizationList = tx.AuthorizationList
+	}
 
 	if tx.Signed {
 		obj.From, _ = tx.SenderAddress()
@@ -457,6 +707,39 @@ func (tx *EvmTx) UnmarshalJSON(b []byte) error {
 			return errors.New("invalid value in gasPrice")
 		}
 	}
+	if obj.Type != "" {
+		typ, err := strconv.ParseUint(obj.Type, 0, 8)
+		if err != nil {
+			return err
+		}
+		if typ > uint64(EvmTxEIP7702) {
+			return fmt.Errorf("unsupported transaction type %s", obj.Type)
+		}
+		tx.Type = EvmTxType(typ)
+	} else if obj.AccessList != nil && tx.Type == EvmTxLegacy {
+		tx.Type = EvmTxEIP2930
+	}
+	if obj.AccessList != nil {
+		tx.AccessList = *obj.AccessList
+	}
+	if obj.MaxFeePerBlobGas != "" {
+		tx.MaxFeePerBlobGas, ok = new(big.Int).SetString(obj.MaxFeePerBlobGas, 0)
+		if !ok {
+			return errors.New("invalid value in maxFeePerBlobGas")
+		}
+	}
+	if obj.AuthorizationList != nil {
+		tx.AuthorizationList = obj.AuthorizationList
+	}
+	if obj.BlobVersionedHashes != nil {
+		tx.BlobVersionedHashes = make([][]byte, len(obj.BlobVersionedHashes))
+		for n, h := range obj.BlobVersionedHashes {
+			tx.BlobVersionedHashes[n], err = parseEthBufferHex(h)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	if obj.Input != "" {
 		tx.Data, err = parseEthBufferHex(obj.Input)
 		if err != nil {
@@ -502,9 +785,34 @@ func (tx *EvmTx) UnmarshalJSON(b []byte) error {
 			return errors.New("invalid value in s")
 		}
 	}
+	tx.Signed = tx.Y != nil && tx.R != nil && tx.S != nil
 	return nil
 }
 
+// IsContractCreation returns true if the transaction deploys a contract, which is the case when To
+// is empty. The address of the contract is returned by [EvmTx.ContractAddress]. Blob and set code
+// transactions cannot create contracts and fail to encode without a destination.
+func (tx *EvmTx) IsContractCreation() bool {
+	return tx.To == "" || tx.To == "0x"
+}
+
+// rlpTo returns the destination of the transaction as a rlp field, empty for contract creation
+func (tx *EvmTx) rlpTo() any {
+	if tx.IsContractCreation() {
+		return []byte{}
+	}
+	return tx.To
+}
+
+// parseEvmTo returns the destination of the transaction from its rlp field
+func parseEvmTo(buf []byte) string {
+	if len(buf) == 0 {
+		// contract creation
+		return ""
+	}
+	return "0x" + hex.EncodeToString(buf)
+}
+
 func parseEthBufferHex(buf string) ([]byte, error) {
 	if len(buf) < 2 {
 		return nil, errors.New("eth buffer must start with 0x")
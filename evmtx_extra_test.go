@@ -95,7 +95,6 @@ func TestEvmTxJSONRoundTrip(t *testing.T) {
 
 func TestEvmTxCall(t *testing.T) {
 	tx := &outscript.EvmTx{}
-	// Use a function with uint256 params only since AppendAddressAny is not yet implemented
 	err := tx.Call("approve(uint256,uint256)", big.NewInt(100), big.NewInt(200))
 	if err != nil {
 		t.Fatalf("Call failed: %s", err)
@@ -174,3 +173,70 @@ func TestEvmTxEIP1559Sign(t *testing.T) {
 		t.Errorf("expected EIP1559 type after round-trip")
 	}
 }
+
+func TestEvmTxAccessList(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	accessList := outscript.EvmAccessList{
+		{Address: "0xdac17f958d2ee523a2206206994597c13d831ec7", StorageKeys: []string{
+			"0x0000000000000000000000000000000000000000000000000000000000000001",
+			"0xabababababababababababababababababababababababababababababababab",
+		}},
+		{Address: "0x2aeb8add8337360e088b7d9ce4e857b9be60f3a7", StorageKeys: []string{}},
+	}
+
+	for _, v := range []struct {
+		tx  *outscript.EvmTx
+		exp string
+	}{
+		{
+			&outscript.EvmTx{Type: outscript.EvmTxEIP2930, ChainId: 1, Nonce: 7, GasFeeCap: big.NewInt(30000000000), Gas: 50000},
+			"01f8e501078506fc23ac0082c350945fb84129ad9e7818f099966de975ff41213f028d880de0b6b3a764000084a9059cbbf872f85994dac17f958d2ee523a2206206994597c13d831ec7f842a00000000000000000000000000000000000000000000000000000000000000001a0ababababababababababababababababababababababababababababababababd6942aeb8add8337360e088b7d9ce4e857b9be60f3a7c001a07cf97de40859a9d94cf947fe66d30ec2b0db70c8da13bb4e6ffcaf96458233cba00857de965a061eeaf9f571f455bb8df80c112d46d326d15820da4b9173d6bef3",
+		},
+		{
+			&outscript.EvmTx{Type: outscript.EvmTxEIP1559, ChainId: 1, Nonce: 7, GasTipCap: big.NewInt(1000000000), GasFeeCap: big.NewInt(30000000000), Gas: 50000},
+			"02f8ea0107843b9aca008506fc23ac0082c350945fb84129ad9e7818f099966de975ff41213f028d880de0b6b3a764000084a9059cbbf872f85994dac17f958d2ee523a2206206994597c13d831ec7f842a00000000000000000000000000000000000000000000000000000000000000001a0ababababababababababababababababababababababababababababababababd6942aeb8add8337360e088b7d9ce4e857b9be60f3a7c001a0e041c8eff1c29a4ea4b4289e7647629195241dc16db3b30c03346eb626c03284a0032cb80730e2cd89b13c6bc8a955ed8133d4a327007bc87a903aac6d9c18fdd7",
+		},
+	} {
+		tx := v.tx
+		tx.To = "0x5fb84129ad9e7818f099966de975ff41213f028d"
+		tx.Value = big.NewInt(1000000000000000000)
+		tx.Data = must(hex.DecodeString("a9059cbb"))
+		tx.AccessList = accessList
+		if err := tx.Sign(key); err != nil {
+			t.Fatalf("Sign failed: %s", err)
+		}
+		if res := hex.EncodeToString(must(tx.MarshalBinary())); res != v.exp {
+			t.Errorf("bad signed tx of type %d: %s", tx.Type, res)
+		}
+
+		var tx2 outscript.EvmTx
+		if err := tx2.UnmarshalBinary(must(hex.DecodeString(v.exp))); err != nil {
+			t.Fatalf("UnmarshalBinary failed: %s", err)
+		}
+		if tx2.Type != tx.Type || len(tx2.AccessList) != 2 || tx2.AccessList[0].StorageKeys[1] != accessList[0].StorageKeys[1] || len(tx2.AccessList[1].StorageKeys) != 0 {
+			t.Errorf("bad parsed access list %+v", tx2.AccessList)
+		}
+		if must(tx2.SenderAddress()) != "0x2AeB8ADD8337360E088B7D9ce4e857b9BE60f3a7" {
+			t.Errorf("unexpected sender: %s", must(tx2.SenderAddress()))
+		}
+
+		// JSON uses the standard accessList shape
+		jsonData := must(json.Marshal(tx))
+		if !bytes.Contains(jsonData, []byte(`"accessList":[{"address":"0xdac17f958d2ee523a2206206994597c13d831ec7","storageKeys":["0x0000000000000000000000000000000000000000000000000000000000000001"`)) {
+			t.Errorf("bad access list in json %s", jsonData)
+		}
+		var tx3 outscript.EvmTx
+		if err := json.Unmarshal(jsonData, &tx3); err != nil {
+			t.Fatalf("UnmarshalJSON failed: %s", err)
+		}
+		if res := hex.EncodeToString(must(tx3.MarshalBinary())); res != v.exp {
+			t.Errorf("bad tx after json round-trip: %s", res)
+		}
+	}
+
+	// typed transactions always include the access list in json
+	tx := &outscript.EvmTx{Type: outscript.EvmTxEIP1559, GasTipCap: big.NewInt(1), GasFeeCap: big.NewInt(1), Value: big.NewInt(0)}
+	if jsonData := must(json.Marshal(tx)); !bytes.Contains(jsonData, []byte(`"type":"0x2","accessList":[]`)) {
+		t.Errorf("bad json %s", jsonData)
+	}
+}
@@ -0,0 +1,8 @@
+package outscript
+
+// LegacySigHash and WitnessSigHash expose the signature hash algorithms to the tests, so they
+// can be checked against external test vectors.
+var (
+	LegacySigHash  = (*BtcTx).legacySigHash
+	WitnessSigHash = (*BtcTx).witnessSigHash
+)
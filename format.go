@@ -29,6 +29,8 @@ var (
 		"p2wsh:p2pk":   Format{Bytes{0}, IPushBytes{IHash(Lookup("p2pk"), sha256.New)}},
 		"p2wsh:p2puk":  Format{Bytes{0}, IPushBytes{IHash(Lookup("p2puk"), sha256.New)}},
 		"p2wsh:p2wpkh": Format{Bytes{0}, IPushBytes{IHash(Lookup("p2wpkh"), sha256.New)}},
+		// taproot key-path only output (BIP-86)
+		"p2tr": Format{Bytes{0x51}, IPushBytes{ITaprootTweak(Lookup("pubkey:xonly"))}},
 		// ethereum format
 		"eth": Format{IHash(Lookup("pubkey:uncomp"), newEtherHash)},
 		// massa keys are blake3 encoded
@@ -40,7 +42,7 @@ var (
 
 	// FormatsPerNetwork is a table listing the typically available formats for each network
 	FormatsPerNetwork = map[string][]string{
-		"bitcoin":      []string{"p2wpkh", "p2sh:p2wpkh", "p2puk", "p2pk", "p2pukh", "p2pkh"},
+		"bitcoin":      []string{"p2tr", "p2wpkh", "p2sh:p2wpkh", "p2puk", "p2pk", "p2pukh", "p2pkh"},
 		"bitcoin-cash": []string{"p2puk", "p2pk", "p2pukh", "p2pkh"},
 		"litecoin":     []string{"p2wpkh", "p2sh:p2wpkh", "p2puk", "p2pk", "p2pukh", "p2pkh"},
 		"dogecoin":     []string{"p2puk", "p2pk", "p2pukh", "p2pkh"},
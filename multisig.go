@@ -0,0 +1,230 @@
+package outscript
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/BottleFmt/gobottle"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Multisig is a m-of-n OP_CHECKMULTISIG script, used as redeem script or witness script in
+// p2sh, p2wsh and p2sh:p2wsh outputs.
+type Multisig struct {
+	M       int      // number of signatures required
+	PubKeys [][]byte // serialized public keys, in script order
+}
+
+// NewMultisig returns a m-of-n [Multisig] for the given keys, sorted as per BIP-67 so that
+// the resulting script does not depend on the order of the keys.
+func NewMultisig(m int, keys ...crypto.PublicKey) (*Multisig, error) {
+	ms, err := NewMultisigUnsorted(m, keys...)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(ms.PubKeys, bytes.Compare)
+	return ms, nil
+}
+
+// NewMultisigUnsorted returns a m-of-n [Multisig] for the given keys, keeping their order.
+func NewMultisigUnsorted(m int, keys ...crypto.PublicKey) (*Multisig, error) {
+	ms := &Multisig{M: m}
+	for _, k := range keys {
+		buf, err := New(k).Generate("pubkey:comp")
+		if err != nil {
+			return nil, err
+		}
+		ms.PubKeys = append(ms.PubKeys, buf)
+	}
+	if err := ms.check(); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+// ParseMultisig parses a OP_CHECKMULTISIG script of the form OP_m <pubkeys...> OP_n OP_CHECKMULTISIG.
+func ParseMultisig(script []byte) (*Multisig, error) {
+	if len(script) < 3 || script[0] < 0x51 || script[0] > 0x60 || script[len(script)-1] != 0xae {
+		return nil, errors.New("not a multisig script")
+	}
+	ms := &Multisig{M: int(script[0] - 0x50)}
+	n := script[len(script)-2]
+	for v := script[1 : len(script)-2]; len(v) > 0; {
+		key, ln := ParsePushBytes(v)
+		if ln == 0 {
+			return nil, errors.New("invalid multisig script")
+		}
+		ms.PubKeys = append(ms.PubKeys, key)
+		v = v[ln:]
+	}
+	if n < 0x51 || n > 0x60 || int(n-0x50) != len(ms.PubKeys) {
+		return nil, errors.New("invalid multisig script key count")
+	}
+	if err := ms.check(); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (ms *Multisig) check() error {
+	if ms.M < 1 || ms.M > len(ms.PubKeys) || len(ms.PubKeys) > 16 {
+		return fmt.Errorf("invalid multisig %d-of-%d", ms.M, len(ms.PubKeys))
+	}
+	for _, k := range ms.PubKeys {
+		if len(k) != 33 && len(k) != 65 {
+			return errors.New("invalid multisig public key")
+		}
+	}
+	return nil
+}
+
+// Script returns the OP_CHECKMULTISIG script.
+func (ms *Multisig) Script() []byte {
+	res := []byte{byte(0x50 + ms.M)}
+	for _, k := range ms.PubKeys {
+		res = append(res, PushBytes(k)...)
+	}
+	return append(res, byte(0x50+len(ms.PubKeys)), 0xae)
+}
+
+// Out returns the [Out] paying to the multisig script using the given scheme, one of "p2sh",
+// "p2wsh" or "p2sh:p2wsh". As the p2sh redeem script is pushed when spending, it cannot exceed
+// 520 bytes, which allows up to 15 compressed keys.
+func (ms *Multisig) Out(scheme string) (*Out, error) {
+	script := ms.Script()
+	switch scheme {
+	case "p2sh":
+		if len(script) > maxScriptElementSize {
+			return nil, fmt.Errorf("multisig script of %d bytes cannot be spent in p2sh, the limit is %d bytes", len(script), maxScriptElementSize)
+		}
+		return makeOut("p2sh:multisig", p2shScript(script)), nil
+	case "p2wsh":
+		return makeOut("p2wsh:multisig", p2wshScript(script)), nil
+	case "p2sh:p2wsh":
+		return makeOut("p2sh:p2wsh:multisig", p2shScript(p2wshScript(script))), nil
+	default:
+		return nil, fmt.Errorf("unsupported multisig scheme %s", scheme)
+	}
+}
+
+// Address returns the address of the multisig script using the given scheme (see [Multisig.Out])
+// and optional network flags.
+func (ms *Multisig) Address(scheme string, flags ...string) (string, error) {
+	out, err := ms.Out(scheme)
+	if err != nil {
+		return "", err
+	}
+	return out.Address(flags...)
+}
+
+// index returns the position of pub in the multisig keys, or -1
+func (ms *Multisig) index(pub crypto.PublicKey) int {
+	s := New(pub)
+	for _, typ := range []string{"pubkey:comp", "pubkey:uncomp"} {
+		buf, err := s.Generate(typ)
+		if err != nil {
+			continue
+		}
+		if pos := slices.IndexFunc(ms.PubKeys, func(k []byte) bool { return bytes.Equal(k, buf) }); pos != -1 {
+			return pos
+		}
+	}
+	return -1
+}
+
+// addSig returns stack with sig added for the key at pos. Until the threshold is reached, the
+// stack holds one (possibly empty) slot per key so that signatures can be added in any order.
+// Once enough signatures are available, only the first M are kept. The stack starts with the
+// dummy element consumed by OP_CHECKMULTISIG and ends with the script.
+func (ms *Multisig) addSig(stack [][]byte, pos int, sig []byte) [][]byte {
+	script := ms.Script()
+	n := len(ms.PubKeys)
+	var slots [][]byte
+	switch {
+	case len(stack) == n+2 && bytes.Equal(stack[n+1], script):
+		slots = slices.Clone(stack[1 : n+1])
+	case len(stack) == ms.M+2 && bytes.Equal(stack[ms.M+1], script):
+		// already has enough signatures
+		return stack
+	default:
+		slots = make([][]byte, n)
+		for i := range slots {
+			slots[i] = []byte{}
+		}
+	}
+	slots[pos] = sig
+
+	var sigs [][]byte
+	for _, v := range slots {
+		if len(v) > 0 {
+			sigs = append(sigs, v)
+		}
+	}
+	if len(sigs) >= ms.M {
+		slots = sigs[:ms.M]
+	}
+	return slices.Concat([][]byte{{}}, slots, [][]byte{script})
+}
+
+// multisigSign signs input n for the multisig script of k, adding the signature to the ones
+// already present in the input
+func (tx *BtcTx) multisigSign(n int, k *BtcTxSign) error {
+	ms := k.Multisig
+	if ms == nil {
+		return fmt.Errorf("%s signature requires Multisig to be set", k.Scheme)
+	}
+	pos := ms.index(k.Key.Public())
+	if pos == -1 {
+		return errors.New("signing key is not part of the multisig script")
+	}
+	script := ms.Script()
+	sign, err := k.sign(tx.signHash(n, k, script, k.Scheme != "p2sh:multisig"))
+	if err != nil {
+		return err
+	}
+
+	switch k.Scheme {
+	case "p2sh:multisig":
+		stack, _ := parsePushes(tx.In[n].Script)
+		tx.In[n].Script = pushStack(ms.addSig(stack, pos, sign))
+	case "p2wsh:multisig":
+		tx.In[n].Witnesses = ms.addSig(tx.In[n].Witnesses, pos, sign)
+		tx.In[n].Script = nil
+	case "p2sh:p2wsh:multisig":
+		tx.In[n].Witnesses = ms.addSig(tx.In[n].Witnesses, pos, sign)
+		tx.In[n].Script = PushBytes(p2wshScript(script))
+	}
+	return nil
+}
+
+// p2shScript returns the p2sh output script for the given redeem script
+func p2shScript(script []byte) []byte {
+	return slices.Concat([]byte{0xa9}, PushBytes(gobottle.Hash(script, sha256.New, ripemd160.New)), []byte{0x87})
+}
+
+// p2wshScript returns the p2wsh output script for the given witness script
+func p2wshScript(script []byte) []byte {
+	h := sha256.Sum256(script)
+	return slices.Concat([]byte{0}, PushBytes(h[:]))
+}
+
+// parsePushes returns the values pushed by a script made only of push operations
+func parsePushes(script []byte) ([][]byte, bool) {
+	var res [][]byte
+	for len(script) > 0 {
+		v, n := ParsePushBytes(script)
+		if n == 0 {
+			return nil, false
+		}
+		if v == nil {
+			v = []byte{}
+		}
+		res = append(res, v)
+		script = script[n:]
+	}
+	return res, true
+}
@@ -0,0 +1,240 @@
+package outscript_test
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"slices"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestMultisigScript(t *testing.T) {
+	// BIP-67 test vector
+	pub1 := must(secp256k1.ParsePubKey(must(hex.DecodeString("02ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f8"))))
+	pub2 := must(secp256k1.ParsePubKey(must(hex.DecodeString("02fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f"))))
+
+	ms := must(outscript.NewMultisig(2, pub1, pub2))
+	script := "522102fe6f0a5a297eb38c391581c4413e084773ea23954d93f7753db7dc0adc188b2f2102ff12471208c14bd580709cb2358d98975247d8765f92bc25eab3b2763ed605f852ae"
+	if h := hex.EncodeToString(ms.Script()); h != script {
+		t.Errorf("unexpected sorted multisig script %s", h)
+	}
+	if addr := must(ms.Address("p2sh")); addr != "39bgKC7RFbpoCRbtD5KEdkYKtNyhpsNa3Z" {
+		t.Errorf("unexpected p2sh address %s", addr)
+	}
+
+	// unsorted keeps the order
+	ms2 := must(outscript.NewMultisigUnsorted(2, pub1, pub2))
+	if !bytes.Equal(ms2.PubKeys[0], must(outscript.New(pub1).Generate("pubkey:comp"))) {
+		t.Errorf("unsorted multisig changed key order")
+	}
+
+	parsed := must(outscript.ParseMultisig(must(hex.DecodeString(script))))
+	if parsed.M != 2 || len(parsed.PubKeys) != 2 || !bytes.Equal(parsed.Script(), ms.Script()) {
+		t.Errorf("failed to parse multisig script")
+	}
+
+	for scheme, prefix := range map[string]string{"p2sh": "a914", "p2wsh": "0020", "p2sh:p2wsh": "a914"} {
+		out := must(ms.Out(scheme))
+		if !bytes.HasPrefix(out.Bytes(), must(hex.DecodeString(prefix))) {
+			t.Errorf("unexpected %s output %x", scheme, out.Bytes())
+		}
+		if _, err := out.Address("bitcoin"); err != nil {
+			t.Errorf("failed to get %s address: %s", scheme, err)
+		}
+	}
+
+	// invalid values
+	if _, err := outscript.NewMultisig(3, pub1, pub2); err == nil {
+		t.Errorf("expected error for 3-of-2 multisig")
+	}
+	if _, err := outscript.ParseMultisig(must(hex.DecodeString("5221" + script[6:72] + "53ae"))); err == nil {
+		t.Errorf("expected error for bad key count")
+	}
+
+	// 16 keys exceed the p2sh redeem script limit, but not the p2wsh one
+	var keys []crypto.PublicKey
+	for i := range 16 {
+		keys = append(keys, must(outscript.NewHDKey([]byte{byte(i), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15})).PubKey())
+	}
+	if _, err := must(outscript.NewMultisig(16, keys...)).Out("p2sh"); err == nil {
+		t.Errorf("expected error for 16 keys p2sh multisig")
+	}
+	if _, err := must(outscript.NewMultisig(16, keys...)).Out("p2wsh"); err != nil {
+		t.Errorf("failed to get 16 keys p2wsh multisig: %s", err)
+	}
+	if _, err := must(outscript.NewMultisig(15, keys[:15]...)).Out("p2sh"); err != nil {
+		t.Errorf("failed to get 15 keys p2sh multisig: %s", err)
+	}
+}
+
+func TestBtcTxSignMultisig(t *testing.T) {
+	keys := []*secp256k1.PrivateKey{
+		secp256k1.PrivKeyFromBytes(must(hex.DecodeString("bbc27228ddcb9209d7fd6f36b02f7dfa6252af40bb2f1cbc7a557da8027ff866"))),
+		secp256k1.PrivKeyFromBytes(must(hex.DecodeString("619c335025c7f4012e556c2a58b2506e30b8511b53ade95ea316fd8c3286feb9"))),
+		secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf"))),
+	}
+	ms := must(outscript.NewMultisig(2, keys[0].PubKey(), keys[1].PubKey(), keys[2].PubKey()))
+	script := ms.Script()
+
+	tx := &outscript.BtcTx{Version: 2}
+	for n := range 3 {
+		tx.In = append(tx.In, &outscript.BtcTxInput{TXID: outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f"))), Vout: uint32(n), Sequence: 0xffffffff})
+	}
+	tx.Out = append(tx.Out, &outscript.BtcTxOutput{Amount: 250000, Script: must(ms.Out("p2wsh")).Bytes()})
+
+	schemes := []string{"p2sh:multisig", "p2wsh:multisig", "p2sh:p2wsh:multisig"}
+	sign := func(key *secp256k1.PrivateKey) {
+		t.Helper()
+		var k []*outscript.BtcTxSign
+		for _, scheme := range schemes {
+			k = append(k, &outscript.BtcTxSign{Key: key, Scheme: scheme, Amount: 100000, Multisig: ms})
+		}
+		if err := tx.Sign(k...); err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+	}
+	stacks := func() [][][]byte {
+		res := [][][]byte{nil, tx.In[1].Witnesses, tx.In[2].Witnesses}
+		// p2sh stack is found in the input script
+		for v := tx.In[0].Script; len(v) > 0; {
+			item, n := outscript.ParsePushBytes(v)
+			res[0] = append(res[0], item)
+			v = v[n:]
+		}
+		return res
+	}
+
+	// first signature by the last key, one slot per key is kept
+	sign(keys[2])
+	for n, st := range stacks() {
+		filled := slices.IndexFunc(st[1:4], func(v []byte) bool { return len(v) > 0 })
+		if len(st) != 5 || len(st[0]) != 0 || filled == -1 || !bytes.Equal(st[4], script) {
+			t.Fatalf("input %d: unexpected partial stack %x", n, st)
+		}
+	}
+
+	var prevOuts []*outscript.BtcTxOutput
+	for _, scheme := range []string{"p2sh", "p2wsh", "p2sh:p2wsh"} {
+		prevOuts = append(prevOuts, &outscript.BtcTxOutput{Amount: 100000, Script: must(ms.Out(scheme)).Bytes()})
+	}
+	for n := range tx.In {
+		if err := tx.VerifyInput(n, prevOuts[n]); err == nil {
+			t.Errorf("input %d: expected error verifying partially signed input", n)
+		}
+	}
+
+	// threshold reached, only the signatures remain, in key order
+	sign(keys[0])
+	complete := stacks()
+	for n, st := range complete {
+		if len(st) != 4 || len(st[0]) != 0 || len(st[1]) == 0 || len(st[2]) == 0 || !bytes.Equal(st[3], script) {
+			t.Fatalf("input %d: unexpected final stack %x", n, st)
+		}
+	}
+	if err := tx.Verify(prevOuts); err != nil {
+		t.Errorf("failed to verify multisig transaction: %s", err)
+	}
+
+	// signing again with another key does not change a complete input
+	sign(keys[1])
+	for n, st := range stacks() {
+		if !slices.EqualFunc(st, complete[n], bytes.Equal) {
+			t.Errorf("input %d: complete input was modified", n)
+		}
+	}
+	if !bytes.Equal(tx.In[2].Script, outscript.PushBytes(must(ms.Out("p2wsh")).Bytes())) {
+		t.Errorf("unexpected p2sh:p2wsh input script")
+	}
+
+	// keys not part of the multisig cannot sign
+	other := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000003")))
+	if err := tx.Sign(&outscript.BtcTxSign{Key: other, Scheme: "p2wsh:multisig", Multisig: ms}, nil, nil); err == nil {
+		t.Errorf("expected error signing with a key not in the multisig")
+	}
+}
+
+func TestBtcTxSignMultisigBip143(t *testing.T) {
+	// BIP-143 P2SH-P2WSH example: 6-of-6 multisig, the witness script is over 75 bytes
+	tx := &outscript.BtcTx{}
+	if err := tx.UnmarshalBinary(must(hex.DecodeString("010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000"))); err != nil {
+		t.Fatalf("failed to parse tx: %s", err)
+	}
+	script := must(hex.DecodeString("56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae"))
+	ms := must(outscript.ParseMultisig(script))
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("730fff80e1413068a05b57d6a58261f07551163369787f349438ea38ca80fac6")))
+
+	if err := tx.Sign(&outscript.BtcTxSign{Key: key, Scheme: "p2sh:p2wsh:multisig", Amount: 987654321, Multisig: ms}); err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if h := hex.EncodeToString(tx.In[0].Script); h != "220020a16b5755f7f6f96dbd65f5f0d6ab9418b89af4b1f14a1bb8a09062c35f0dcb54" {
+		t.Errorf("unexpected input script %s", h)
+	}
+
+	// first key of the script, SIGHASH_ALL
+	w := tx.In[0].Witnesses
+	if len(w) != 8 || len(w[1]) == 0 || w[1][len(w[1])-1] != 0x01 || !bytes.Equal(w[7], script) {
+		t.Fatalf("unexpected witness %x", w)
+	}
+	sig := must(secp256k1.ParseDERSignature(w[1][:len(w[1])-1]))
+	sighash := must(hex.DecodeString("185c0be5263dce5b4bb50a047973c1b6272bfbd0103a89444597dc40b248ee7c"))
+	if !sig.Verify(sighash, key.PubKey()) {
+		t.Errorf("signature does not match the BIP-143 sighash")
+	}
+}
+
+func TestPsbtMultisig(t *testing.T) {
+	key0 := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("bbc27228ddcb9209d7fd6f36b02f7dfa6252af40bb2f1cbc7a557da8027ff866")))
+	key1 := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("619c335025c7f4012e556c2a58b2506e30b8511b53ade95ea316fd8c3286feb9")))
+	ms := must(outscript.NewMultisig(2, key0.PubKey(), key1.PubKey()))
+
+	tx := &outscript.BtcTx{
+		Version: 2,
+		In: []*outscript.BtcTxInput{
+			{TXID: outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f"))), Sequence: 0xffffffff},
+			{TXID: outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f"))), Vout: 1, Sequence: 0xffffffff},
+		},
+		Out: []*outscript.BtcTxOutput{{Amount: 190000, Script: must(ms.Out("p2wsh")).Bytes()}},
+	}
+	p := outscript.NewPsbt(tx)
+	p.In[0].WitnessUtxo = &outscript.BtcTxOutput{Amount: 100000, Script: must(ms.Out("p2sh:p2wsh")).Bytes()}
+	p.In[1].WitnessUtxo = &outscript.BtcTxOutput{Amount: 100000, Script: must(ms.Out("p2sh")).Bytes()}
+
+	p2 := must(outscript.ParsePsbt(p.Bytes()))
+	for i, key := range []*secp256k1.PrivateKey{key0, key1} {
+		target := []*outscript.Psbt{p, p2}[i]
+		err := target.Sign(
+			&outscript.BtcTxSign{Key: key, Scheme: "p2sh:p2wsh:multisig", Multisig: ms},
+			&outscript.BtcTxSign{Key: key, Scheme: "p2sh:multisig", Multisig: ms},
+		)
+		if err != nil {
+			t.Fatalf("failed to sign: %s", err)
+		}
+	}
+	if err := p.Finalize(); err == nil {
+		t.Errorf("expected error finalizing without enough signatures")
+	}
+	if err := p.Combine(p2); err != nil {
+		t.Fatalf("failed to combine: %s", err)
+	}
+	if err := p.Finalize(); err != nil {
+		t.Fatalf("failed to finalize: %s", err)
+	}
+	final := must(p.Extract())
+
+	w := final.In[0].Witnesses
+	if len(w) != 4 || len(w[0]) != 0 || !bytes.Equal(w[3], ms.Script()) {
+		t.Errorf("unexpected p2sh:p2wsh witness")
+	}
+	if !bytes.Equal(final.In[0].Script, outscript.PushBytes(must(ms.Out("p2wsh")).Bytes())) {
+		t.Errorf("unexpected p2sh:p2wsh script")
+	}
+	if len(final.In[1].Witnesses) != 0 || final.In[1].Script[0] != 0 || !bytes.HasSuffix(final.In[1].Script, outscript.PushBytes(ms.Script())) {
+		t.Errorf("unexpected p2sh script %x", final.In[1].Script)
+	}
+	if err := final.Verify([]*outscript.BtcTxOutput{p.In[0].WitnessUtxo, p.In[1].WitnessUtxo}); err != nil {
+		t.Errorf("failed to verify extracted transaction: %s", err)
+	}
+}
@@ -0,0 +1,971 @@
+package outscript
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// PSBT key types, see BIP-174 and BIP-370
+const (
+	psbtGlobalUnsignedTx       = 0x00
+	psbtGlobalTxVersion        = 0x02
+	psbtGlobalFallbackLocktime = 0x03
+	psbtGlobalInputCount       = 0x04
+	psbtGlobalOutputCount      = 0x05
+	psbtGlobalTxModifiable     = 0x06
+	psbtGlobalVersion          = 0xfb
+
+	psbtInNonWitnessUtxo         = 0x00
+	psbtInWitnessUtxo            = 0x01
+	psbtInPartialSig             = 0x02
+	psbtInSigHashType            = 0x03
+	psbtInRedeemScript           = 0x04
+	psbtInWitnessScript          = 0x05
+	psbtInBip32Derivation        = 0x06
+	psbtInFinalScriptSig         = 0x07
+	psbtInFinalScriptWitness     = 0x08
+	psbtInPreviousTxid           = 0x0e
+	psbtInOutputIndex            = 0x0f
+	psbtInSequence               = 0x10
+	psbtInRequiredTimeLocktime   = 0x11
+	psbtInRequiredHeightLocktime = 0x12
+	psbtInTapKeySig              = 0x13
+	psbtInTapScriptSig           = 0x14
+	psbtInTapLeafScript          = 0x15
+	psbtInTapBip32Derivation     = 0x16
+	psbtInTapInternalKey         = 0x17
+	psbtInTapMerkleRoot          = 0x18
+
+	psbtOutRedeemScript       = 0x00
+	psbtOutWitnessScript      = 0x01
+	psbtOutBip32Derivation    = 0x02
+	psbtOutAmount             = 0x03
+	psbtOutScript             = 0x04
+	psbtOutTapInternalKey     = 0x05
+	psbtOutTapTree            = 0x06
+	psbtOutTapBip32Derivation = 0x07
+)
+
+var psbtMagic = []byte{'p', 's', 'b', 't', 0xff}
+
+// Psbt is a partially signed bitcoin transaction as defined in BIP-174 (version 0) and
+// BIP-370 (version 2). It carries an unsigned transaction along with the data needed by
+// signers, so that it can be passed around between watch-only wallets and offline signers.
+type Psbt struct {
+	Version    uint32          // PSBT version, 0 or 2
+	Tx         *BtcTx          // unsigned transaction, for version 2 Locktime is the fallback locktime
+	Modifiable byte            // PSBT_GLOBAL_TX_MODIFIABLE flags, version 2 only
+	In         []*PsbtInput    // per-input data, one for each Tx.In
+	Out        []*PsbtOutput   // per-output data, one for each Tx.Out
+	Unknown    []*PsbtKeyValue // other global values (xpubs, proprietary, etc), kept as is
+}
+
+// PsbtKeyValue is a raw PSBT key/value pair, used for values not handled by this package.
+type PsbtKeyValue struct {
+	Key   []byte
+	Value []byte
+}
+
+// PsbtDerivation is a BIP-32 derivation path for a public key found in a PSBT.
+type PsbtDerivation struct {
+	PubKey      []byte
+	Fingerprint uint32   // master key fingerprint, as big endian
+	Path        []uint32 // derivation path, hardened indexes have the 0x80000000 bit set
+	LeafHashes  [][]byte // taproot leaves the key appears in, taproot derivations only
+}
+
+// PsbtPartialSig is a signature for a given public key, or for a x-only public key and
+// leaf hash in the case of taproot script-path signatures.
+type PsbtPartialSig struct {
+	PubKey   []byte
+	LeafHash []byte // taproot leaf hash, script-path signatures only
+	Sig      []byte
+}
+
+// PsbtTapLeaf is a taproot leaf script and the control block needed to spend it.
+type PsbtTapLeaf struct {
+	ControlBlock []byte
+	Script       []byte
+	Version      byte
+}
+
+// PsbtInput holds the PSBT data for a single transaction input.
+type PsbtInput struct {
+	NonWitnessUtxo         *BtcTx       // full transaction containing the spent output
+	WitnessUtxo            *BtcTxOutput // spent output, for segwit inputs
+	PartialSigs            []*PsbtPartialSig
+	SigHash                uint32 // requested sighash type, 0 if not set
+	RedeemScript           []byte
+	WitnessScript          []byte
+	Derivations            []*PsbtDerivation
+	FinalScriptSig         []byte
+	FinalScriptWitness     [][]byte
+	RequiredTimeLocktime   uint32 // version 2 only, 0 if not set
+	RequiredHeightLocktime uint32 // version 2 only, 0 if not set
+	TapKeySig              []byte
+	TapScriptSigs          []*PsbtPartialSig
+	TapLeafScripts         []*PsbtTapLeaf
+	TapDerivations         []*PsbtDerivation
+	TapInternalKey         []byte
+	TapMerkleRoot          []byte
+	Unknown                []*PsbtKeyValue
+}
+
+// PsbtOutput holds the PSBT data for a single transaction output.
+type PsbtOutput struct {
+	RedeemScript   []byte
+	WitnessScript  []byte
+	Derivations    []*PsbtDerivation
+	TapInternalKey []byte
+	TapTree        *TaprootTree
+	TapDerivations []*PsbtDerivation
+	Unknown        []*PsbtKeyValue
+}
+
+// NewPsbt returns a version 0 [Psbt] for the given transaction. Input scripts and witnesses
+// are not part of the unsigned transaction and will be cleared in the copy kept by the PSBT.
+func NewPsbt(tx *BtcTx) *Psbt {
+	p := &Psbt{Tx: tx.Dup()}
+	p.Tx.ClearInputs()
+	p.In = make([]*PsbtInput, len(tx.In))
+	for n := range p.In {
+		p.In[n] = &PsbtInput{}
+	}
+	p.Out = make([]*PsbtOutput, len(tx.Out))
+	for n := range p.Out {
+		p.Out[n] = &PsbtOutput{}
+	}
+	return p
+}
+
+// ParsePsbt parses a PSBT either in binary form or base64 encoded.
+func ParsePsbt(buf []byte) (*Psbt, error) {
+	if !bytes.HasPrefix(buf, psbtMagic) {
+		dec, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(buf)))
+		if err != nil {
+			return nil, fmt.Errorf("invalid psbt: %w", err)
+		}
+		buf = dec
+	}
+	p := &Psbt{}
+	if err := p.UnmarshalBinary(buf); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// MarshalBinary implements [encoding.BinaryMarshaler] and returns the serialized PSBT.
+func (p *Psbt) MarshalBinary() ([]byte, error) {
+	return p.Bytes(), nil
+}
+
+// UnmarshalBinary implements [encoding.BinaryUnmarshaler] and parses a binary PSBT.
+func (p *Psbt) UnmarshalBinary(buf []byte) error {
+	_, err := p.ReadFrom(bytes.NewReader(buf))
+	return err
+}
+
+// MarshalText returns the PSBT encoded in base64, as is commonly used to exchange PSBTs.
+func (p *Psbt) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText parses a base64 encoded PSBT.
+func (p *Psbt) UnmarshalText(text []byte) error {
+	buf, err := base64.StdEncoding.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	return p.UnmarshalBinary(buf)
+}
+
+// String returns the PSBT encoded in base64.
+func (p *Psbt) String() string {
+	return base64.StdEncoding.EncodeToString(p.Bytes())
+}
+
+// Bytes returns the serialized PSBT.
+func (p *Psbt) Bytes() []byte {
+	buf := slices.Clone(psbtMagic)
+
+	// global map
+	switch p.Version {
+	case 0:
+		buf = appendPsbtPair(buf, []byte{psbtGlobalUnsignedTx}, p.Tx.exportBytes(false))
+	default:
+		buf = appendPsbtPair(buf, []byte{psbtGlobalTxVersion}, binary.LittleEndian.AppendUint32(nil, p.Tx.Version))
+		if p.Tx.Locktime != 0 {
+			buf = appendPsbtPair(buf, []byte{psbtGlobalFallbackLocktime}, binary.LittleEndian.AppendUint32(nil, p.Tx.Locktime))
+		}
+		buf = appendPsbtPair(buf, []byte{psbtGlobalInputCount}, BtcVarInt(len(p.Tx.In)).Bytes())
+		buf = appendPsbtPair(buf, []byte{psbtGlobalOutputCount}, BtcVarInt(len(p.Tx.Out)).Bytes())
+		if p.Modifiable != 0 {
+			buf = appendPsbtPair(buf, []byte{psbtGlobalTxModifiable}, []byte{p.Modifiable})
+		}
+		buf = appendPsbtPair(buf, []byte{psbtGlobalVersion}, binary.LittleEndian.AppendUint32(nil, p.Version))
+	}
+	for _, kv := range p.Unknown {
+		buf = appendPsbtPair(buf, kv.Key, kv.Value)
+	}
+	buf = append(buf, 0)
+
+	for n, in := range p.In {
+		var txin *BtcTxInput
+		if p.Version != 0 {
+			txin = p.Tx.In[n]
+		}
+		for _, kv := range in.pairs(txin) {
+			buf = appendPsbtPair(buf, kv.Key, kv.Value)
+		}
+		buf = append(buf, 0)
+	}
+	for n, out := range p.Out {
+		var txout *BtcTxOutput
+		if p.Version != 0 {
+			txout = p.Tx.Out[n]
+		}
+		for _, kv := range out.pairs(txout) {
+			buf = appendPsbtPair(buf, kv.Key, kv.Value)
+		}
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+// ReadFrom reads and parses a binary PSBT from r.
+func (p *Psbt) ReadFrom(r io.Reader) (int64, error) {
+	h := &readHelper{R: r}
+	magic := make([]byte, len(psbtMagic))
+	h.readFull(magic)
+	if h.Err != nil {
+		return h.ret()
+	}
+	if !bytes.Equal(magic, psbtMagic) {
+		return h.err(errors.New("invalid psbt: bad magic"))
+	}
+
+	global, err := readPsbtMap(h)
+	if err != nil {
+		return h.err(err)
+	}
+	*p = Psbt{}
+	var inCnt, outCnt uint64
+	var hasVersion, hasInCnt, hasOutCnt bool
+	tx := &BtcTx{}
+	for _, kv := range global {
+		if len(kv.Key) != 1 {
+			// all the global values we handle have no key data
+			switch kv.Key[0] {
+			case psbtGlobalUnsignedTx, psbtGlobalTxVersion, psbtGlobalFallbackLocktime, psbtGlobalInputCount, psbtGlobalOutputCount, psbtGlobalTxModifiable, psbtGlobalVersion:
+				return h.err(fmt.Errorf("invalid psbt: unexpected key data for global type %#x", kv.Key[0]))
+			}
+			p.Unknown = append(p.Unknown, kv)
+			continue
+		}
+		switch kv.Key[0] {
+		case psbtGlobalUnsignedTx:
+			// the unsigned transaction never has witness data, read it as legacy so that a
+			// transaction without inputs is not mistaken for a segwit marker
+			if _, err := tx.readFrom(bytes.NewReader(kv.Value), false); err != nil {
+				return h.err(fmt.Errorf("invalid psbt unsigned transaction: %w", err))
+			}
+			p.Tx = tx
+		case psbtGlobalTxVersion:
+			if len(kv.Value) != 4 {
+				return h.err(errors.New("invalid psbt transaction version"))
+			}
+			tx.Version = binary.LittleEndian.Uint32(kv.Value)
+			hasVersion = true
+		case psbtGlobalFallbackLocktime:
+			if len(kv.Value) != 4 {
+				return h.err(errors.New("invalid psbt fallback locktime"))
+			}
+			tx.Locktime = binary.LittleEndian.Uint32(kv.Value)
+		case psbtGlobalInputCount:
+			inCnt, hasInCnt = psbtVarInt(kv.Value)
+			if !hasInCnt {
+				return h.err(errors.New("invalid psbt input count"))
+			}
+		case psbtGlobalOutputCount:
+			outCnt, hasOutCnt = psbtVarInt(kv.Value)
+			if !hasOutCnt {
+				return h.err(errors.New("invalid psbt output count"))
+			}
+		case psbtGlobalTxModifiable:
+			if len(kv.Value) != 1 {
+				return h.err(errors.New("invalid psbt modifiable flags"))
+			}
+			p.Modifiable = kv.Value[0]
+		case psbtGlobalVersion:
+			if len(kv.Value) != 4 {
+				return h.err(errors.New("invalid psbt version"))
+			}
+			p.Version = binary.LittleEndian.Uint32(kv.Value)
+		default:
+			p.Unknown = append(p.Unknown, kv)
+		}
+	}
+
+	switch p.Version {
+	case 0:
+		if p.Tx == nil {
+			return h.err(errors.New("invalid psbt: missing unsigned transaction"))
+		}
+		if hasVersion || hasInCnt || hasOutCnt || p.Modifiable != 0 {
+			return h.err(errors.New("invalid psbt: version 2 fields found in version 0 psbt"))
+		}
+		for _, in := range p.Tx.In {
+			if len(in.Script) > 0 || len(in.Witnesses) > 0 {
+				return h.err(errors.New("invalid psbt: unsigned transaction has input scripts"))
+			}
+		}
+	case 2:
+		if p.Tx != nil {
+			return h.err(errors.New("invalid psbt: unsigned transaction found in version 2 psbt"))
+		}
+		if !hasVersion || !hasInCnt || !hasOutCnt {
+			return h.err(errors.New("invalid psbt: missing required version 2 fields"))
+		}
+		if inCnt > 10000 || outCnt > 65536 {
+			return h.err(errors.New("invalid psbt: too many inputs or outputs"))
+		}
+		tx.In = make([]*BtcTxInput, inCnt)
+		for n := range tx.In {
+			tx.In[n] = &BtcTxInput{Sequence: 0xffffffff}
+		}
+		tx.Out = make([]*BtcTxOutput, outCnt)
+		for n := range tx.Out {
+			tx.Out[n] = &BtcTxOutput{N: n}
+		}
+		p.Tx = tx
+	default:
+		return h.err(fmt.Errorf("unsupported psbt version %d", p.Version))
+	}
+
+	p.In = make([]*PsbtInput, len(p.Tx.In))
+	for n := range p.In {
+		kvs, err := readPsbtMap(h)
+		if err != nil {
+			return h.err(err)
+		}
+		var txin *BtcTxInput
+		if p.Version != 0 {
+			txin = p.Tx.In[n]
+		}
+		p.In[n] = &PsbtInput{}
+		for _, kv := range kvs {
+			if err := p.In[n].set(kv, txin); err != nil {
+				return h.err(fmt.Errorf("invalid psbt input %d: %w", n, err))
+			}
+		}
+		if txin != nil && (!hasPsbtKey(kvs, psbtInPreviousTxid) || !hasPsbtKey(kvs, psbtInOutputIndex)) {
+			return h.err(fmt.Errorf("invalid psbt input %d: missing previous txid or output index", n))
+		}
+	}
+	p.Out = make([]*PsbtOutput, len(p.Tx.Out))
+	for n := range p.Out {
+		kvs, err := readPsbtMap(h)
+		if err != nil {
+			return h.err(err)
+		}
+		var txout *BtcTxOutput
+		if p.Version != 0 {
+			txout = p.Tx.Out[n]
+		}
+		p.Out[n] = &PsbtOutput{}
+		for _, kv := range kvs {
+			if err := p.Out[n].set(kv, txout); err != nil {
+				return h.err(fmt.Errorf("invalid psbt output %d: %w", n, err))
+			}
+		}
+		if txout != nil && (!hasPsbtKey(kvs, psbtOutAmount) || !hasPsbtKey(kvs, psbtOutScript)) {
+			return h.err(fmt.Errorf("invalid psbt output %d: missing amount or script", n))
+		}
+	}
+	return h.ret()
+}
+
+// Combine merges the data found in others into p, as described in BIP-174. All the PSBTs
+// must be for the same unsigned transaction.
+func (p *Psbt) Combine(others ...*Psbt) error {
+	tx, err := p.unsignedTx()
+	if err != nil {
+		return err
+	}
+	for _, o := range others {
+		otx, err := o.unsignedTx()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(tx.exportBytes(false), otx.exportBytes(false)) {
+			return errors.New("cannot combine psbts for different transactions")
+		}
+		p.Unknown = mergePsbtPairs(p.Unknown, o.Unknown)
+		for n, in := range p.In {
+			res := &PsbtInput{}
+			for _, kv := range mergePsbtPairs(in.pairs(nil), o.In[n].pairs(nil)) {
+				if err := res.set(kv, nil); err != nil {
+					return err
+				}
+			}
+			p.In[n] = res
+		}
+		for n, out := range p.Out {
+			res := &PsbtOutput{}
+			for _, kv := range mergePsbtPairs(out.pairs(nil), o.Out[n].pairs(nil)) {
+				if err := res.set(kv, nil); err != nil {
+					return err
+				}
+			}
+			p.Out[n] = res
+		}
+	}
+	return nil
+}
+
+// unsignedTx returns a copy of the unsigned transaction, with the locktime computed as per
+// BIP-370 for version 2 PSBTs
+func (p *Psbt) unsignedTx() (*BtcTx, error) {
+	if p.Tx == nil || len(p.Tx.In) != len(p.In) || len(p.Tx.Out) != len(p.Out) {
+		return nil, errors.New("psbt inputs and outputs do not match its transaction")
+	}
+	tx := p.Tx.Dup()
+	tx.ClearInputs()
+	if p.Version == 0 {
+		return tx, nil
+	}
+
+	// use the highest height locktime if all inputs with requirements accept it, or the highest time locktime
+	var height, time uint32
+	heightOk, timeOk, found := true, true, false
+	for _, in := range p.In {
+		if in.RequiredHeightLocktime == 0 && in.RequiredTimeLocktime == 0 {
+			continue
+		}
+		found = true
+		if in.RequiredHeightLocktime == 0 {
+			heightOk = false
+		}
+		if in.RequiredTimeLocktime == 0 {
+			timeOk = false
+		}
+		height = max(height, in.RequiredHeightLocktime)
+		time = max(time, in.RequiredTimeLocktime)
+	}
+	switch {
+	case !found:
+		// use fallback locktime
+	case heightOk:
+		tx.Locktime = height
+	case timeOk:
+		tx.Locktime = time
+	default:
+		return nil, errors.New("psbt inputs have incompatible locktime requirements")
+	}
+	return tx, nil
+}
+
+// prevOut returns the output spent by input n, or nil if the PSBT has no UTXO data for it
+func (p *Psbt) prevOut(n int) (*BtcTxOutput, error) {
+	in := p.In[n]
+	if in.WitnessUtxo != nil {
+		return in.WitnessUtxo, nil
+	}
+	if in.NonWitnessUtxo == nil {
+		return nil, nil
+	}
+	txin := p.Tx.In[n]
+	h, err := in.NonWitnessUtxo.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(h, txin.TXID[:]) {
+		return nil, fmt.Errorf("input %d: non-witness utxo does not match the spent transaction", n)
+	}
+	if int(txin.Vout) >= len(in.NonWitnessUtxo.Out) {
+		return nil, fmt.Errorf("input %d: spent output not found in non-witness utxo", n)
+	}
+	return in.NonWitnessUtxo.Out[txin.Vout], nil
+}
+
+// pairs returns the key/value pairs for this input. If txin is not nil, version 2 fields are included
+func (in *PsbtInput) pairs(txin *BtcTxInput) []*PsbtKeyValue {
+	var res []*PsbtKeyValue
+	add := func(key []byte, value []byte) {
+		res = append(res, &PsbtKeyValue{Key: key, Value: value})
+	}
+
+	if in.NonWitnessUtxo != nil {
+		add([]byte{psbtInNonWitnessUtxo}, in.NonWitnessUtxo.Bytes())
+	}
+	if in.WitnessUtxo != nil {
+		add([]byte{psbtInWitnessUtxo}, in.WitnessUtxo.Bytes())
+	}
+	for _, sig := range in.PartialSigs {
+		add(append([]byte{psbtInPartialSig}, sig.PubKey...), sig.Sig)
+	}
+	if in.SigHash != 0 {
+		add([]byte{psbtInSigHashType}, binary.LittleEndian.AppendUint32(nil, in.SigHash))
+	}
+	if in.RedeemScript != nil {
+		add([]byte{psbtInRedeemScript}, in.RedeemScript)
+	}
+	if in.WitnessScript != nil {
+		add([]byte{psbtInWitnessScript}, in.WitnessScript)
+	}
+	for _, d := range in.Derivations {
+		add(append([]byte{psbtInBip32Derivation}, d.PubKey...), d.bytes(false))
+	}
+	if in.FinalScriptSig != nil {
+		add([]byte{psbtInFinalScriptSig}, in.FinalScriptSig)
+	}
+	if in.FinalScriptWitness != nil {
+		add([]byte{psbtInFinalScriptWitness}, encodeWitness(in.FinalScriptWitness))
+	}
+	if txin != nil {
+		txid := slices.Clone(txin.TXID[:])
+		slices.Reverse(txid)
+		add([]byte{psbtInPreviousTxid}, txid)
+		add([]byte{psbtInOutputIndex}, binary.LittleEndian.AppendUint32(nil, txin.Vout))
+		if txin.Sequence != 0xffffffff {
+			add([]byte{psbtInSequence}, binary.LittleEndian.AppendUint32(nil, txin.Sequence))
+		}
+	}
+	if in.RequiredTimeLocktime != 0 {
+		add([]byte{psbtInRequiredTimeLocktime}, binary.LittleEndian.AppendUint32(nil, in.RequiredTimeLocktime))
+	}
+	if in.RequiredHeightLocktime != 0 {
+		add([]byte{psbtInRequiredHeightLocktime}, binary.LittleEndian.AppendUint32(nil, in.RequiredHeightLocktime))
+	}
+	if in.TapKeySig != nil {
+		add([]byte{psbtInTapKeySig}, in.TapKeySig)
+	}
+	for _, sig := range in.TapScriptSigs {
+		add(slices.Concat([]byte{psbtInTapScriptSig}, sig.PubKey, sig.LeafHash), sig.Sig)
+	}
+	for _, leaf := range in.TapLeafScripts {
+		add(append([]byte{psbtInTapLeafScript}, leaf.ControlBlock...), append(slices.Clone(leaf.Script), leaf.Version))
+	}
+	for _, d := range in.TapDerivations {
+		add(append([]byte{psbtInTapBip32Derivation}, d.PubKey...), d.bytes(true))
+	}
+	if in.TapInternalKey != nil {
+		add([]byte{psbtInTapInternalKey}, in.TapInternalKey)
+	}
+	if in.TapMerkleRoot != nil {
+		add([]byte{psbtInTapMerkleRoot}, in.TapMerkleRoot)
+	}
+	return append(res, in.Unknown...)
+}
+
+// set sets a value of the input from its key/value pair. Version 2 fields are stored in txin
+func (in *PsbtInput) set(kv *PsbtKeyValue, txin *BtcTxInput) error {
+	typ, keyData := kv.Key[0], kv.Key[1:]
+	if typ >= 0xfc {
+		// proprietary or multi-byte types
+		in.Unknown = append(in.Unknown, kv)
+		return nil
+	}
+	switch typ {
+	case psbtInPartialSig:
+		if len(keyData) != 33 && len(keyData) != 65 {
+			return errors.New("invalid partial signature public key")
+		}
+		in.PartialSigs = append(in.PartialSigs, &PsbtPartialSig{PubKey: keyData, Sig: kv.Value})
+		return nil
+	case psbtInBip32Derivation, psbtInTapBip32Derivation:
+		d, err := parsePsbtDerivation(keyData, kv.Value, typ == psbtInTapBip32Derivation)
+		if err != nil {
+			return err
+		}
+		if typ == psbtInTapBip32Derivation {
+			in.TapDerivations = append(in.TapDerivations, d)
+		} else {
+			in.Derivations = append(in.Derivations, d)
+		}
+		return nil
+	case psbtInTapScriptSig:
+		if len(keyData) != 64 {
+			return errors.New("invalid taproot script signature key")
+		}
+		in.TapScriptSigs = append(in.TapScriptSigs, &PsbtPartialSig{PubKey: keyData[:32], LeafHash: keyData[32:], Sig: kv.Value})
+		return nil
+	case psbtInTapLeafScript:
+		if len(keyData) < 33 || (len(keyData)-33)%32 != 0 || len(kv.Value) == 0 {
+			return errors.New("invalid taproot leaf script")
+		}
+		in.TapLeafScripts = append(in.TapLeafScripts, &PsbtTapLeaf{ControlBlock: keyData, Script: kv.Value[:len(kv.Value)-1], Version: kv.Value[len(kv.Value)-1]})
+		return nil
+	}
+
+	if len(keyData) != 0 {
+		switch typ {
+		case psbtInNonWitnessUtxo, psbtInWitnessUtxo, psbtInSigHashType, psbtInRedeemScript, psbtInWitnessScript,
+			psbtInFinalScriptSig, psbtInFinalScriptWitness, psbtInTapKeySig, psbtInTapInternalKey, psbtInTapMerkleRoot:
+			return fmt.Errorf("unexpected key data for type %#x", typ)
+		case psbtInPreviousTxid, psbtInOutputIndex, psbtInSequence, psbtInRequiredTimeLocktime, psbtInRequiredHeightLocktime:
+			// version 2 types, kept as unknown values in version 0 psbts
+			if txin != nil {
+				return fmt.Errorf("unexpected key data for type %#x", typ)
+			}
+		}
+		in.Unknown = append(in.Unknown, kv)
+		return nil
+	}
+	var err error
+	switch typ {
+	case psbtInNonWitnessUtxo:
+		in.NonWitnessUtxo = &BtcTx{}
+		err = in.NonWitnessUtxo.UnmarshalBinary(kv.Value)
+	case psbtInWitnessUtxo:
+		in.WitnessUtxo = &BtcTxOutput{}
+		_, err = in.WitnessUtxo.ReadFrom(bytes.NewReader(kv.Value))
+	case psbtInSigHashType:
+		in.SigHash, err = psbtUint32(kv.Value)
+	case psbtInRedeemScript:
+		in.RedeemScript = kv.Value
+	case psbtInWitnessScript:
+		in.WitnessScript = kv.Value
+	case psbtInFinalScriptSig:
+		in.FinalScriptSig = kv.Value
+	case psbtInFinalScriptWitness:
+		in.FinalScriptWitness, err = decodeWitness(kv.Value)
+	case psbtInPreviousTxid, psbtInOutputIndex, psbtInSequence:
+		if txin == nil {
+			return errors.New("version 2 field found in version 0 psbt")
+		}
+		switch typ {
+		case psbtInPreviousTxid:
+			if len(kv.Value) != 32 {
+				return errors.New("invalid previous txid")
+			}
+			copy(txin.TXID[:], kv.Value)
+			slices.Reverse(txin.TXID[:])
+		case psbtInOutputIndex:
+			txin.Vout, err = psbtUint32(kv.Value)
+		case psbtInSequence:
+			txin.Sequence, err = psbtUint32(kv.Value)
+		}
+	case psbtInRequiredTimeLocktime:
+		in.RequiredTimeLocktime, err = psbtUint32(kv.Value)
+		if err == nil && in.RequiredTimeLocktime < 500000000 {
+			err = errors.New("invalid required time locktime")
+		}
+	case psbtInRequiredHeightLocktime:
+		in.RequiredHeightLocktime, err = psbtUint32(kv.Value)
+		if err == nil && (in.RequiredHeightLocktime == 0 || in.RequiredHeightLocktime >= 500000000) {
+			err = errors.New("invalid required height locktime")
+		}
+	case psbtInTapKeySig:
+		if len(kv.Value) != 64 && len(kv.Value) != 65 {
+			return errors.New("invalid taproot key signature")
+		}
+		in.TapKeySig = kv.Value
+	case psbtInTapInternalKey:
+		if len(kv.Value) != 32 {
+			return errors.New("invalid taproot internal key")
+		}
+		in.TapInternalKey = kv.Value
+	case psbtInTapMerkleRoot:
+		if len(kv.Value) != 32 {
+			return errors.New("invalid taproot merkle root")
+		}
+		in.TapMerkleRoot = kv.Value
+	default:
+		in.Unknown = append(in.Unknown, kv)
+	}
+	return err
+}
+
+// isFinal returns true if the input has been finalized
+func (in *PsbtInput) isFinal() bool {
+	return in.FinalScriptSig != nil || in.FinalScriptWitness != nil
+}
+
+// pairs returns the key/value pairs for this output. If txout is not nil, version 2 fields are included
+func (out *PsbtOutput) pairs(txout *BtcTxOutput) []*PsbtKeyValue {
+	var res []*PsbtKeyValue
+	add := func(key []byte, value []byte) {
+		res = append(res, &PsbtKeyValue{Key: key, Value: value})
+	}
+
+	if out.RedeemScript != nil {
+		add([]byte{psbtOutRedeemScript}, out.RedeemScript)
+	}
+	if out.WitnessScript != nil {
+		add([]byte{psbtOutWitnessScript}, out.WitnessScript)
+	}
+	for _, d := range out.Derivations {
+		add(append([]byte{psbtOutBip32Derivation}, d.PubKey...), d.bytes(false))
+	}
+	if txout != nil {
+		add([]byte{psbtOutAmount}, binary.LittleEndian.AppendUint64(nil, uint64(txout.Amount)))
+		add([]byte{psbtOutScript}, txout.Script)
+	}
+	if out.TapInternalKey != nil {
+		add([]byte{psbtOutTapInternalKey}, out.TapInternalKey)
+	}
+	if out.TapTree != nil {
+		add([]byte{psbtOutTapTree}, encodePsbtTapTree(out.TapTree))
+	}
+	for _, d := range out.TapDerivations {
+		add(append([]byte{psbtOutTapBip32Derivation}, d.PubKey...), d.bytes(true))
+	}
+	return append(res, out.Unknown...)
+}
+
+// set sets a value of the output from its key/value pair. Version 2 fields are stored in txout
+func (out *PsbtOutput) set(kv *PsbtKeyValue, txout *BtcTxOutput) error {
+	typ, keyData := kv.Key[0], kv.Key[1:]
+	switch {
+	case typ >= 0xfc:
+		out.Unknown = append(out.Unknown, kv)
+		return nil
+	case typ == psbtOutBip32Derivation || typ == psbtOutTapBip32Derivation:
+		d, err := parsePsbtDerivation(keyData, kv.Value, typ == psbtOutTapBip32Derivation)
+		if err != nil {
+			return err
+		}
+		if typ == psbtOutTapBip32Derivation {
+			out.TapDerivations = append(out.TapDerivations, d)
+		} else {
+			out.Derivations = append(out.Derivations, d)
+		}
+		return nil
+	case len(keyData) != 0:
+		switch typ {
+		case psbtOutRedeemScript, psbtOutWitnessScript, psbtOutTapInternalKey, psbtOutTapTree:
+			return fmt.Errorf("unexpected key data for type %#x", typ)
+		case psbtOutAmount, psbtOutScript:
+			// version 2 types, kept as unknown values in version 0 psbts
+			if txout != nil {
+				return fmt.Errorf("unexpected key data for type %#x", typ)
+			}
+		}
+		out.Unknown = append(out.Unknown, kv)
+		return nil
+	}
+
+	var err error
+	switch typ {
+	case psbtOutRedeemScript:
+		out.RedeemScript = kv.Value
+	case psbtOutWitnessScript:
+		out.WitnessScript = kv.Value
+	case psbtOutAmount, psbtOutScript:
+		if txout == nil {
+			return errors.New("version 2 field found in version 0 psbt")
+		}
+		if typ == psbtOutScript {
+			txout.Script = kv.Value
+			break
+		}
+		if len(kv.Value) != 8 {
+			return errors.New("invalid output amount")
+		}
+		txout.Amount = BtcAmount(binary.LittleEndian.Uint64(kv.Value))
+	case psbtOutTapInternalKey:
+		if len(kv.Value) != 32 {
+			return errors.New("invalid taproot internal key")
+		}
+		out.TapInternalKey = kv.Value
+	case psbtOutTapTree:
+		out.TapTree, err = parsePsbtTapTree(kv.Value)
+	default:
+		out.Unknown = append(out.Unknown, kv)
+	}
+	return err
+}
+
+// bytes returns the PSBT value for this derivation
+func (d *PsbtDerivation) bytes(taproot bool) []byte {
+	var buf []byte
+	if taproot {
+		buf = BtcVarInt(len(d.LeafHashes)).Bytes()
+		buf = append(buf, slices.Concat(d.LeafHashes...)...)
+	}
+	buf = binary.BigEndian.AppendUint32(buf, d.Fingerprint)
+	for _, v := range d.Path {
+		buf = binary.LittleEndian.AppendUint32(buf, v)
+	}
+	return buf
+}
+
+func parsePsbtDerivation(pubKey, value []byte, taproot bool) (*PsbtDerivation, error) {
+	d := &PsbtDerivation{PubKey: pubKey}
+	if taproot {
+		if len(pubKey) != 32 {
+			return nil, errors.New("invalid taproot derivation public key")
+		}
+		cnt, ok := psbtVarInt(value)
+		if !ok || uint64(len(value)) < uint64(BtcVarInt(cnt).Len())+cnt*32 {
+			return nil, errors.New("invalid taproot derivation leaf hashes")
+		}
+		value = value[BtcVarInt(cnt).Len():]
+		for range cnt {
+			d.LeafHashes = append(d.LeafHashes, value[:32])
+			value = value[32:]
+		}
+	} else if len(pubKey) != 33 && len(pubKey) != 65 {
+		return nil, errors.New("invalid derivation public key")
+	}
+	if len(value) < 4 || len(value)%4 != 0 {
+		return nil, errors.New("invalid derivation path")
+	}
+	d.Fingerprint = binary.BigEndian.Uint32(value)
+	for v := value[4:]; len(v) > 0; v = v[4:] {
+		d.Path = append(d.Path, binary.LittleEndian.Uint32(v))
+	}
+	return d, nil
+}
+
+// encodePsbtTapTree encodes a taproot tree as a list of (depth, leaf version, script)
+func encodePsbtTapTree(t *TaprootTree) []byte {
+	var buf []byte
+	var walk func(t *TaprootTree, depth byte)
+	walk = func(t *TaprootTree, depth byte) {
+		if t.IsLeaf() {
+			buf = append(buf, depth, t.Version)
+			buf = append(buf, BtcVarInt(len(t.Script)).Bytes()...)
+			buf = append(buf, t.Script...)
+			return
+		}
+		walk(t.Left, depth+1)
+		walk(t.Right, depth+1)
+	}
+	walk(t, 0)
+	return buf
+}
+
+// parsePsbtTapTree rebuilds a taproot tree from its PSBT encoding
+func parsePsbtTapTree(buf []byte) (*TaprootTree, error) {
+	type node struct {
+		t     *TaprootTree
+		depth byte
+	}
+	var stack []node
+	h := &readHelper{R: bytes.NewReader(buf)}
+	for h.N < int64(len(buf)) {
+		depth := h.readByte()
+		version := h.readByte()
+		script := h.readVarBuf()
+		if h.Err != nil {
+			return nil, fmt.Errorf("invalid taproot tree: %w", h.Err)
+		}
+		if depth > 128 {
+			return nil, errors.New("invalid taproot tree: leaf too deep")
+		}
+		stack = append(stack, node{&TaprootTree{Script: script, Version: version}, depth})
+		// join nodes as soon as both sides of a branch are known
+		for len(stack) >= 2 && stack[len(stack)-1].depth == stack[len(stack)-2].depth {
+			l, r := stack[len(stack)-2], stack[len(stack)-1]
+			if l.depth == 0 {
+				return nil, errors.New("invalid taproot tree")
+			}
+			stack = append(stack[:len(stack)-2], node{NewTaprootBranch(l.t, r.t), l.depth - 1})
+		}
+	}
+	if len(stack) != 1 || stack[0].depth != 0 {
+		return nil, errors.New("invalid taproot tree: incomplete tree")
+	}
+	return stack[0].t, nil
+}
+
+// encodeWitness encodes a witness stack the same way it appears in transactions
+func encodeWitness(wit [][]byte) []byte {
+	buf := BtcVarInt(len(wit)).Bytes()
+	for _, b := range wit {
+		buf = append(buf, BtcVarInt(len(b)).Bytes()...)
+		buf = append(buf, b...)
+	}
+	return buf
+}
+
+func decodeWitness(buf []byte) ([][]byte, error) {
+	h := &readHelper{R: bytes.NewReader(buf)}
+	var cnt BtcVarInt
+	h.readTo(&cnt)
+	if cnt > 10000 {
+		return nil, errors.New("invalid witness: too many items")
+	}
+	res := make([][]byte, cnt)
+	for n := range res {
+		res[n] = h.readVarBuf()
+		if res[n] == nil {
+			res[n] = []byte{}
+		}
+	}
+	if h.Err != nil {
+		return nil, fmt.Errorf("invalid witness: %w", h.Err)
+	}
+	return res, nil
+}
+
+// appendPsbtPair appends a key/value pair to buf
+func appendPsbtPair(buf, key, value []byte) []byte {
+	buf = append(buf, BtcVarInt(len(key)).Bytes()...)
+	buf = append(buf, key...)
+	buf = append(buf, BtcVarInt(len(value)).Bytes()...)
+	return append(buf, value...)
+}
+
+// readPsbtMap reads key/value pairs up to the map separator
+func readPsbtMap(h *readHelper) ([]*PsbtKeyValue, error) {
+	var res []*PsbtKeyValue
+	for {
+		key := h.readVarBuf()
+		if h.Err != nil {
+			return nil, fmt.Errorf("invalid psbt: %w", h.Err)
+		}
+		if key == nil {
+			// separator
+			return res, nil
+		}
+		value := h.readVarBuf()
+		if h.Err != nil {
+			return nil, fmt.Errorf("invalid psbt: %w", h.Err)
+		}
+		if value == nil {
+			value = []byte{}
+		}
+		if slices.ContainsFunc(res, func(kv *PsbtKeyValue) bool { return bytes.Equal(kv.Key, key) }) {
+			return nil, fmt.Errorf("invalid psbt: duplicate key %x", key)
+		}
+		res = append(res, &PsbtKeyValue{Key: key, Value: value})
+	}
+}
+
+// mergePsbtPairs returns a with the pairs of b whose key is not found in a appended
+func mergePsbtPairs(a, b []*PsbtKeyValue) []*PsbtKeyValue {
+	for _, kv := range b {
+		if !slices.ContainsFunc(a, func(v *PsbtKeyValue) bool { return bytes.Equal(v.Key, kv.Key) }) {
+			a = append(a, kv)
+		}
+	}
+	return a
+}
+
+// hasPsbtKey returns true if kvs holds a value of type typ without key data
+func hasPsbtKey(kvs []*PsbtKeyValue, typ byte) bool {
+	return slices.ContainsFunc(kvs, func(kv *PsbtKeyValue) bool { return len(kv.Key) == 1 && kv.Key[0] == typ })
+}
+
+func psbtUint32(v []byte) (uint32, error) {
+	if len(v) != 4 {
+		return 0, errors.New("invalid psbt value: expected 4 bytes")
+	}
+	return binary.LittleEndian.Uint32(v), nil
+}
+
+func psbtVarInt(v []byte) (uint64, bool) {
+	var res BtcVarInt
+	if _, err := res.ReadFrom(bytes.NewReader(v)); err != nil {
+		return 0, false
+	}
+	return uint64(res), true
+}
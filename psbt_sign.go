@@ -0,0 +1,412 @@
+package outscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/BottleFmt/gobottle"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Sign adds signatures to the PSBT, taking one [BtcTxSign] per input, which can be nil to skip
+// an input. Amount and PrevScript are taken from the input UTXO data when available, and the
+// resulting signatures are stored as partial signatures, waiting for [Psbt.Finalize].
+func (p *Psbt) Sign(keys ...*BtcTxSign) error {
+	if len(keys) != len(p.In) {
+		return errors.New("Sign requires as many keys as there are inputs")
+	}
+	tx, err := p.unsignedTx()
+	if err != nil {
+		return err
+	}
+
+	signKeys := make([]*BtcTxSign, len(keys))
+	for n, k := range keys {
+		ks := &BtcTxSign{}
+		if k != nil {
+			*ks = *k
+		}
+		prev, err := p.prevOut(n)
+		if err != nil {
+			return err
+		}
+		if prev != nil {
+			ks.Amount = prev.Amount
+			ks.PrevScript = prev.Script
+		}
+		signKeys[n] = ks
+		if ks.Key == nil {
+			continue
+		}
+
+		in := p.In[n]
+		if in.isFinal() {
+			return fmt.Errorf("input %d: already finalized", n)
+		}
+		if in.SigHash != 0 {
+			if ks.SigHash == 0 {
+				ks.SigHash = in.SigHash
+			} else if ks.SigHash != in.SigHash {
+				return fmt.Errorf("input %d: sighash type %d does not match the requested type %d", n, ks.SigHash, in.SigHash)
+			}
+		}
+		if strings.HasPrefix(ks.Scheme, "p2wsh") {
+			// allows detection of the witness script
+			tx.In[n].Script = ks.PrevScript
+		}
+	}
+
+	if err := tx.Sign(signKeys...); err != nil {
+		return err
+	}
+
+	for n, k := range signKeys {
+		if k.Key == nil {
+			continue
+		}
+		if err := p.In[n].addSignature(k, tx.In[n]); err != nil {
+			return fmt.Errorf("input %d: %w", n, err)
+		}
+	}
+	return nil
+}
+
+// addSignature stores the signature found in txin after signing with k
+func (in *PsbtInput) addSignature(k *BtcTxSign, txin *BtcTxInput) error {
+	s := New(k.Key.Public())
+	wit := txin.Witnesses
+
+	switch k.Scheme {
+	case "p2tr", "p2tr:script":
+		xonly, err := s.Generate("pubkey:xonly")
+		if err != nil {
+			return err
+		}
+		if in.TapInternalKey == nil {
+			in.TapInternalKey, err = k.Taproot.internalKey(k.Key.Public())
+			if err != nil {
+				return err
+			}
+		}
+		if in.TapMerkleRoot == nil && k.Taproot != nil && k.Taproot.Tree != nil {
+			in.TapMerkleRoot = k.Taproot.Tree.Hash()
+		}
+		if k.Scheme == "p2tr" {
+			in.TapKeySig = wit[0]
+			return nil
+		}
+
+		// our signature is the only one in the stack
+		var sig []byte
+		for _, v := range wit[:len(wit)-2] {
+			if len(v) > 0 {
+				sig = v
+			}
+		}
+		leaf := k.Taproot.Leaf
+		in.setPartialSig(&PsbtPartialSig{PubKey: xonly, LeafHash: leaf.Hash(), Sig: sig})
+		controlBlock := wit[len(wit)-1]
+		if !slices.ContainsFunc(in.TapLeafScripts, func(l *PsbtTapLeaf) bool { return bytes.Equal(l.ControlBlock, controlBlock) }) {
+			in.TapLeafScripts = append(in.TapLeafScripts, &PsbtTapLeaf{ControlBlock: controlBlock, Script: leaf.Script, Version: leaf.Version})
+		}
+		return nil
+	}
+
+	var sig, pubKey []byte
+	switch {
+	case strings.HasSuffix(k.Scheme, ":multisig"):
+		// our signature is the only one in the stack
+		stack := wit
+		if k.Scheme == "p2sh:multisig" {
+			stack, _ = parsePushes(txin.Script)
+		}
+		for _, v := range stack[1 : len(stack)-1] {
+			if len(v) > 0 {
+				sig = v
+			}
+		}
+		pubKey = k.Multisig.PubKeys[k.Multisig.index(k.Key.Public())]
+		script := k.Multisig.Script()
+		switch k.Scheme {
+		case "p2sh:multisig":
+			in.RedeemScript = script
+		case "p2wsh:multisig":
+			in.WitnessScript = script
+		case "p2sh:p2wsh:multisig":
+			in.RedeemScript = p2wshScript(script)
+			in.WitnessScript = script
+		}
+	case strings.HasPrefix(k.Scheme, "p2wsh"):
+		// [sig, witnessScript] or [sig, pubkey, witnessScript]
+		sig = wit[0]
+		in.WitnessScript = wit[len(wit)-1]
+		if len(wit) == 3 {
+			pubKey = wit[1]
+		} else {
+			pubKey, _ = ParsePushBytes(in.WitnessScript)
+		}
+	case len(wit) == 2:
+		// p2wpkh or p2sh:p2wpkh
+		sig, pubKey = wit[0], wit[1]
+		if k.Scheme == "p2sh:p2wpkh" {
+			in.RedeemScript, _ = ParsePushBytes(txin.Script)
+		}
+	default:
+		// <sig> <pubkey>, or <sig> only for p2pk
+		var n int
+		sig, n = ParsePushBytes(txin.Script)
+		pubKey, _ = ParsePushBytes(txin.Script[n:])
+		if pubKey == nil {
+			var err error
+			pubKey, err = s.Generate("pubkey:comp")
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if sig == nil || pubKey == nil {
+		return errors.New("unable to find signature")
+	}
+	in.setPartialSig(&PsbtPartialSig{PubKey: pubKey, Sig: sig})
+	return nil
+}
+
+// setPartialSig adds sig to the partial signatures, replacing any existing signature for the same key
+func (in *PsbtInput) setPartialSig(sig *PsbtPartialSig) {
+	list := &in.PartialSigs
+	if sig.LeafHash != nil {
+		list = &in.TapScriptSigs
+	}
+	*list = slices.DeleteFunc(*list, func(v *PsbtPartialSig) bool {
+		return bytes.Equal(v.PubKey, sig.PubKey) && bytes.Equal(v.LeafHash, sig.LeafHash)
+	})
+	*list = append(*list, sig)
+}
+
+// partialSig returns the signature for the given public key and leaf hash, or nil
+func (in *PsbtInput) partialSig(pubKey, leafHash []byte) []byte {
+	list := in.PartialSigs
+	if leafHash != nil {
+		list = in.TapScriptSigs
+	}
+	for _, v := range list {
+		if bytes.Equal(v.PubKey, pubKey) && bytes.Equal(v.LeafHash, leafHash) {
+			return v.Sig
+		}
+	}
+	return nil
+}
+
+// Finalize builds the final scriptSig and witness of every input that has not been finalized
+// yet from the partial signatures and scripts, then removes the data that is no longer needed.
+func (p *Psbt) Finalize() error {
+	for n, in := range p.In {
+		if in.isFinal() {
+			continue
+		}
+		prev, err := p.prevOut(n)
+		if err != nil {
+			return err
+		}
+		if prev == nil {
+			return fmt.Errorf("input %d: missing utxo data", n)
+		}
+		if err := in.finalize(prev.Script); err != nil {
+			return fmt.Errorf("input %d: %w", n, err)
+		}
+	}
+	return nil
+}
+
+// finalize computes the final scriptSig and witness for this input, spending script
+func (in *PsbtInput) finalize(script []byte) error {
+	var scriptSig []byte
+	var witness [][]byte
+	var err error
+
+	switch btcScriptType(script) {
+	case "p2tr":
+		witness, err = in.taprootStack()
+	case "p2wpkh", "p2wsh":
+		witness, err = in.witnessStack(script)
+	case "p2sh":
+		if in.RedeemScript == nil {
+			return errors.New("missing redeem script")
+		}
+		if !bytes.Equal(gobottle.Hash(in.RedeemScript, sha256.New, ripemd160.New), script[2:22]) {
+			return errors.New("redeem script does not match the spent output")
+		}
+		switch btcScriptType(in.RedeemScript) {
+		case "p2wpkh", "p2wsh":
+			witness, err = in.witnessStack(in.RedeemScript)
+		default:
+			var stack [][]byte
+			stack, err = in.scriptStack(in.RedeemScript)
+			scriptSig = pushStack(stack)
+		}
+		scriptSig = append(scriptSig, PushBytes(in.RedeemScript)...)
+	default:
+		var stack [][]byte
+		stack, err = in.scriptStack(script)
+		scriptSig = pushStack(stack)
+	}
+	if err != nil {
+		return err
+	}
+
+	if scriptSig != nil {
+		in.FinalScriptSig = scriptSig
+	}
+	if witness != nil {
+		in.FinalScriptWitness = witness
+	}
+	in.PartialSigs = nil
+	in.SigHash = 0
+	in.RedeemScript = nil
+	in.WitnessScript = nil
+	in.Derivations = nil
+	in.TapKeySig = nil
+	in.TapScriptSigs = nil
+	in.TapLeafScripts = nil
+	in.TapDerivations = nil
+	in.TapInternalKey = nil
+	in.TapMerkleRoot = nil
+	return nil
+}
+
+// witnessStack returns the witness for a p2wpkh or p2wsh program
+func (in *PsbtInput) witnessStack(program []byte) ([][]byte, error) {
+	if btcScriptType(program) == "p2wpkh" {
+		return in.scriptStack(slices.Concat([]byte{0x76, 0xa9}, PushBytes(program[2:]), []byte{0x88, 0xac}))
+	}
+	if in.WitnessScript == nil {
+		return nil, errors.New("missing witness script")
+	}
+	h := sha256.Sum256(in.WitnessScript)
+	if !bytes.Equal(h[:], program[2:]) {
+		return nil, errors.New("witness script does not match the spent output")
+	}
+	stack, err := in.scriptStack(in.WitnessScript)
+	if err != nil {
+		return nil, err
+	}
+	return append(stack, in.WitnessScript), nil
+}
+
+// scriptStack returns the stack items satisfying script using the partial signatures
+func (in *PsbtInput) scriptStack(script []byte) ([][]byte, error) {
+	switch btcScriptType(script) {
+	case "p2pkh":
+		for _, sig := range in.PartialSigs {
+			if bytes.Equal(gobottle.Hash(sig.PubKey, sha256.New, ripemd160.New), script[3:23]) {
+				return [][]byte{sig.Sig, sig.PubKey}, nil
+			}
+		}
+		return nil, errors.New("missing signature")
+	case "p2pk":
+		pubKey, _ := ParsePushBytes(script)
+		sig := in.partialSig(pubKey, nil)
+		if sig == nil {
+			return nil, errors.New("missing signature")
+		}
+		return [][]byte{sig}, nil
+	}
+
+	ms, err := ParseMultisig(script)
+	if err != nil {
+		return nil, errors.New("unsupported script type for finalization")
+	}
+	// dummy element, then signatures in the same order as keys
+	stack := [][]byte{{}}
+	for _, pubKey := range ms.PubKeys {
+		if sig := in.partialSig(pubKey, nil); sig != nil && len(stack) <= ms.M {
+			stack = append(stack, sig)
+		}
+	}
+	if len(stack) <= ms.M {
+		return nil, fmt.Errorf("not enough signatures: %d of %d", len(stack)-1, ms.M)
+	}
+	return stack, nil
+}
+
+// taprootStack returns the witness for a taproot input, preferring key-path spending
+func (in *PsbtInput) taprootStack() ([][]byte, error) {
+	if in.TapKeySig != nil {
+		return [][]byte{in.TapKeySig}, nil
+	}
+	for _, leaf := range in.TapLeafScripts {
+		leafHash := (&TaprootTree{Script: leaf.Script, Version: leaf.Version}).Hash()
+		keys := tapscriptKeys(leaf.Script)
+		if len(keys) == 0 {
+			continue
+		}
+		// the first key is checked first so its signature goes on top, keys without a
+		// signature get an empty value as long as enough signatures are provided
+		required := tapscriptThreshold(leaf.Script, len(keys))
+		var stack [][]byte
+		var cnt int
+		for i := len(keys) - 1; i >= 0; i-- {
+			sig := in.partialSig(keys[i], leafHash)
+			if sig == nil || cnt == required {
+				stack = append(stack, []byte{})
+				continue
+			}
+			stack = append(stack, sig)
+			cnt += 1
+		}
+		if cnt == required {
+			return append(stack, leaf.Script, leaf.ControlBlock), nil
+		}
+	}
+	return nil, errors.New("missing taproot signature")
+}
+
+// Extract returns the final transaction, ready to be broadcast. All inputs must have been
+// finalized first.
+func (p *Psbt) Extract() (*BtcTx, error) {
+	tx, err := p.unsignedTx()
+	if err != nil {
+		return nil, err
+	}
+	for n, in := range p.In {
+		if !in.isFinal() {
+			return nil, fmt.Errorf("input %d: not finalized", n)
+		}
+		tx.In[n].Script = slices.Clone(in.FinalScriptSig)
+		tx.In[n].Witnesses = slices.Clone(in.FinalScriptWitness)
+	}
+	return tx, nil
+}
+
+// pushStack returns a script pushing the given items
+func pushStack(stack [][]byte) []byte {
+	var res []byte
+	for _, v := range stack {
+		res = append(res, PushBytes(v)...)
+	}
+	return res
+}
+
+// btcScriptType returns the type of a standard output script, or an empty string
+func btcScriptType(script []byte) string {
+	switch {
+	case len(script) == 25 && script[0] == 0x76 && script[1] == 0xa9 && script[2] == 0x14 && script[23] == 0x88 && script[24] == 0xac:
+		return "p2pkh"
+	case len(script) == 23 && script[0] == 0xa9 && script[1] == 0x14 && script[22] == 0x87:
+		return "p2sh"
+	case len(script) == 22 && script[0] == 0x00 && script[1] == 0x14:
+		return "p2wpkh"
+	case len(script) == 34 && script[0] == 0x00 && script[1] == 0x20:
+		return "p2wsh"
+	case len(script) == 34 && script[0] == 0x51 && script[1] == 0x20:
+		return "p2tr"
+	case (len(script) == 35 && script[0] == 0x21 || len(script) == 67 && script[0] == 0x41) && script[len(script)-1] == 0xac:
+		return "p2pk"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,150 @@
+package outscript
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"io"
+
+	"github.com/KarpelesLab/secp256k1"
+)
+
+// BIP-340 Schnorr signatures over secp256k1, as used by taproot
+
+// taggedHash computes a BIP-340 tagged hash: sha256(sha256(tag) || sha256(tag) || data...)
+func taggedHash(tag string, data ...[]byte) []byte {
+	tagHash := sha256.Sum256([]byte(tag))
+	h := sha256.New()
+	h.Write(tagHash[:])
+	h.Write(tagHash[:])
+	for _, d := range data {
+		h.Write(d)
+	}
+	return h.Sum(nil)
+}
+
+// liftX returns the point with the given x coordinate and an even y coordinate
+func liftX(x []byte) (*secp256k1.JacobianPoint, error) {
+	if len(x) != 32 {
+		return nil, errors.New("x-only public key must be 32 bytes long")
+	}
+	res := &secp256k1.JacobianPoint{}
+	if overflow := res.X.SetByteSlice(x); overflow {
+		return nil, errors.New("x-only public key exceeds field size")
+	}
+	if !secp256k1.DecompressY(&res.X, false, &res.Y) {
+		return nil, errors.New("x-only public key is not on the curve")
+	}
+	res.Z.SetInt(1)
+	return res, nil
+}
+
+// xOnly returns the x coordinate of p (which must be in affine form) and whether its y coordinate is odd
+func xOnly(p *secp256k1.JacobianPoint) ([]byte, bool) {
+	return p.X.Bytes()[:], p.Y.IsOdd()
+}
+
+// schnorrSign performs a BIP-340 signature of msg with the secret scalar d
+func schnorrSign(d *secp256k1.ModNScalar, msg, aux []byte) ([]byte, error) {
+	if d.IsZero() {
+		return nil, errors.New("invalid private key for schnorr signature")
+	}
+	if len(aux) != 32 {
+		return nil, errors.New("schnorr auxiliary randomness must be 32 bytes long")
+	}
+	d = new(secp256k1.ModNScalar).Set(d)
+
+	var P secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(d, &P)
+	P.ToAffine()
+	px, odd := xOnly(&P)
+	if odd {
+		d.Negate()
+	}
+
+	dBytes := d.Bytes()
+	t := taggedHash("BIP0340/aux", aux)
+	subtle.XORBytes(t, t, dBytes[:])
+
+	var k secp256k1.ModNScalar
+	k.SetByteSlice(taggedHash("BIP0340/nonce", t, px, msg))
+	if k.IsZero() {
+		return nil, errors.New("schnorr nonce generation failed")
+	}
+
+	var R secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&k, &R)
+	R.ToAffine()
+	rx, odd := xOnly(&R)
+	if odd {
+		k.Negate()
+	}
+
+	var e secp256k1.ModNScalar
+	e.SetByteSlice(taggedHash("BIP0340/challenge", rx, px, msg))
+
+	s := new(secp256k1.ModNScalar).Mul2(&e, d).Add(&k)
+	sBytes := s.Bytes()
+	sig := append(rx, sBytes[:]...)
+
+	// make sure the signature we generated is valid
+	if !SchnorrVerify(px, msg, sig) {
+		return nil, errors.New("generated schnorr signature failed verification")
+	}
+	return sig, nil
+}
+
+// SchnorrSign generates a 64 bytes BIP-340 Schnorr signature of the 32 bytes hash using
+// the given key. If aux is nil, fresh random data will be used as auxiliary randomness.
+func SchnorrSign(key *secp256k1.PrivateKey, hash, aux []byte) ([]byte, error) {
+	if aux == nil {
+		return schnorrSignRandom(&key.Key, hash)
+	}
+	return schnorrSign(&key.Key, hash, aux)
+}
+
+// schnorrSignRandom performs a BIP-340 signature using fresh auxiliary randomness
+func schnorrSignRandom(d *secp256k1.ModNScalar, msg []byte) ([]byte, error) {
+	aux := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, aux); err != nil {
+		return nil, err
+	}
+	return schnorrSign(d, msg, aux)
+}
+
+// SchnorrVerify checks a 64 bytes BIP-340 Schnorr signature against a 32 bytes x-only public key.
+func SchnorrVerify(pubkey, hash, sig []byte) bool {
+	if len(sig) != 64 {
+		return false
+	}
+	P, err := liftX(pubkey)
+	if err != nil {
+		return false
+	}
+	var r secp256k1.FieldVal
+	if overflow := r.SetByteSlice(sig[:32]); overflow {
+		return false
+	}
+	var s secp256k1.ModNScalar
+	if overflow := s.SetByteSlice(sig[32:]); overflow {
+		return false
+	}
+	var e secp256k1.ModNScalar
+	e.SetByteSlice(taggedHash("BIP0340/challenge", sig[:32], pubkey, hash))
+	e.Negate()
+
+	// R = s*G - e*P
+	var sG, eP, R secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&s, &sG)
+	secp256k1.ScalarMultNonConst(&e, P, &eP)
+	secp256k1.AddNonConst(&sG, &eP, &R)
+	if (R.X.IsZero() && R.Y.IsZero()) || R.Z.IsZero() {
+		return false
+	}
+	R.ToAffine()
+	if R.Y.IsOdd() {
+		return false
+	}
+	return R.X.Equals(&r)
+}
@@ -0,0 +1,74 @@
+package outscript_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestSchnorrSign(t *testing.T) {
+	// test vectors from https://github.com/bitcoin/bips/blob/master/bip-0340/test-vectors.csv
+	testV := [][5]string{
+		{"0000000000000000000000000000000000000000000000000000000000000003", "F9308A019258C31049344F85F89D5229B531C845836F99B08601F113BCE036F9", "0000000000000000000000000000000000000000000000000000000000000000", "0000000000000000000000000000000000000000000000000000000000000000", "E907831F80848D1069A5371B402410364BDF1C5F8307B0084C55F1CE2DCA821525F66A4A85EA8B71E482A74F382D2CE5EBEEE8FDB2172F477DF4900D310536C0"},
+		{"B7E151628AED2A6ABF7158809CF4F3C762E7160F38B4DA56A784D9045190CFEF", "DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659", "0000000000000000000000000000000000000000000000000000000000000001", "243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89", "6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A"},
+	}
+
+	for _, tv := range testV {
+		key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString(tv[0])))
+		pub := must(hex.DecodeString(tv[1]))
+		aux := must(hex.DecodeString(tv[2]))
+		msg := must(hex.DecodeString(tv[3]))
+		expect := must(hex.DecodeString(tv[4]))
+
+		if xonly := must(outscript.New(key.PubKey()).Generate("pubkey:xonly")); !bytes.Equal(xonly, pub) {
+			t.Errorf("unexpected x-only pubkey %x", xonly)
+		}
+
+		sig, err := outscript.SchnorrSign(key, msg, aux)
+		if err != nil {
+			t.Errorf("failed to sign: %s", err)
+			continue
+		}
+		if !bytes.Equal(sig, expect) {
+			t.Errorf("unexpected signature %s", strings.ToUpper(hex.EncodeToString(sig)))
+		}
+		if !outscript.SchnorrVerify(pub, msg, sig) {
+			t.Errorf("signature failed to verify")
+		}
+
+		// random aux
+		sig, err = outscript.SchnorrSign(key, msg, nil)
+		if err != nil {
+			t.Errorf("failed to sign: %s", err)
+		} else if !outscript.SchnorrVerify(pub, msg, sig) {
+			t.Errorf("signature with random aux failed to verify")
+		}
+	}
+}
+
+func TestSchnorrVerifyInvalid(t *testing.T) {
+	pub := must(hex.DecodeString("DFF1D77F2A671C5F36183726DB2341BE58FEAE1DA2DECED843240F7B502BA659"))
+	msg := must(hex.DecodeString("243F6A8885A308D313198A2E03707344A4093822299F31D0082EFA98EC4E6C89"))
+	sig := must(hex.DecodeString("6896BD60EEAE296DB48A229FF71DFE071BDE413E6D43F917DC8DCF8C78DE33418906D11AC976ABCCB20B091292BFF4EA897EFCB639EA871CFA95F6DE339E4B0A"))
+
+	// altered message
+	bad := bytes.Clone(msg)
+	bad[0] ^= 1
+	if outscript.SchnorrVerify(pub, bad, sig) {
+		t.Errorf("signature verified with altered message")
+	}
+	// altered signature
+	bad = bytes.Clone(sig)
+	bad[63] ^= 1
+	if outscript.SchnorrVerify(pub, msg, bad) {
+		t.Errorf("altered signature verified")
+	}
+	// truncated signature
+	if outscript.SchnorrVerify(pub, msg, sig[:63]) {
+		t.Errorf("truncated signature verified")
+	}
+}
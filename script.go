@@ -34,8 +34,8 @@ func New(pubkey crypto.PublicKey) *Script {
 	return v
 }
 
-// getPubKeyBytes returns the public key in the requested format (one of pubkey, pubkey:comp or pubkey:uncomp).
-// pubkey:comp and pubkey:uncomp require a secp256k1 key
+// getPubKeyBytes returns the public key in the requested format (one of pubkey, pubkey:comp, pubkey:uncomp or pubkey:xonly).
+// pubkey:comp, pubkey:uncomp and pubkey:xonly require a secp256k1 key
 func (s *Script) getPubKeyBytes(typ string) ([]byte, error) {
 	switch typ {
 	case "pubkey:pkix":
@@ -60,6 +60,14 @@ func (s *Script) getPubKeyBytes(typ string) ([]byte, error) {
 		default:
 			return nil, fmt.Errorf("pubkey of type %T does not support %s export", s.pubkey, typ)
 		}
+	case "pubkey:xonly":
+		// BIP-340 x-only key, which is the compressed key without its parity byte
+		switch o := s.pubkey.(type) {
+		case interface{ SerializeCompressed() []byte }:
+			return o.SerializeCompressed()[1:], nil
+		default:
+			return nil, fmt.Errorf("pubkey of type %T does not support %s export", s.pubkey, typ)
+		}
 	default:
 		return nil, fmt.Errorf("unknown public key format %s", typ)
 	}
@@ -73,7 +81,7 @@ func (s *Script) Generate(name string) ([]byte, error) {
 
 	// some special cases to access the public key
 	switch name {
-	case "pubkey:pkix", "pubkey:ed25519", "pubkey:comp", "pubkey:uncomp":
+	case "pubkey:pkix", "pubkey:ed25519", "pubkey:comp", "pubkey:uncomp", "pubkey:xonly":
 		res, err := s.getPubKeyBytes(name)
 		if err != nil {
 			return nil, err
@@ -87,6 +95,16 @@ func (s *Script) Generate(name string) ([]byte, error) {
 		return nil, fmt.Errorf("unsupported format %s", name)
 	}
 
+	res, err := s.generateFormat(f)
+	if err != nil {
+		return nil, err
+	}
+	s.cache[name] = res
+	return res, nil
+}
+
+// generateFormat returns the bytes of the given format for the current public key, without caching
+func (s *Script) generateFormat(f Format) ([]byte, error) {
 	var pieces [][]byte
 
 	for _, piece := range f {
@@ -96,9 +114,7 @@ func (s *Script) Generate(name string) ([]byte, error) {
 		}
 		pieces = append(pieces, v)
 	}
-	res := slices.Concat(pieces...)
-	s.cache[name] = res
-	return res, nil
+	return slices.Concat(pieces...), nil
 }
 
 // Out returns a [Out] object matching the requested script
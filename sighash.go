@@ -0,0 +1,146 @@
+package outscript
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"slices"
+
+	"github.com/BottleFmt/gobottle"
+)
+
+// Signature hash types for [BtcTxSign.SigHash]. SigHashAnyoneCanPay can be combined with any of
+// the base types, and SigHashForkID is required for bitcoin-cash signatures.
+const (
+	SigHashDefault      = 0x00 // taproot only, signs like SigHashAll without appending a type byte
+	SigHashAll          = 0x01 // sign all inputs and outputs
+	SigHashNone         = 0x02 // sign all inputs and no output
+	SigHashSingle       = 0x03 // sign all inputs and the output with the same index
+	SigHashForkID       = 0x40 // bitcoin-cash replay protection, BIP-143 hashing for all inputs
+	SigHashAnyoneCanPay = 0x80 // sign only the current input
+)
+
+// legacySigHash returns the signature hash of input n for pre-segwit scripts
+func (tx *BtcTx) legacySigHash(n int, scriptCode []byte, hashType uint32) []byte {
+	if hashType&0x1f == SigHashSingle && n >= len(tx.Out) {
+		// SIGHASH_SINGLE without a matching output signs the value 1, as per the original
+		// implementation
+		res := make([]byte, 32)
+		res[0] = 1
+		return res
+	}
+
+	// OP_CODESEPARATOR is never part of the signed script
+	var script []byte
+	for pc := 0; pc < len(scriptCode); {
+		op, _, next, err := readScriptOp(scriptCode, pc)
+		if err != nil {
+			script = append(script, scriptCode[pc:]...)
+			break
+		}
+		if op != 0xab {
+			script = append(script, scriptCode[pc:next]...)
+		}
+		pc = next
+	}
+
+	wtx := tx.Dup()
+	wtx.ClearInputs()
+	wtx.In[n].Script = script
+
+	switch hashType & 0x1f {
+	case SigHashNone:
+		wtx.Out = nil
+		wtx.clearSequences(n)
+	case SigHashSingle:
+		wtx.Out = wtx.Out[:n+1]
+		for _, out := range wtx.Out[:n] {
+			out.Amount = math.MaxUint64 // -1
+			out.Script = nil
+		}
+		wtx.clearSequences(n)
+	}
+	if hashType&SigHashAnyoneCanPay != 0 {
+		wtx.In = wtx.In[n : n+1]
+	}
+
+	buf := binary.LittleEndian.AppendUint32(wtx.exportBytes(false), hashType)
+	return gobottle.Hash(buf, sha256.New, sha256.New)
+}
+
+// clearSequences sets the sequence of all inputs but n to zero, so that they can be updated
+// without invalidating SIGHASH_NONE and SIGHASH_SINGLE signatures
+func (tx *BtcTx) clearSequences(n int) {
+	for i, in := range tx.In {
+		if i != n {
+			in.Sequence = 0
+		}
+	}
+}
+
+// witnessSigHash returns the BIP-143 signature hash of input n, also used by bitcoin-cash
+// signatures with SIGHASH_FORKID
+func (tx *BtcTx) witnessSigHash(n int, scriptCode []byte, amount BtcAmount, hashType uint32) []byte {
+	base := hashType & 0x1f
+	hashPrevouts := make([]byte, 32)
+	hashSequence := make([]byte, 32)
+	hashOutputs := make([]byte, 32)
+
+	if hashType&SigHashAnyoneCanPay == 0 {
+		var prevouts, sequences []byte
+		for _, in := range tx.In {
+			outpoint, seq := in.preimageBytes()
+			prevouts = append(prevouts, outpoint...)
+			sequences = append(sequences, seq...)
+		}
+		hashPrevouts = gobottle.Hash(prevouts, sha256.New, sha256.New)
+		if base != SigHashSingle && base != SigHashNone {
+			hashSequence = gobottle.Hash(sequences, sha256.New, sha256.New)
+		}
+	}
+	switch {
+	case base != SigHashSingle && base != SigHashNone:
+		var outputs []byte
+		for _, out := range tx.Out {
+			outputs = append(outputs, out.Bytes()...)
+		}
+		hashOutputs = gobottle.Hash(outputs, sha256.New, sha256.New)
+	case base == SigHashSingle && n < len(tx.Out):
+		hashOutputs = gobottle.Hash(tx.Out[n].Bytes(), sha256.New, sha256.New)
+	}
+
+	input, inputSeq := tx.In[n].preimageBytes()
+	buf := slices.Concat(
+		binary.LittleEndian.AppendUint32(nil, tx.Version),
+		hashPrevouts,
+		hashSequence,
+		input,
+		BtcVarInt(len(scriptCode)).Bytes(),
+		scriptCode,
+		binary.LittleEndian.AppendUint64(nil, uint64(amount)),
+		inputSeq,
+		hashOutputs,
+		binary.LittleEndian.AppendUint32(nil, tx.Locktime),
+		binary.LittleEndian.AppendUint32(nil, hashType),
+	)
+	return gobottle.Hash(buf, sha256.New, sha256.New)
+}
+
+// signHash returns the hash to sign for input n with the given scriptCode, using BIP-143 for
+// segwit inputs and bitcoin-cash FORKID signatures, and the legacy algorithm otherwise
+func (tx *BtcTx) signHash(n int, k *BtcTxSign, scriptCode []byte, segwit bool) []byte {
+	if segwit || k.SigHash&SigHashForkID != 0 {
+		return tx.witnessSigHash(n, scriptCode, k.Amount, k.SigHash)
+	}
+	return tx.legacySigHash(n, scriptCode, k.SigHash)
+}
+
+// sign returns the ECDSA signature of hash followed by the sighash type byte
+func (k *BtcTxSign) sign(hash []byte) ([]byte, error) {
+	sign, err := k.Key.Sign(rand.Reader, hash, k.Options)
+	if err != nil {
+		return nil, err
+	}
+	return append(sign, byte(k.SigHash&0xff)), nil
+}
@@ -0,0 +1,233 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestBtcTxSignSigHash(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	ms := must(outscript.NewMultisig(1, key.PubKey()))
+	txid := outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f")))
+
+	schemes := []string{"p2pkh", "p2wpkh", "p2sh:p2wpkh", "p2wsh:p2pkh", "p2sh:multisig", "p2wsh:multisig"}
+	sigHashes := []uint32{
+		outscript.SigHashAll,
+		outscript.SigHashNone,
+		outscript.SigHashSingle,
+		outscript.SigHashAll | outscript.SigHashAnyoneCanPay,
+		outscript.SigHashNone | outscript.SigHashAnyoneCanPay,
+		outscript.SigHashSingle | outscript.SigHashAnyoneCanPay,
+	}
+
+	for _, scheme := range schemes {
+		var prevScript []byte
+		if scheme == "p2sh:multisig" || scheme == "p2wsh:multisig" {
+			prevScript = must(ms.Out(scheme[:len(scheme)-9])).Bytes()
+		} else {
+			prevScript = must(outscript.New(key.PubKey()).Generate(scheme))
+		}
+
+		for _, sigHash := range sigHashes {
+			name := fmt.Sprintf("%s/%02x", scheme, sigHash)
+			tx := &outscript.BtcTx{Version: 2}
+			var keys []*outscript.BtcTxSign
+			var prevOuts []*outscript.BtcTxOutput
+			for n := range 2 {
+				tx.In = append(tx.In, &outscript.BtcTxInput{TXID: txid, Vout: uint32(n), Sequence: 0xfffffffd})
+				tx.Out = append(tx.Out, &outscript.BtcTxOutput{Amount: outscript.BtcAmount(40000 + n), Script: prevScript})
+				keys = append(keys, &outscript.BtcTxSign{Key: key, Scheme: scheme, Amount: 50000, SigHash: sigHash, Multisig: ms})
+				prevOuts = append(prevOuts, &outscript.BtcTxOutput{Amount: 50000, Script: prevScript})
+			}
+			if err := tx.Sign(keys...); err != nil {
+				t.Fatalf("%s: failed to sign: %s", name, err)
+			}
+			if err := tx.Verify(prevOuts); err != nil {
+				t.Fatalf("%s: failed to verify: %s", name, err)
+			}
+
+			base := sigHash & 0x1f
+			acp := sigHash&outscript.SigHashAnyoneCanPay != 0
+			check := func(what string, n int, ok bool, modify func(tx *outscript.BtcTx, prevOuts []*outscript.BtcTxOutput) []*outscript.BtcTxOutput) {
+				t.Helper()
+				wtx := tx.Dup()
+				err := wtx.VerifyInputFlags(n, modify(wtx, append([]*outscript.BtcTxOutput{}, prevOuts...)), outscript.BtcVerifyAll)
+				if ok && err != nil {
+					t.Errorf("%s: %s should not invalidate input %d: %s", name, what, n, err)
+				} else if !ok && err == nil {
+					t.Errorf("%s: %s should invalidate input %d", name, what, n)
+				}
+			}
+
+			check("changing the second output", 0, base != outscript.SigHashAll, func(tx *outscript.BtcTx, p []*outscript.BtcTxOutput) []*outscript.BtcTxOutput {
+				tx.Out[1].Amount = 1000
+				return p
+			})
+			check("changing the second output", 1, base == outscript.SigHashNone, func(tx *outscript.BtcTx, p []*outscript.BtcTxOutput) []*outscript.BtcTxOutput {
+				tx.Out[1].Amount = 1000
+				return p
+			})
+			check("changing the first output", 0, base == outscript.SigHashNone, func(tx *outscript.BtcTx, p []*outscript.BtcTxOutput) []*outscript.BtcTxOutput {
+				tx.Out[0].Amount = 1000
+				return p
+			})
+			check("changing another input sequence", 0, base != outscript.SigHashAll || acp, func(tx *outscript.BtcTx, p []*outscript.BtcTxOutput) []*outscript.BtcTxOutput {
+				tx.In[1].Sequence = 0
+				return p
+			})
+			check("adding an input", 0, acp, func(tx *outscript.BtcTx, p []*outscript.BtcTxOutput) []*outscript.BtcTxOutput {
+				tx.In = append(tx.In, &outscript.BtcTxInput{TXID: txid, Vout: 5})
+				return append(p, prevOuts[0])
+			})
+		}
+	}
+}
+
+func TestBtcTxSignSigHashSingleOutOfRange(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	prevScript := must(outscript.New(key.PubKey()).Generate("p2pkh"))
+
+	tx := &outscript.BtcTx{Version: 1}
+	for n := range 2 {
+		tx.In = append(tx.In, &outscript.BtcTxInput{Vout: uint32(n), Sequence: 0xffffffff})
+	}
+	tx.Out = append(tx.Out, &outscript.BtcTxOutput{Amount: 1000, Script: prevScript})
+	prevOuts := []*outscript.BtcTxOutput{{Amount: 5000, Script: prevScript}, {Amount: 5000, Script: prevScript}}
+
+	keys := []*outscript.BtcTxSign{
+		{Key: key, Scheme: "p2pkh"},
+		{Key: key, Scheme: "p2pkh", SigHash: outscript.SigHashSingle},
+	}
+	if err := tx.Sign(keys...); err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if err := tx.Verify(prevOuts); err != nil {
+		t.Fatalf("failed to verify: %s", err)
+	}
+
+	// the second input signs the constant value 1, so its signature is valid in any transaction
+	sig := tx.In[1].Script
+	other := &outscript.BtcTx{Version: 2, Locktime: 100}
+	other.In = append(other.In, &outscript.BtcTxInput{Vout: 9}, &outscript.BtcTxInput{Vout: 3, Script: sig})
+	if err := other.VerifyInputFlags(1, prevOuts, outscript.BtcVerifyAll); err != nil {
+		t.Errorf("expected SIGHASH_SINGLE bug signature to be valid: %s", err)
+	}
+}
+
+func TestBtcTxSignForkID(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	bch := outscript.BtcVerifyP2SH | outscript.BtcVerifyCLTV | outscript.BtcVerifyCSV | outscript.BtcVerifyForkID
+
+	for _, scheme := range []string{"p2pkh", "p2pukh"} {
+		prevScript := must(outscript.New(key.PubKey()).Generate(scheme))
+		tx := &outscript.BtcTx{Version: 2}
+		tx.In = append(tx.In, &outscript.BtcTxInput{Sequence: 0xffffffff})
+		tx.Out = append(tx.Out, &outscript.BtcTxOutput{Amount: 1000, Script: prevScript})
+		prevOuts := []*outscript.BtcTxOutput{{Amount: 5000, Script: prevScript}}
+
+		if err := tx.Sign(&outscript.BtcTxSign{Key: key, Scheme: scheme, Amount: 5000, SigHash: outscript.SigHashAll | outscript.SigHashForkID}); err != nil {
+			t.Fatalf("%s: failed to sign: %s", scheme, err)
+		}
+		if err := tx.VerifyInputFlags(0, prevOuts, bch); err != nil {
+			t.Errorf("%s: failed to verify FORKID signature: %s", scheme, err)
+		}
+		if err := tx.VerifyInputFlags(0, prevOuts, outscript.BtcVerifyAll); err == nil {
+			t.Errorf("%s: expected FORKID signature to fail on bitcoin", scheme)
+		}
+		// the amount is part of the signed data
+		if err := tx.VerifyInputFlags(0, []*outscript.BtcTxOutput{{Amount: 5001, Script: prevScript}}, bch); err == nil {
+			t.Errorf("%s: expected error with wrong amount", scheme)
+		}
+
+		// signatures without FORKID are rejected by bitcoin-cash
+		if err := tx.Sign(&outscript.BtcTxSign{Key: key, Scheme: scheme}); err != nil {
+			t.Fatalf("%s: failed to sign: %s", scheme, err)
+		}
+		if err := tx.VerifyInputFlags(0, prevOuts, bch); err == nil {
+			t.Errorf("%s: expected error without FORKID", scheme)
+		}
+		if err := tx.VerifyInputFlags(0, prevOuts, outscript.BtcVerifyAll); err != nil {
+			t.Errorf("%s: failed to verify bitcoin signature: %s", scheme, err)
+		}
+	}
+}
+
+func TestBtcTxSigHashVectors(t *testing.T) {
+	// legacy cases from bitcoin core sighash.json: raw transaction, script, input index, hash
+	// type (signed) and the signature hash, displayed reversed
+	legacy := []struct {
+		tx      string
+		script  string
+		n       int
+		typ     int32
+		sigHash string
+	}{
+		{"f2b539a401e4e8402869d5e1502dbc3156dbce93583f516a4947b333260d5af1a34810c6a00200000003525363ffffffff01d305e2000000000005acab535200a265fe77", "", 0, -1435650456, "41617b27321a830c712638dbb156dae23d4ef181c7a06728ccbf3153ec53d7dd"},
+		{"fea256ce01272d125e577c0a09570a71366898280dda279b021000db1325f27edda41a53460100000002ab53c752c21c013c2b3a01000000000000000000", "65", 0, 1145543262, "076b9f844f6ae429de228a2c337c704df1652c292b6c6494882190638dad9efd"},
+		{"c33028b301d5093e1e8397270d75a0b009b2a6509a01861061ab022ca122a6ba935b8513320200000000ffffffff013bcf5a0500000000015200000000", "", 0, -513413204, "6b1459536f51482f5dbf42d7e561896557461e1e3b6bf67871e2b51faae2832c"},
+		{"b240517501334021240427adb0b413433641555424f6d24647211e3e6bfbb22a8045cbda2f000000000071bac8630112717802000000000000000000", "6a5165abac52656551", 0, 1790414254, "2c8be597620d95abd88f9c1cf4967c1ae3ca2309f3afec8928058c9598660e9e"},
+		{"cf7bdc250249e22cbe23baf6b648328d31773ea0e771b3b76a48b4748d7fbd390e88a004d30000000003ac536a4ab8cce0e097136c90b2037f231b7fde2063017facd40ed4e5896da7ad00e9c71dd70ae600000000096a0063516352525365ffffffff01b71e3e00000000000300536a00000000", "", 1, 546970113, "6a815ba155270af102322c882f26d22da11c5330a751f520807936b320b9af5d"},
+		{"b7877f82019c832707a60cf14fba44cfa254d787501fdd676bd58c744f6e951dbba0b3b77f0200000009ac515263ac53525300a5a36e500148f89c0500000000085265ac6a6a65acab00000000", "6563", 0, -1785108415, "cb6e4322955af12eb29613c70e1a00ddbb559c887ba844df0bcdebed736dffbd"},
+		{"e3cdbfb4014d90ae6a4401e85f7ac717adc2c035858bf6ff48979dd399d155bce1f150daea0300000002ac51a67a0d39017f6c71040000000005535200535200000000", "", 0, -1899950911, "c1c7df8206e661d593f6455db1d61a364a249407f88e99ecad05346e495b38d7"},
+		{"df0a32ae01c4672fd1abd0b2623aae0a1a8256028df57e532f9a472d1a9ceb194267b6ee190200000009536a6a51516a525251b545f9e803469a2302000000000465526500810631040000000000441f5b050000000006530051006aaceb183c76", "536a635252ac6a", 0, 1601138113, "9a0435996cc58bdba09643927fe48c1fc908d491a050abbef8daec87f323c58f"},
+		{"2f7353dd02e395b0a4d16da0f7472db618857cd3de5b9e2789232952a9b154d249102245fd030000000151617fd88f103280b85b0a198198e438e7cab1a4c92ba58409709997cc7a65a619eb9eec3c0200000003636aabffffffff0397481c0200000000045300636a0dc97803000000000009d389030000000003ac6a53134007bb", "0000536552526a", 0, -1912746174, "30c4cd4bd6b291f7e9489cc4b4440a083f93a7664ea1f93e77a9597dab8ded9c"},
+		{"25ee54ef0187387564bb86e0af96baec54289ca8d15e81a507a2ed6668dc92683111dfb7a50100000004005263634cecf17d0429aa4d000000000007636a6aabab5263daa75601000000000251ab4df70a01000000000151980a890400000000065253ac6a006377fd24e3", "65ab", 0, 797877378, "069f38fd5d47abff46f04ee3ae27db03275e9aa4737fa0d2f5394779f9654845"},
+		{"ff5400dd02fec5beb9a396e1cbedc82bedae09ed44bae60ba9bef2ff375a6858212478844b03000000025253ffffffff01e46c203577a79d1172db715e9cc6316b9cfc59b5e5e4d9199fef201c6f9f0f000000000900ab6552656a5165acffffffff02e8ce62040000000002515312ce3e00000000000251513f119316", "", 0, 1541581667, "1e0da47eedbbb381b0e0debbb76e128d042e02e65b11125e17fd127305fc65cd"},
+		{"6f62138301436f33a00b84a26a0457ccbfc0f82403288b9cbae39986b34357cb2ff9b889b302000000045253655335a7ff6701bac9960400000000086552ab656352635200000000", "6aac51", 0, 1444414211, "502a2435fd02898d2ff3ab08a3c19078414b32ec9b73d64a944834efc9dae10c"},
+		{"d3b7421e011f4de0f1cea9ba7458bf3486bee722519efab711a963fa8c100970cf7488b7bb0200000003525352dcd61b300148be5d05000000000000000000", "535251536aac536a", 0, -1960128125, "29aa6d2d752d3310eba20442770ad345b7f6a35f96161ede5f07b33e92053e2a"},
+		{"3c436c2501442a5b700cbc0622ee5143b34b1b8021ea7bbc29e4154ab1f5bdfb3dff9d640501000000086aab5251ac5252acffffffff0170b9a20300000000066aab6351525114b13791", "63acabab52ab51ac65", 0, -2140612788, "87ddf1f9acb6640448e955bd1968f738b4b3e073983af7b83394ab7557f5cd61"},
+	}
+	for _, v := range legacy {
+		tx := &outscript.BtcTx{}
+		if err := tx.UnmarshalBinary(must(hex.DecodeString(v.tx))); err != nil {
+			t.Fatalf("failed to parse tx: %s", err)
+		}
+		h := outscript.LegacySigHash(tx, v.n, must(hex.DecodeString(v.script)), uint32(v.typ))
+		slices.Reverse(h)
+		if hex.EncodeToString(h) != v.sigHash {
+			t.Errorf("legacy sighash mismatch for type %d: %x", v.typ, h)
+		}
+	}
+
+	// BIP-143 P2SH-P2WSH example, with each hash type
+	tx := &outscript.BtcTx{}
+	if err := tx.UnmarshalBinary(must(hex.DecodeString("010000000136641869ca081e70f394c6948e8af409e18b619df2ed74aa106c1ca29787b96e0100000000ffffffff0200e9a435000000001976a914389ffce9cd9ae88dcc0631e88a821ffdbe9bfe2688acc0832f05000000001976a9147480a33f950689af511e6e84c138dbbd3c3ee41588ac00000000"))); err != nil {
+		t.Fatalf("failed to parse tx: %s", err)
+	}
+	script := must(hex.DecodeString("56210307b8ae49ac90a048e9b53357a2354b3334e9c8bee813ecb98e99a7e07e8c3ba32103b28f0c28bfab54554ae8c658ac5c3e0ce6e79ad336331f78c428dd43eea8449b21034b8113d703413d57761b8b9781957b8c0ac1dfe69f492580ca4195f50376ba4a21033400f6afecb833092a9a21cfdf1ed1376e58c5d1f47de74683123987e967a8f42103a6d48b1131e94ba04d9737d61acdaa1322008af9602b3b14862c07a1789aac162102d8b661b0b3302ee2f162b09e07a55ad5dfbe673a9f01d9f0c19617681024306b56ae"))
+	for typ, sigHash := range map[uint32]string{
+		outscript.SigHashAll:                                    "185c0be5263dce5b4bb50a047973c1b6272bfbd0103a89444597dc40b248ee7c",
+		outscript.SigHashNone:                                   "e9733bc60ea13c95c6527066bb975a2ff29a925e80aa14c213f686cbae5d2f36",
+		outscript.SigHashSingle:                                 "1e1f1c303dc025bd664acb72e583e933fae4cff9148bf78c157d1e8f78530aea",
+		outscript.SigHashAll | outscript.SigHashAnyoneCanPay:    "2a67f03e63a6a422125878b40b82da593be8d4efaafe88ee528af6e5a9955c6e",
+		outscript.SigHashNone | outscript.SigHashAnyoneCanPay:   "781ba15f3779d5542ce8ecb5c18716733a5ee42a6f51488ec96154934e2c890a",
+		outscript.SigHashSingle | outscript.SigHashAnyoneCanPay: "511e8e52ed574121fc1b654970395502128263f62662e076dc6baf05c2e6a99b",
+	} {
+		if h := hex.EncodeToString(outscript.WitnessSigHash(tx, 0, script, 987654321, typ)); h != sigHash {
+			t.Errorf("witness sighash mismatch for type %02x: %s", typ, h)
+		}
+	}
+
+	// BIP-143 native P2WPKH example, then the same input signed with bitcoin-cash FORKID which
+	// only differs by the hash type
+	tx = &outscript.BtcTx{}
+	if err := tx.UnmarshalBinary(must(hex.DecodeString("0100000002fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f0000000000eeffffffef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a0100000000ffffffff02202cb206000000001976a9148280b37df378db99f66f85c95a783a76ac7a6d5988ac9093510d000000001976a9143bde42dbee7e4dbe6a21b2d50ce2f0167faa815988ac11000000"))); err != nil {
+		t.Fatalf("failed to parse tx: %s", err)
+	}
+	script = must(hex.DecodeString("76a9141d0f172a0ecb48aee1be1f2687d2963ae33f71a188ac"))
+	for typ, sigHash := range map[uint32]string{
+		outscript.SigHashAll:                           "c37af31116d1b27caf68aae9e3ac82f1477929014d5b917657d0eb49478cb670",
+		outscript.SigHashAll | outscript.SigHashForkID: "467f411d178762db122a6aced76370a1c8324355bf0796502bf82eeaeda86a35",
+		outscript.SigHashAll | outscript.SigHashForkID | outscript.SigHashAnyoneCanPay: "a5890ce40dc95a89717ae6fa3c9d60bcf9372539058c7e9a0cd8ff7909723326",
+		outscript.SigHashSingle | outscript.SigHashForkID:                              "abb61ba86e14313425d25846ed3a30904de1f081e013d80c385e165c2af1e020",
+	} {
+		if h := hex.EncodeToString(outscript.WitnessSigHash(tx, 1, script, 600000000, typ)); h != sigHash {
+			t.Errorf("witness sighash mismatch for type %02x: %s", typ, h)
+		}
+	}
+}
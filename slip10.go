@@ -0,0 +1,88 @@
+package outscript
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/BottleFmt/gobottle"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// Ed25519HDKey is a SLIP-10 ed25519 extended private key, as used by Solana and Massa wallets.
+// Only hardened derivation is possible with ed25519.
+type Ed25519HDKey struct {
+	Depth             uint8
+	ParentFingerprint uint32 // as big endian, zero for master keys
+	ChildNumber       uint32
+	ChainCode         []byte
+	Key               []byte // ed25519 seed of the private key
+}
+
+// NewEd25519HDKey returns the SLIP-10 ed25519 master key generated from seed, for example the
+// result of [MnemonicSeed].
+func NewEd25519HDKey(seed []byte) (*Ed25519HDKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errors.New("seed must be between 16 and 64 bytes long")
+	}
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	I := mac.Sum(nil)
+	return &Ed25519HDKey{ChainCode: I[32:], Key: I[:32]}, nil
+}
+
+// PrivateKey returns the ed25519 private key, which can be used with [SolanaTx.Sign].
+func (k *Ed25519HDKey) PrivateKey() ed25519.PrivateKey {
+	return ed25519.NewKeyFromSeed(k.Key)
+}
+
+// PublicKey returns the ed25519 public key, which can be passed to [New].
+func (k *Ed25519HDKey) PublicKey() ed25519.PublicKey {
+	return k.PrivateKey().Public().(ed25519.PublicKey)
+}
+
+// Fingerprint returns the first 4 bytes of the hash160 of the public key prefixed with 0x00, as
+// big endian.
+func (k *Ed25519HDKey) Fingerprint() uint32 {
+	h := gobottle.Hash(append([]byte{0}, k.PublicKey()...), sha256.New, ripemd160.New)
+	return binary.BigEndian.Uint32(h[:4])
+}
+
+// Child derives the child key at index i, which must be hardened (HDHardened or more).
+func (k *Ed25519HDKey) Child(i uint32) (*Ed25519HDKey, error) {
+	if i < HDHardened {
+		return nil, errors.New("ed25519 only supports hardened derivation")
+	}
+	if k.Depth == 255 {
+		return nil, errors.New("maximum derivation depth reached")
+	}
+	data := append([]byte{0}, k.Key...)
+	data = binary.BigEndian.AppendUint32(data, i)
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	I := mac.Sum(nil)
+	return &Ed25519HDKey{Depth: k.Depth + 1, ParentFingerprint: k.Fingerprint(), ChildNumber: i, ChainCode: I[32:], Key: I[:32]}, nil
+}
+
+// Derive derives the key at the given path, such as "m/44'/501'/0'/0'" for the first Solana
+// account. All path elements must be hardened. Paths starting with "m" can only be derived from a
+// master key, other paths are relative to k.
+func (k *Ed25519HDKey) Derive(path string) (*Ed25519HDKey, error) {
+	if strings.HasPrefix(path, "m") && k.Depth != 0 {
+		return nil, errors.New("absolute derivation path requires a master key")
+	}
+	p, err := ParseHDPath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range p {
+		if k, err = k.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
@@ -0,0 +1,291 @@
+package outscript
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/BottleFmt/gobottle"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+// ITaprootTweakInfo is an [Insertable] that tweaks a 32 bytes x-only internal key into a
+// taproot output key as per BIP-341, optionally committing to a script tree merkle root.
+type ITaprootTweakInfo struct {
+	v    Insertable
+	root []byte
+}
+
+// ITaprootTweak returns an [ITaprootTweakInfo] that tweaks the x-only key returned by v with
+// no script tree (BIP-86).
+func ITaprootTweak(v Insertable) ITaprootTweakInfo {
+	return ITaprootTweakInfo{v: v}
+}
+
+// ITaprootScriptTweak returns an [ITaprootTweakInfo] that tweaks the x-only key returned by v
+// to commit to the script tree with the given merkle root.
+func ITaprootScriptTweak(v Insertable, merkleRoot []byte) ITaprootTweakInfo {
+	return ITaprootTweakInfo{v: v, root: merkleRoot}
+}
+
+// Bytes returns the tweaked x-only output key.
+func (i ITaprootTweakInfo) Bytes(s *Script) ([]byte, error) {
+	v, err := i.v.Bytes(s)
+	if err != nil {
+		return nil, err
+	}
+	res, _, err := taprootTweakPubKey(v, i.root)
+	return res, err
+}
+
+// String returns a human-readable representation of the tweak operation.
+func (i ITaprootTweakInfo) String() string {
+	if i.root != nil {
+		return fmt.Sprintf("TaprootTweak(%s, %x)", i.v, i.root)
+	}
+	return fmt.Sprintf("TaprootTweak(%s)", i.v)
+}
+
+// taprootTweakPubKey computes the taproot output key Q = P + hash_TapTweak(P || merkleRoot)*G for
+// the x-only internal key P, and returns Q in x-only format along with the parity of its y
+// coordinate. merkleRoot can be nil for key-path only outputs (BIP-86).
+func taprootTweakPubKey(internalKey, merkleRoot []byte) ([]byte, bool, error) {
+	P, err := liftX(internalKey)
+	if err != nil {
+		return nil, false, err
+	}
+	var t secp256k1.ModNScalar
+	if overflow := t.SetByteSlice(taggedHash("TapTweak", internalKey, merkleRoot)); overflow {
+		return nil, false, errors.New("taproot tweak exceeds curve order")
+	}
+	var tG, Q secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(&t, &tG)
+	secp256k1.AddNonConst(P, &tG, &Q)
+	if (Q.X.IsZero() && Q.Y.IsZero()) || Q.Z.IsZero() {
+		return nil, false, errors.New("taproot tweak resulted in an invalid key")
+	}
+	Q.ToAffine()
+	res, odd := xOnly(&Q)
+	return res, odd, nil
+}
+
+// taprootTweakPrivKey returns the secret scalar matching the output key computed by
+// [taprootTweakPubKey] for the public key of priv.
+func taprootTweakPrivKey(priv *secp256k1.PrivateKey, merkleRoot []byte) (*secp256k1.ModNScalar, error) {
+	d := new(secp256k1.ModNScalar).Set(&priv.Key)
+	if d.IsZero() {
+		return nil, errors.New("invalid private key")
+	}
+	var P secp256k1.JacobianPoint
+	secp256k1.ScalarBaseMultNonConst(d, &P)
+	P.ToAffine()
+	px, odd := xOnly(&P)
+	if odd {
+		d.Negate()
+	}
+	var t secp256k1.ModNScalar
+	if overflow := t.SetByteSlice(taggedHash("TapTweak", px, merkleRoot)); overflow {
+		return nil, errors.New("taproot tweak exceeds curve order")
+	}
+	return d.Add(&t), nil
+}
+
+// taprootSigHash computes the BIP-341 signature hash for input n. prevOuts must contain the
+// outputs spent by each of the transaction's inputs, in order. leafHash is nil for key-path
+// spending, or the tapleaf hash of the executed script for script-path spending.
+func (tx *BtcTx) taprootSigHash(n int, prevOuts []*BtcTxOutput, hashType uint32, leafHash []byte) ([]byte, error) {
+	return tx.taprootSigHashExt(n, prevOuts, hashType, leafHash, nil, 0xffffffff)
+}
+
+// taprootSigHashExt is [BtcTx.taprootSigHash] with an optional annex, and the position of the
+// last executed OP_CODESEPARATOR in the tapscript (0xffffffff if none).
+func (tx *BtcTx) taprootSigHashExt(n int, prevOuts []*BtcTxOutput, hashType uint32, leafHash, annex []byte, codeSepPos uint32) ([]byte, error) {
+	if len(prevOuts) != len(tx.In) {
+		return nil, errors.New("taproot signature requires the spent output of every input")
+	}
+	switch hashType {
+	case 0x00, 0x01, 0x02, 0x03, 0x81, 0x82, 0x83:
+	default:
+		return nil, fmt.Errorf("invalid taproot sighash type 0x%x", hashType)
+	}
+	outType := hashType & 3
+	anyoneCanPay := hashType&0x80 == 0x80
+
+	// epoch + hash_type + nVersion + nLockTime
+	msg := []byte{0x00, byte(hashType)}
+	msg = binary.LittleEndian.AppendUint32(msg, tx.Version)
+	msg = binary.LittleEndian.AppendUint32(msg, tx.Locktime)
+
+	if !anyoneCanPay {
+		var prevouts, amounts, scripts, sequences []byte
+		for i, in := range tx.In {
+			if prevOuts[i] == nil {
+				return nil, errors.New("taproot signature requires the spent output of every input")
+			}
+			outpoint, seq := in.preimageBytes()
+			prevouts = append(prevouts, outpoint...)
+			sequences = append(sequences, seq...)
+			amounts = binary.LittleEndian.AppendUint64(amounts, uint64(prevOuts[i].Amount))
+			scripts = append(scripts, BtcVarInt(len(prevOuts[i].Script)).Bytes()...)
+			scripts = append(scripts, prevOuts[i].Script...)
+		}
+		msg = append(msg, gobottle.Hash(prevouts, sha256.New)...)
+		msg = append(msg, gobottle.Hash(amounts, sha256.New)...)
+		msg = append(msg, gobottle.Hash(scripts, sha256.New)...)
+		msg = append(msg, gobottle.Hash(sequences, sha256.New)...)
+	}
+	if outType != 2 && outType != 3 {
+		// SIGHASH_DEFAULT or SIGHASH_ALL
+		var outputs []byte
+		for _, out := range tx.Out {
+			outputs = append(outputs, out.Bytes()...)
+		}
+		msg = append(msg, gobottle.Hash(outputs, sha256.New)...)
+	}
+
+	// spend_type = (ext_flag * 2) + annex_present
+	var spendType byte
+	if leafHash != nil {
+		spendType = 2
+	}
+	if annex != nil {
+		spendType |= 1
+	}
+	msg = append(msg, spendType)
+
+	if anyoneCanPay {
+		if prevOuts[n] == nil {
+			return nil, errors.New("taproot signature requires the spent output of the input")
+		}
+		outpoint, seq := tx.In[n].preimageBytes()
+		msg = append(msg, outpoint...)
+		msg = binary.LittleEndian.AppendUint64(msg, uint64(prevOuts[n].Amount))
+		msg = append(msg, BtcVarInt(len(prevOuts[n].Script)).Bytes()...)
+		msg = append(msg, prevOuts[n].Script...)
+		msg = append(msg, seq...)
+	} else {
+		msg = binary.LittleEndian.AppendUint32(msg, uint32(n))
+	}
+	if annex != nil {
+		msg = append(msg, gobottle.Hash(slices.Concat(BtcVarInt(len(annex)).Bytes(), annex), sha256.New)...)
+	}
+
+	if outType == 3 {
+		// SIGHASH_SINGLE
+		if n >= len(tx.Out) {
+			return nil, errors.New("taproot SIGHASH_SINGLE without a matching output")
+		}
+		msg = append(msg, gobottle.Hash(tx.Out[n].Bytes(), sha256.New)...)
+	}
+
+	if leafHash != nil {
+		// tapleaf_hash + key_version + codesep_pos
+		msg = append(msg, leafHash...)
+		msg = append(msg, 0x00)
+		msg = binary.LittleEndian.AppendUint32(msg, codeSepPos)
+	}
+
+	return taggedHash("TapSighash", msg), nil
+}
+
+// p2trSign performs a taproot key-path signature of input n
+func (tx *BtcTx) p2trSign(n int, k *BtcTxSign, prevOuts []*BtcTxOutput) error {
+	priv, ok := k.Key.(*secp256k1.PrivateKey)
+	if !ok {
+		return fmt.Errorf("p2tr signature requires a secp256k1 private key, got %T", k.Key)
+	}
+	var merkleRoot []byte
+	if k.Taproot != nil {
+		if k.Taproot.InternalKey != nil {
+			xonly, err := New(priv.PubKey()).Generate("pubkey:xonly")
+			if err != nil {
+				return err
+			}
+			if !bytes.Equal(xonly, k.Taproot.InternalKey) {
+				return errors.New("p2tr key-path signature requires the internal key")
+			}
+		}
+		merkleRoot = k.Taproot.Tree.Hash()
+	}
+	d, err := taprootTweakPrivKey(priv, merkleRoot)
+	if err != nil {
+		return err
+	}
+	sigHash, err := tx.taprootSigHash(n, prevOuts, k.SigHash, nil)
+	if err != nil {
+		return err
+	}
+	sign, err := schnorrSignRandom(d, sigHash)
+	if err != nil {
+		return err
+	}
+	if k.SigHash != 0 {
+		sign = append(sign, byte(k.SigHash))
+	}
+	tx.In[n].Witnesses = [][]byte{sign}
+	tx.In[n].Script = nil
+	return nil
+}
+
+// p2trScriptSign performs a taproot script-path signature of input n. The witness stack gets
+// one slot per public key found in the leaf script so that several keys can sign the same
+// input one after the other, with missing signatures left empty.
+func (tx *BtcTx) p2trScriptSign(n int, k *BtcTxSign, prevOuts []*BtcTxOutput) error {
+	priv, ok := k.Key.(*secp256k1.PrivateKey)
+	if !ok {
+		return fmt.Errorf("p2tr signature requires a secp256k1 private key, got %T", k.Key)
+	}
+	if k.Taproot == nil || k.Taproot.Leaf == nil {
+		return errors.New("p2tr:script signature requires a taproot leaf")
+	}
+	leaf := k.Taproot.Leaf
+	internalKey, err := k.Taproot.internalKey(priv.PubKey())
+	if err != nil {
+		return err
+	}
+	controlBlock, err := k.Taproot.Tree.ControlBlock(internalKey, leaf)
+	if err != nil {
+		return err
+	}
+
+	// locate where our signature goes
+	xonly, err := New(priv.PubKey()).Generate("pubkey:xonly")
+	if err != nil {
+		return err
+	}
+	keys := tapscriptKeys(leaf.Script)
+	pos := slices.IndexFunc(keys, func(v []byte) bool { return bytes.Equal(v, xonly) })
+	if pos == -1 {
+		return errors.New("signing key does not appear in the taproot leaf script")
+	}
+
+	sigHash, err := tx.taprootSigHash(n, prevOuts, k.SigHash, leaf.Hash())
+	if err != nil {
+		return err
+	}
+	sign, err := schnorrSignRandom(&priv.Key, sigHash)
+	if err != nil {
+		return err
+	}
+	if k.SigHash != 0 {
+		sign = append(sign, byte(k.SigHash))
+	}
+
+	// the first key in the script is checked first, so its signature must be at the top of the stack
+	wit := tx.In[n].Witnesses
+	if len(wit) != len(keys)+2 || !bytes.Equal(wit[len(keys)], leaf.Script) || !bytes.Equal(wit[len(keys)+1], controlBlock) {
+		wit = make([][]byte, len(keys)+2)
+		for i := range keys {
+			wit[i] = []byte{}
+		}
+		wit[len(keys)] = leaf.Script
+		wit[len(keys)+1] = controlBlock
+	}
+	wit[len(keys)-1-pos] = sign
+	tx.In[n].Witnesses = wit
+	tx.In[n].Script = nil
+	return nil
+}
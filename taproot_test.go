@@ -0,0 +1,97 @@
+package outscript_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestTaprootAddress(t *testing.T) {
+	// test vectors from https://github.com/bitcoin/bips/blob/master/bip-0086.mediawiki
+	testV := [][3]string{
+		{"cc8a4bc64d897bddc5fbc2f670f7a8ba0b386779106cf1223c6fc5d7cd6fc115", "5120a60869f0dbcf1dc659c9cecbaf8050135ea9e8cdc487053f1dc6880949dc684c", "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr"},
+		{"83dfe85a3151d2517290da461fe2815591ef69f2b18a2ce63f01697a8b313145", "5120a82f29944d65b86ae6b5e5cc75e294ead6c59391a1edc5e016e3498c67fc7bbb", "bc1p4qhjn9zdvkux4e44uhx8tc55attvtyu358kutcqkudyccelu0was9fqzwh"},
+	}
+
+	for _, tv := range testV {
+		// the parity of the internal key does not matter
+		pub := must(secp256k1.ParsePubKey(must(hex.DecodeString("02" + tv[0]))))
+		s := outscript.New(pub)
+
+		script := must(s.Generate("p2tr"))
+		if hex.EncodeToString(script) != tv[1] {
+			t.Errorf("unexpected p2tr script %x", script)
+		}
+		addr, err := s.Address("p2tr", "bitcoin")
+		if err != nil {
+			t.Errorf("failed to generate address: %s", err)
+		} else if addr != tv[2] {
+			t.Errorf("unexpected p2tr address %s", addr)
+		}
+
+		out, err := outscript.ParseBitcoinBasedAddress("bitcoin", tv[2])
+		if err != nil {
+			t.Errorf("failed to parse address: %s", err)
+		} else if !bytes.Equal(out.Bytes(), script) {
+			t.Errorf("parsed address does not match script")
+		}
+	}
+}
+
+func TestBtcTxSignP2TR(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("eb696a065ef48a2192da5b28b694f87544b30fae8327c4510137a922f32c6dcf")))
+	key2 := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("619c335025c7f4012e556c2a58b2506e30b8511b53ade95ea316fd8c3286feb9")))
+
+	tx := &outscript.BtcTx{Version: 2}
+	tx.In = append(tx.In,
+		&outscript.BtcTxInput{TXID: outscript.Hex32(must(hex.DecodeString("fff7f7881a8099afa6940d42d1e7f6362bec38171ea3edf433541db4e4ad969f"))), Vout: 0, Sequence: 0xffffffff},
+		&outscript.BtcTxInput{TXID: outscript.Hex32(must(hex.DecodeString("ef51e1b804cc89d182d279655c3aa89e815b1b309fe287d9b2b55d57b90ec68a"))), Vout: 1, Sequence: 0xfffffffd},
+	)
+	if err := tx.AddNetOutput("bitcoin", "bc1p5cyxnuxmeuwuvkwfem96lqzszd02n6xdcjrs20cac6yqjjwudpxqkedrcr", 90000); err != nil {
+		t.Fatalf("failed to add output: %s", err)
+	}
+
+	err := tx.Sign(
+		&outscript.BtcTxSign{Key: key, Scheme: "p2tr", Amount: 50000},
+		&outscript.BtcTxSign{Key: key2, Scheme: "p2wpkh", Amount: 50000},
+	)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if len(tx.In[0].Witnesses) != 1 || len(tx.In[0].Witnesses[0]) != 64 {
+		t.Errorf("expected a single 64 bytes witness for SIGHASH_DEFAULT, got %x", tx.In[0].Witnesses)
+	}
+	if len(tx.In[0].Script) != 0 {
+		t.Errorf("p2tr input should have an empty script")
+	}
+
+	err = tx.Sign(
+		&outscript.BtcTxSign{Key: key, Scheme: "p2tr", Amount: 50000, SigHash: 0x01},
+		&outscript.BtcTxSign{Key: key2, Scheme: "p2wpkh", Amount: 50000},
+	)
+	if err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	if w := tx.In[0].Witnesses; len(w) != 1 || len(w[0]) != 65 || w[0][64] != 0x01 {
+		t.Errorf("expected a single 65 bytes witness for SIGHASH_ALL, got %x", w)
+	}
+	prevOuts := []*outscript.BtcTxOutput{
+		{Amount: 50000, Script: must(outscript.New(key.PubKey()).Generate("p2tr"))},
+		{Amount: 50000, Script: must(outscript.New(key2.PubKey()).Generate("p2wpkh"))},
+	}
+	if err := tx.Verify(prevOuts); err != nil {
+		t.Errorf("failed to verify signed tx: %s", err)
+	}
+
+	// invalid sighash type
+	err = tx.Sign(
+		&outscript.BtcTxSign{Key: key, Scheme: "p2tr", Amount: 50000, SigHash: 0x04},
+		&outscript.BtcTxSign{Key: key2, Scheme: "p2wpkh", Amount: 50000},
+	)
+	if err == nil {
+		t.Errorf("expected error with invalid sighash type")
+	}
+}
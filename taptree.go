@@ -0,0 +1,235 @@
+package outscript
+
+import (
+	"bytes"
+	"crypto"
+	"errors"
+	"slices"
+)
+
+// TaprootLeafVersion is the leaf version used for BIP-342 tapscript leaves.
+const TaprootLeafVersion = 0xc0
+
+// TaprootTree is a node of a taproot script tree as defined in BIP-341. A node is either a
+// leaf holding a script, or a branch holding two child nodes.
+type TaprootTree struct {
+	Script  []byte       // leaf script, nil for branches
+	Version byte         // leaf version, typically TaprootLeafVersion
+	Left    *TaprootTree // left child, nil for leaves
+	Right   *TaprootTree // right child, nil for leaves
+}
+
+// TaprootSpend holds the information needed to sign for a taproot output that commits to a
+// script tree, either through the key path ("p2tr") or one of its leaves ("p2tr:script").
+type TaprootSpend struct {
+	InternalKey []byte       // 32 bytes x-only internal key, defaults to the signing key
+	Tree        *TaprootTree // script tree committed in the output, nil for BIP-86 outputs
+	Leaf        *TaprootTree // leaf to execute for script-path spending
+}
+
+// NewTaprootLeaf returns a tapscript leaf for the given script.
+func NewTaprootLeaf(script []byte) *TaprootTree {
+	return &TaprootTree{Script: script, Version: TaprootLeafVersion}
+}
+
+// NewTaprootBranch returns a branch joining the two given nodes.
+func NewTaprootBranch(left, right *TaprootTree) *TaprootTree {
+	return &TaprootTree{Left: left, Right: right}
+}
+
+// NewTaprootTree builds a balanced tree out of the given nodes by joining them two by two,
+// level after level. It returns nil if no node is given.
+func NewTaprootTree(nodes ...*TaprootTree) *TaprootTree {
+	if len(nodes) == 0 {
+		return nil
+	}
+	for len(nodes) > 1 {
+		var next []*TaprootTree
+		for i := 0; i < len(nodes); i += 2 {
+			if i+1 == len(nodes) {
+				// odd node gets moved up as is
+				next = append(next, nodes[i])
+				break
+			}
+			next = append(next, NewTaprootBranch(nodes[i], nodes[i+1]))
+		}
+		nodes = next
+	}
+	return nodes[0]
+}
+
+// IsLeaf returns true if the node is a leaf.
+func (t *TaprootTree) IsLeaf() bool {
+	return t.Left == nil && t.Right == nil
+}
+
+// Hash returns the tapleaf hash of a leaf, or the tapbranch hash of a branch. The hash of the
+// root node is the merkle root of the tree. Calling Hash on a nil tree returns nil.
+func (t *TaprootTree) Hash() []byte {
+	if t == nil {
+		return nil
+	}
+	if t.IsLeaf() {
+		return taggedHash("TapLeaf", []byte{t.Version &^ 1}, BtcVarInt(len(t.Script)).Bytes(), t.Script)
+	}
+	a, b := t.Left.Hash(), t.Right.Hash()
+	if bytes.Compare(a, b) > 0 {
+		a, b = b, a
+	}
+	return taggedHash("TapBranch", a, b)
+}
+
+// Leaves returns all the leaves of the tree, from left to right.
+func (t *TaprootTree) Leaves() []*TaprootTree {
+	if t == nil {
+		return nil
+	}
+	if t.IsLeaf() {
+		return []*TaprootTree{t}
+	}
+	return append(t.Left.Leaves(), t.Right.Leaves()...)
+}
+
+// merklePath returns the hashes needed to go from the leaf with the given hash up to the root,
+// starting with the leaf's sibling.
+func (t *TaprootTree) merklePath(leafHash []byte) ([][]byte, bool) {
+	if t.IsLeaf() {
+		return nil, bytes.Equal(t.Hash(), leafHash)
+	}
+	if path, ok := t.Left.merklePath(leafHash); ok {
+		return append(path, t.Right.Hash()), true
+	}
+	if path, ok := t.Right.merklePath(leafHash); ok {
+		return append(path, t.Left.Hash()), true
+	}
+	return nil, false
+}
+
+// OutputKey returns the x-only taproot output key committing to internalKey and the tree,
+// along with the parity of the key.
+func (t *TaprootTree) OutputKey(internalKey []byte) ([]byte, bool, error) {
+	return taprootTweakPubKey(internalKey, t.Hash())
+}
+
+// Out returns the p2tr [Out] committing to internalKey and the tree. This can be used with
+// unspendable internal keys for outputs that can only be spent through the script path.
+func (t *TaprootTree) Out(internalKey []byte) (*Out, error) {
+	key, _, err := t.OutputKey(internalKey)
+	if err != nil {
+		return nil, err
+	}
+	return makeOut("p2tr", slices.Concat([]byte{0x51}, PushBytes(key))), nil
+}
+
+// ControlBlock returns the control block needed to spend the given leaf of the tree through
+// the script path, for an output using internalKey.
+func (t *TaprootTree) ControlBlock(internalKey []byte, leaf *TaprootTree) ([]byte, error) {
+	if t == nil || leaf == nil || !leaf.IsLeaf() {
+		return nil, errors.New("taproot control block requires a tree and one of its leaves")
+	}
+	path, ok := t.merklePath(leaf.Hash())
+	if !ok {
+		return nil, errors.New("leaf is not part of the taproot tree")
+	}
+	_, odd, err := t.OutputKey(internalKey)
+	if err != nil {
+		return nil, err
+	}
+	first := leaf.Version &^ 1
+	if odd {
+		first |= 1
+	}
+	return slices.Concat(append([][]byte{{first}, internalKey}, path...)...), nil
+}
+
+// TaprootOut returns the p2tr [Out] using the current public key as internal key and
+// committing to the given script tree. A nil tree returns the same output as "p2tr".
+func (s *Script) TaprootOut(tree *TaprootTree) (*Out, error) {
+	buf, err := s.generateFormat(Format{Bytes{0x51}, IPushBytes{ITaprootScriptTweak(Lookup("pubkey:xonly"), tree.Hash())}})
+	if err != nil {
+		return nil, err
+	}
+	return makeOut("p2tr", buf), nil
+}
+
+// internalKey returns the internal key for this spend, using pub if none was specified
+func (ts *TaprootSpend) internalKey(pub crypto.PublicKey) ([]byte, error) {
+	if ts != nil && ts.InternalKey != nil {
+		return ts.InternalKey, nil
+	}
+	return New(pub).Generate("pubkey:xonly")
+}
+
+// outScript returns the p2tr output script this spend refers to
+func (ts *TaprootSpend) outScript(pub crypto.PublicKey) ([]byte, error) {
+	internalKey, err := ts.internalKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	var tree *TaprootTree
+	if ts != nil {
+		tree = ts.Tree
+	}
+	out, err := tree.Out(internalKey)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// tapscriptKeys returns the 32 bytes values pushed by a tapscript and consumed by a signature
+// check (OP_CHECKSIG, OP_CHECKSIGVERIFY or OP_CHECKSIGADD), in order. These are the x-only
+// public keys a signature may be provided for.
+func tapscriptKeys(script []byte) [][]byte {
+	var res [][]byte
+	for len(script) > 0 {
+		op := script[0]
+		if op == 0 || op > 0x4e {
+			// not a data push
+			script = script[1:]
+			continue
+		}
+		v, n := ParsePushBytes(script)
+		if n == 0 {
+			// invalid push, stop there
+			break
+		}
+		script = script[n:]
+		if len(v) == 32 && len(script) > 0 && slices.Contains([]byte{0xac, 0xad, 0xba}, script[0]) {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// tapscriptThreshold returns the number of signatures required by a tapscript with the given
+// number of keys. This is k for a multi_a script ending in <k> OP_NUMEQUAL, and one signature
+// per key for any other script.
+func tapscriptThreshold(script []byte, keys int) int {
+	var prev []byte // previous opcode, including its data for pushes
+	for len(script) > 0 {
+		n := 1
+		if op := script[0]; op != 0 && op <= 0x4e {
+			if _, n = ParsePushBytes(script); n == 0 {
+				return keys
+			}
+		}
+		if len(script) == 1 && script[0] == 0x9c && prev != nil {
+			// OP_NUMEQUAL, the previous opcode pushes k
+			var k int64
+			switch op := prev[0]; {
+			case op >= 0x51 && op <= 0x60:
+				k = int64(op - 0x50)
+			case op != 0 && op <= 0x4e:
+				v, _ := ParsePushBytes(prev)
+				k, _ = scriptNum(v, 4)
+			}
+			if k > 0 && k <= int64(keys) {
+				return int(k)
+			}
+			return keys
+		}
+		prev, script = script[:n], script[n:]
+	}
+	return keys
+}
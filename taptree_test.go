@@ -0,0 +1,177 @@
+package outscript_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestTaprootTree(t *testing.T) {
+	// BIP-341 wallet test vectors (scriptPubKey)
+	vectors := []struct {
+		internalKey string
+		tree        *outscript.TaprootTree
+		leafHashes  []string
+		outputKey   string
+		address     string
+		control     []string
+	}{
+		{
+			internalKey: "187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27",
+			tree:        outscript.NewTaprootLeaf(must(hex.DecodeString("20d85a959b0290bf19bb89ed43c916be835475d013da4b362117393e25a48229b8ac"))),
+			leafHashes:  []string{"5b75adecf53548f3ec6ad7d78383bf84cc57b55a3127c72b9a2481752dd88b21"},
+			outputKey:   "147c9c57132f6e7ecddba9800bb0c4449251c92a1e60371ee77557b6620f3ea3",
+			address:     "bc1pz37fc4cn9ah8anwm4xqqhvxygjf9rjf2resrw8h8w4tmvcs0863sa2e586",
+			control:     []string{"c1187791b6f712a8ea41c8ecdd0ee77fab3e85263b37e1ec18a3651926b3a6cf27"},
+		},
+		{
+			internalKey: "93478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820",
+			tree:        outscript.NewTaprootLeaf(must(hex.DecodeString("20b617298552a72ade070667e86ca63b8f5789a9fe8731ef91202a91c9f3459007ac"))),
+			leafHashes:  []string{"c525714a7f49c28aedbbba78c005931a81c234b2f6c99a73e4d06082adc8bf2b"},
+			outputKey:   "e4d810fd50586274face62b8a807eb9719cef49c04177cc6b76a9a4251d5450e",
+			control:     []string{"c093478e9488f956df2396be2ce6c5cced75f900dfa18e7dabd2428aae78451820"},
+		},
+		{
+			internalKey: "ee4fe085983462a184015d1f782d6a5f8b9c2b60130aff050ce221ecf3786592",
+			tree: outscript.NewTaprootTree(
+				outscript.NewTaprootLeaf(must(hex.DecodeString("20387671353e273264c495656e27e39ba899ea8fee3bb69fb2a680e22093447d48ac"))),
+				&outscript.TaprootTree{Script: must(hex.DecodeString("06424950333431")), Version: 0xfa},
+			),
+			leafHashes: []string{
+				"8ad69ec7cf41c2a4001fd1f738bf1e505ce2277acdcaa63fe4765192497f47a7",
+				"f224a923cd0021ab202ab139cc56802ddb92dcfc172b9212261a539df79a112a",
+			},
+			outputKey: "712447206d7a5238acc7ff53fbe94a3b64539ad291c7cdbc490b7577e4b17df5",
+			control: []string{
+				"c0ee4fe085983462a184015d1f782d6a5f8b9c2b60130aff050ce221ecf3786592f224a923cd0021ab202ab139cc56802ddb92dcfc172b9212261a539df79a112a",
+				"faee4fe085983462a184015d1f782d6a5f8b9c2b60130aff050ce221ecf37865928ad69ec7cf41c2a4001fd1f738bf1e505ce2277acdcaa63fe4765192497f47a7",
+			},
+		},
+	}
+
+	for n, v := range vectors {
+		internalKey := must(hex.DecodeString(v.internalKey))
+		leaves := v.tree.Leaves()
+		if len(leaves) != len(v.leafHashes) {
+			t.Fatalf("vector %d: expected %d leaves, got %d", n, len(v.leafHashes), len(leaves))
+		}
+		for i, leaf := range leaves {
+			if h := hex.EncodeToString(leaf.Hash()); h != v.leafHashes[i] {
+				t.Errorf("vector %d: bad leaf hash %d: %s", n, i, h)
+			}
+			ctrl := must(v.tree.ControlBlock(internalKey, leaf))
+			if h := hex.EncodeToString(ctrl); h != v.control[i] {
+				t.Errorf("vector %d: bad control block %d: %s", n, i, h)
+			}
+		}
+		out := must(v.tree.Out(internalKey))
+		if h := hex.EncodeToString(out.Bytes()); h != "5120"+v.outputKey {
+			t.Errorf("vector %d: bad output script %s", n, h)
+		}
+		if v.address != "" {
+			if addr := must(out.Address("bitcoin")); addr != v.address {
+				t.Errorf("vector %d: bad address %s", n, addr)
+			}
+		}
+	}
+
+	// a leaf that is not part of the tree cannot be spent
+	if _, err := vectors[0].tree.ControlBlock(must(hex.DecodeString(vectors[0].internalKey)), vectors[1].tree); err == nil {
+		t.Errorf("expected error for leaf not in tree")
+	}
+}
+
+func TestBtcTxSignP2TRScript(t *testing.T) {
+	keyA := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("0000000000000000000000000000000000000000000000000000000000000003")))
+	keyB := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("b7e151628aed2a6abf7158809cf4f3c762e7160f38b4da56a784d9045190cfef")))
+	xA := must(outscript.New(keyA.PubKey()).Generate("pubkey:xonly"))
+	xB := must(outscript.New(keyB.PubKey()).Generate("pubkey:xonly"))
+
+	// <A> OP_CHECKSIGVERIFY <B> OP_CHECKSIG
+	multi := outscript.NewTaprootLeaf(append(append(append(outscript.PushBytes(xA), 0xad), outscript.PushBytes(xB)...), 0xac))
+	// <A> OP_CHECKSIG
+	single := outscript.NewTaprootLeaf(append(outscript.PushBytes(xA), 0xac))
+	tree := outscript.NewTaprootTree(multi, single)
+
+	// key-path spending of an output with a script tree must match TaprootOut
+	out := must(outscript.New(keyA.PubKey()).TaprootOut(tree))
+	tx := &outscript.BtcTx{
+		Version: 2,
+		In:      []*outscript.BtcTxInput{{Vout: 0, Sequence: 0xffffffff}},
+		Out:     []*outscript.BtcTxOutput{{Amount: 90000, Script: out.Bytes()}},
+	}
+	err := tx.Sign(&outscript.BtcTxSign{Key: keyA, Scheme: "p2tr", Amount: 100000, Taproot: &outscript.TaprootSpend{Tree: tree}})
+	if err != nil {
+		t.Fatalf("key path signature failed: %s", err)
+	}
+	if len(tx.In[0].Witnesses) != 1 || len(tx.In[0].Witnesses[0]) != 64 {
+		t.Errorf("unexpected key path witness")
+	}
+	prevOuts := []*outscript.BtcTxOutput{{Amount: 100000, Script: out.Bytes()}}
+	if err := tx.Verify(prevOuts); err != nil {
+		t.Errorf("failed to verify key path signature: %s", err)
+	}
+
+	// script path, signed by each key separately
+	internalKey := xA
+	spend := &outscript.TaprootSpend{InternalKey: internalKey, Tree: tree, Leaf: multi}
+	err = tx.Sign(&outscript.BtcTxSign{Key: keyB, Scheme: "p2tr:script", Amount: 100000, Taproot: spend})
+	if err != nil {
+		t.Fatalf("script path signature failed: %s", err)
+	}
+	wit := tx.In[0].Witnesses
+	if len(wit) != 4 || len(wit[0]) != 64 || len(wit[1]) != 0 {
+		t.Fatalf("unexpected witness after first signature")
+	}
+	if err := tx.Verify(prevOuts); err == nil {
+		t.Errorf("expected error verifying partially signed input")
+	}
+	err = tx.Sign(&outscript.BtcTxSign{Key: keyA, Scheme: "p2tr:script", Amount: 100000, Taproot: spend})
+	if err != nil {
+		t.Fatalf("script path signature failed: %s", err)
+	}
+	wit = tx.In[0].Witnesses
+	if len(wit) != 4 || len(wit[0]) != 64 || len(wit[1]) != 64 {
+		t.Fatalf("unexpected witness after second signature")
+	}
+	if !bytes.Equal(wit[2], multi.Script) || !bytes.Equal(wit[3], must(tree.ControlBlock(internalKey, multi))) {
+		t.Errorf("bad script or control block in witness")
+	}
+	if err := tx.Verify(prevOuts); err != nil {
+		t.Errorf("failed to verify script path signature: %s", err)
+	}
+	if len(tx.Bytes()) == 0 {
+		t.Errorf("failed to serialize transaction")
+	}
+
+	// a key that is not part of the leaf cannot sign
+	err = tx.Sign(&outscript.BtcTxSign{Key: keyB, Scheme: "p2tr:script", Amount: 100000, Taproot: &outscript.TaprootSpend{InternalKey: internalKey, Tree: tree, Leaf: single}})
+	if err == nil {
+		t.Errorf("expected error when signing with a key not in the leaf")
+	}
+
+	// hash lock: OP_SHA256 <hash> OP_EQUALVERIFY <B> OP_CHECKSIG, only B is a key
+	preimage := []byte("outscript hash lock preimage")
+	hash := sha256.Sum256(preimage)
+	lock := outscript.NewTaprootLeaf(slices.Concat([]byte{0xa8}, outscript.PushBytes(hash[:]), []byte{0x88}, outscript.PushBytes(xB), []byte{0xac}))
+	lockTree := outscript.NewTaprootTree(lock, single)
+	lockOut := must(outscript.New(keyA.PubKey()).TaprootOut(lockTree))
+	err = tx.Sign(&outscript.BtcTxSign{Key: keyB, Scheme: "p2tr:script", Amount: 100000, Taproot: &outscript.TaprootSpend{InternalKey: internalKey, Tree: lockTree, Leaf: lock}})
+	if err != nil {
+		t.Fatalf("hash lock signature failed: %s", err)
+	}
+	wit = tx.In[0].Witnesses
+	if len(wit) != 3 || len(wit[0]) != 64 || !bytes.Equal(wit[1], lock.Script) {
+		t.Fatalf("unexpected hash lock witness %x", wit)
+	}
+	// the preimage goes on top of the signature
+	tx.In[0].Witnesses = slices.Insert(wit, 1, preimage)
+	if err := tx.Verify([]*outscript.BtcTxOutput{{Amount: 100000, Script: lockOut.Bytes()}}); err != nil {
+		t.Errorf("failed to verify hash lock spend: %s", err)
+	}
+}
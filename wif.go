@@ -0,0 +1,96 @@
+package outscript
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/BottleFmt/gobottle"
+	"github.com/KarpelesLab/base58"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+// wifVersions lists the WIF version byte of each network, usually the p2pkh version plus 0x80
+var wifVersions = map[string]byte{
+	"bitcoin":         0x80,
+	"bitcoin-cash":    0x80,
+	"bitcoin-testnet": 0xef,
+	"litecoin":        0xb0,
+	"dogecoin":        0x9e,
+	"namecoin":        0xb4,
+	"monacoin":        0xb0, // 0xb2 before monacoin 0.14, still accepted when parsing
+	"dash":            0xcc,
+	"electraproto":    0xb7,
+}
+
+// EncodeWIF returns the Wallet Import Format encoding of key for the given network. Compressed
+// keys are used for p2pkh and segwit outputs, uncompressed keys for p2pukh outputs.
+func EncodeWIF(key *secp256k1.PrivateKey, network string, compressed bool) (string, error) {
+	vers, ok := wifVersions[network]
+	if !ok {
+		return "", fmt.Errorf("unsupported network %s for WIF encoding", network)
+	}
+	b := key.Key.Bytes()
+	buf := append([]byte{vers}, b[:]...)
+	if compressed {
+		buf = append(buf, 1)
+	}
+	h := gobottle.Hash(buf, sha256.New, sha256.New)
+	return base58.Bitcoin.Encode(slices.Concat(buf, h[:4])), nil
+}
+
+// ParseWIF parses a private key in Wallet Import Format for the given network, or "auto" to
+// accept any known network. It returns the key and the scheme implied by its compressed flag,
+// "p2pkh" or "p2pukh".
+func ParseWIF(network, wif string) (*secp256k1.PrivateKey, string, error) {
+	buf, err := base58.Bitcoin.Decode(wif)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(buf) < 5 {
+		return nil, "", errors.New("invalid WIF length")
+	}
+	h := gobottle.Hash(buf[:len(buf)-4], sha256.New, sha256.New)
+	if subtle.ConstantTimeCompare(h[:4], buf[len(buf)-4:]) != 1 {
+		return nil, "", errors.New("bad checksum")
+	}
+	buf = buf[:len(buf)-4]
+
+	switch network {
+	case "auto":
+		known := buf[0] == 0xb2
+		for _, v := range wifVersions {
+			known = known || v == buf[0]
+		}
+		if !known {
+			return nil, "", fmt.Errorf("unsupported WIF version=%x", buf[0])
+		}
+	case "monacoin":
+		if buf[0] != 0xb0 && buf[0] != 0xb2 {
+			return nil, "", fmt.Errorf("unsupported %s WIF version=%x", network, buf[0])
+		}
+	default:
+		vers, ok := wifVersions[network]
+		if !ok {
+			return nil, "", fmt.Errorf("unsupported network %s for WIF parsing", network)
+		}
+		if buf[0] != vers {
+			return nil, "", fmt.Errorf("unsupported %s WIF version=%x", network, buf[0])
+		}
+	}
+
+	scheme := "p2pukh"
+	switch {
+	case len(buf) == 34 && buf[33] == 1:
+		scheme = "p2pkh"
+	case len(buf) != 33:
+		return nil, "", errors.New("invalid WIF length")
+	}
+	var k secp256k1.ModNScalar
+	if overflow := k.SetByteSlice(buf[1:33]); overflow || k.IsZero() {
+		return nil, "", errors.New("invalid private key")
+	}
+	return secp256k1.PrivKeyFromBytes(buf[1:33]), scheme, nil
+}
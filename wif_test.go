@@ -0,0 +1,79 @@
+package outscript_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/KarpelesLab/outscript"
+	"github.com/KarpelesLab/secp256k1"
+)
+
+func TestWIF(t *testing.T) {
+	key := secp256k1.PrivKeyFromBytes(must(hex.DecodeString("0c28fca386c7a227600b2fe50b7cae11ec86d3bf1fbe471be89827e19d72aa1d")))
+
+	// network, uncompressed, compressed
+	vectors := [][3]string{
+		{"bitcoin", "5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ", "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617"},
+		{"bitcoin-testnet", "91gGn1HgSap6CbU12F6z3pJri26xzp7Ay1VW6NHCoEayNXwRpu2", "cMzLdeGd5vEqxB8B6VFQoRopQ3sLAAvEzDAoQgvX54xwofSWj1fx"},
+		{"litecoin", "6uDNfQ1fknCphurZuj12xcY51qJj3T21Pk2iivwjAxAYHHxwEEr", "T3TccUZx4EXBZaHnFiP9eTr8igDEZoqSjNvbA56Z8vV74oyAcjTK"},
+		{"dogecoin", "6JDyVDw6R82kH9PsbHq3nqk8XnDoLmrFEEknLEZbHA3ZQ8cSuqc", "QP2GKa5kuU2i2G3xJMH5KL9NErbVYGxMoRiF5trrJJvHzrJ2Ebp7"},
+		{"namecoin", "72zMNKooAUujFCBPY3V2mtwAgHmbeGPWYJK9hXhK9aC6Fr9YnVD", "Tdn8u81YkrBjTeLrU1xiqAE5iR1quUvMP9R7PtUw6qVxC8hJzPjK"},
+		{"monacoin", "6uDNfQ1fknCphurZuj12xcY51qJj3T21Pk2iivwjAxAYHHxwEEr", "T3TccUZx4EXBZaHnFiP9eTr8igDEZoqSjNvbA56Z8vV74oyAcjTK"},
+		{"dash", "7qeDbtaZch9AUt8KHxP1fbKkf2YqFAcXRe1jZ8Cq1JMQ6nMVBe4", "XBhGczf8xYB2r4fHjqS9wLVmgqqVwXRoHkNEpnoCtKb2x5RsXrCP"},
+		{"electraproto", "78pau29tikwQeevWFnM2drjVRdckbNv8uDXURym18YDFjgK4vUi", "U5X2cc6FHJw9PCPA8F9QDgWYTicoQjjXsUHW5FXDaGm638Jq4g72"},
+	}
+	for _, v := range vectors {
+		for n, compressed := range []bool{false, true} {
+			if wif := must(outscript.EncodeWIF(key, v[0], compressed)); wif != v[n+1] {
+				t.Errorf("bad %s WIF: %s", v[0], wif)
+			}
+			for _, network := range []string{v[0], "auto"} {
+				k, scheme, err := outscript.ParseWIF(network, v[n+1])
+				if err != nil {
+					t.Errorf("failed to parse %s WIF %s: %s", network, v[n+1], err)
+					continue
+				}
+				if !k.Key.Equals(&key.Key) || (scheme == "p2pkh") != compressed {
+					t.Errorf("bad key parsed from %s", v[n+1])
+				}
+			}
+		}
+	}
+
+	// the scheme gives the matching address
+	for wif, addr := range map[string]string{
+		"5HueCGU8rMjxEXxiPuD5BDku4MkFqeZyd4dZ1jvhTVqvbTLvyTJ":  "1GAehh7TsJAHuUAeKZcXf5CnwuGuGgyX2S",
+		"KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617": "1LoVGDgRs9hTfTNJNuXKSpywcbdvwRXpmK",
+	} {
+		k, scheme := must2(outscript.ParseWIF("bitcoin", wif))
+		if a := must(outscript.New(k.PubKey()).Address(scheme, "bitcoin")); a != addr {
+			t.Errorf("bad address %s for %s", a, wif)
+		}
+	}
+
+	// legacy monacoin version
+	if _, _, err := outscript.ParseWIF("monacoin", "TLcskoHkQYMTWcKKMsAwEp37DYcYEeNu4GArGynk7szXdUH9ay9E"); err != nil {
+		t.Errorf("failed to parse legacy monacoin WIF: %s", err)
+	}
+
+	for _, v := range [][2]string{
+		{"litecoin", "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617"},
+		{"bitcoin", "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98618"},
+		{"bitcoin", "1GAehh7TsJAHuUAeKZcXf5CnwuGuGgyX2S"},
+		{"ethereum", "KwdMAjGmerYanjeui5SHS7JkmpZvVipYvB2LJGU1ZxJwYvP98617"},
+	} {
+		if _, _, err := outscript.ParseWIF(v[0], v[1]); err == nil {
+			t.Errorf("expected error parsing %s as %s", v[1], v[0])
+		}
+	}
+	if _, err := outscript.EncodeWIF(key, "solana", true); err == nil {
+		t.Errorf("expected error for unsupported network")
+	}
+}
+
+func must2[T, U any](a T, b U, err error) (T, U) {
+	if err != nil {
+		panic(err)
+	}
+	return a, b
+}